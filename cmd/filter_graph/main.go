@@ -20,6 +20,15 @@ type Edge struct {
 	To         int     `json:"to"`
 	Length     float64 `json:"length"`
 	SpeedLimit float64 `json:"speedlimit"`
+	RoadClass  string  `json:"road_class,omitempty"`
+	Lanes      int     `json:"lanes,omitempty"`
+	Name       string  `json:"name,omitempty"`
+	OneWay     bool    `json:"oneway,omitempty"`
+	Toll       bool    `json:"toll,omitempty"`
+	Roundabout bool    `json:"roundabout,omitempty"`
+	Capacity   float64 `json:"capacity,omitempty"`
+
+	Geometry [][2]float64 `json:"geometry,omitempty"`
 }
 
 type GraphData struct {
@@ -51,7 +60,6 @@ func main() {
 
 	fmt.Printf("Loaded: %d nodes, %d edges\n", len(graph.Nodes), len(graph.Edges))
 
-
 	// בניית רשימות שכנויות
 	adjList := make(map[int][]int)
 	reverseAdj := make(map[int][]int)
@@ -164,4 +172,4 @@ func main() {
 	}
 
 	fmt.Printf("Saved to: %s\n", outputFile)
-}
\ No newline at end of file
+}
@@ -1,37 +1,71 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
-	"net/http"
+	"os"
+	"os/signal"
 	"runtime"
+	"syscall"
+	"time"
 	"waze/internal/config"
 	"waze/internal/server"
 )
 
+// the time the running requests get to finish on shutdown
+const SHUTDOWN_TIMEOUT = 15 * time.Second
+
 func main() {
-	if err := config.Load("config.json"); err != nil {
-		panic(err)
-	}
-	fmt.Printf("Hello Waze!, map file is: %s\n", config.Global.Server.MapFile)
-	srv := server.NewServer(config.Global.Server.MapFile)
+	loader := config.Setup("server", os.Args[1:])
+	cfg := config.Get()
+	fmt.Printf("Hello Waze!, map file is: %s\n", cfg.Server.MapFile)
 	fmt.Printf("The num of cores is: %d\n", runtime.NumCPU())
 
-	server.WakeWorkers(runtime.NumCPU(), srv.Graph)
-	
-	// הפעלת WebSocket Hub
-	server.GlobalHub = server.NewHub()
-	go server.GlobalHub.Run()
-
-	// API endpoints
-	http.HandleFunc("/api/traffic", srv.HandleTrafficBatch)
-	http.HandleFunc("/api/navigate", srv.HandleNavigation)
-	http.HandleFunc("/ws", srv.HandleWebSocket)
-	
-	// הגשת קבצי GUI סטטיים
-	http.Handle("/", http.FileServer(http.Dir("web")))
-
-	log.Printf("Server running on: %s\n", config.Global.Server.Port)
-	log.Printf("GUI available at: http://localhost%s\n", config.Global.Server.Port)
-	log.Fatal(http.ListenAndServe(config.Global.Server.Port, nil))
-}
\ No newline at end of file
+	srv, err := server.NewServer(server.Options{
+		MapFile:      cfg.Server.MapFile,
+		Config:       loader,
+		Workers:      runtime.NumCPU(),
+		StaticDir:    "web", // הגשת קבצי GUI סטטיים
+		SnapshotFile: cfg.Server.SnapshotFile,
+		GRPCAddr:     cfg.Server.GRPCPort,
+		Auth:         server.AuthOptionsFrom(cfg),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := srv.Start(cfg.Server.Port); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Server running on: %s\n", cfg.Server.Port)
+	log.Printf("GUI available at: http://localhost%s\n", cfg.Server.Port)
+	if addr := srv.GRPCAddr(); addr != nil {
+		log.Printf("gRPC API on: %s\n", addr)
+	}
+
+	// SIGHUP reloads the config and the map
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+	var sig os.Signal
+	for sig == nil {
+		select {
+		case <-reload:
+			if _, err := srv.Reload(); err != nil {
+				log.Printf("Reload failed, keeping the old map: %v", err)
+			}
+		case sig = <-stop:
+		}
+	}
+	log.Printf("Got %v, shutting down", sig)
+
+	ctx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Shutdown error: %v", err)
+	}
+	log.Println("Server stopped")
+}
@@ -4,50 +4,50 @@ import (
 	"fmt"
 	"log"
 	"math/rand"
+	"net/http"
+	"os"
 	"runtime"
 	"time"
 	"waze/internal/config"
 	"waze/internal/graph"
 	"waze/internal/sim"
-	"waze/internal/types"
 )
 
-var CONFIG_FILE string = "config.json"
-
 func main() {
-	if err := config.Load(CONFIG_FILE); err != nil {
-		panic(err)
-	}
+	config.Setup("simulation", os.Args[1:])
 
-	world, err := sim.NewWorld(config.Global.Server.MapFile, (config.Global.Simulation.ServerURL + config.Global.Server.Port))
+	world, err := sim.NewWorld(config.Get().Server.MapFile, config.Get().ServerURL())
 	if err != nil {
 		log.Fatal(err)
 	}
+	world.Client.APIKey = config.Get().Simulation.APIKey
+	if addr := config.Get().Simulation.GRPCAddr; addr != "" {
+		if err := world.Client.UseGRPC(addr); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Sending the reports and the route requests over gRPC to %s\n", addr)
+	}
+	if config.Get().Simulation.ReportStream {
+		world.Client.UseReportStream()
+		log.Println("Sending the reports on a report stream")
+	}
 
 	sim.StartMoveWorkers(runtime.NumCPU())
 
-	numCars := config.Global.Simulation.NumCars
+	if port := config.Get().Simulation.MetricsPort; port != "" {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", world.Metrics.Handler())
+			log.Printf("Simulator metrics at: http://localhost%s/metrics\n", port)
+			log.Println(http.ListenAndServe(port, mux))
+		}()
+	}
 
-	go func() {
-		targetEdgeID := 150
-		fakeCarID := 99999
+	numCars := config.Get().Simulation.NumCars
 
-		for {
-			jamReport := []types.TrafficReport{
-				{
-					CarID:     fakeCarID,
-					EdgeID:    targetEdgeID,
-					Speed:     1.0,
-					Timestamp: time.Now().Unix(),
-				},
-			}
-			err := world.Client.SendTrafficBatch(jamReport)
-			if err != nil {
-				fmt.Printf("Error in jam report %s\n", err)
-			}
-			time.Sleep(2 * time.Second)
-		}
-	}()
+	if jam := config.Get().Simulation.Jam; jam.X != 0 || jam.Y != 0 {
+		go world.Jam(jam.X, jam.Y)
+	}
 
 	fmt.Printf("Initializing %d Cars...\n", numCars)
 	var route []int
@@ -70,6 +70,9 @@ func main() {
 	dt := 1.0
 	loop(numCars, dt, world)
 
+	if err := world.Client.Close(); err != nil {
+		fmt.Printf("Error closing the client: %s\n", err)
+	}
 	fmt.Println("Simulation Finished!")
 	fmt.Printf("total run time: %v\n", time.Since(start))
 }
@@ -92,7 +95,7 @@ func loop(carCounter int, dt float64, world *sim.World) {
 		world.Tick(dt)
 		world.CleanArrivedCars()
 
-		if world.SimTime-lastSpawnTime >= config.Global.Simulation.SpawnRate && world.SimTime < (120.0) {
+		if world.SimTime-lastSpawnTime >= config.Get().Simulation.SpawnRate && world.SimTime < (120.0) {
 			lastSpawnTime = world.SimTime
 			var (
 				src, dst int
@@ -114,6 +117,7 @@ func loop(carCounter int, dt float64, world *sim.World) {
 				newCar := world.AddCar(carCounter, carCounter)
 				newCar.InitRoute(route, world.Graph)
 			} else {
+				world.SpawnFailed()
 				fmt.Println("Skipped spawn: could not find valid route after 3 attempts")
 			}
 		}
@@ -6,6 +6,37 @@ from collections import deque, defaultdict
 INPUT_FILE = '../data/export.osm'
 OUTPUT_FILE = '../data/new_shoham.json'
 DEFAULT_SPEED = 50.0
+CAR_LENGTH_KM = 0.005  # same as car_length_km in config.json
+# merge nodes that only shape a single road into the geometry of its edges
+SIMPLIFY_WAYS = True
+
+# lanes per direction when the way has no lanes tag
+DEFAULT_LANES = {
+    'motorway': 3,
+    'trunk': 2,
+    'primary': 2,
+    'secondary': 1,
+    'tertiary': 1,
+}
+
+def parse_lanes(way, road_type, oneway):
+    lanes_tag = way.find("tag[@k='lanes']")
+    if lanes_tag is not None:
+        try:
+            lanes = int(float(lanes_tag.get('v').split(';')[0]))
+            # the lanes tag counts both directions of a two way road
+            if not oneway:
+                lanes = lanes // 2
+            return max(lanes, 1)
+        except ValueError:
+            pass
+    return DEFAULT_LANES.get(road_type.replace('_link', ''), 1)
+
+def get_tag(way, key):
+    tag = way.find(f"tag[@k='{key}']")
+    if tag is None:
+        return None
+    return tag.get('v')
 
 def haversine_distance(lat1, lon1, lat2, lon2):
     R = 6371.0 
@@ -81,6 +112,33 @@ final_nodes_map = {}
 final_edges = []
 edge_id_counter = 1
 
+def node_id_of(osm_id):
+    global next_node_id
+    if osm_id not in internal_id_map:
+        internal_id_map[osm_id] = next_node_id
+        final_nodes_map[next_node_id] = {'id': next_node_id, **nodes[osm_id]}
+        next_node_id += 1
+    return internal_id_map[osm_id]
+
+# split the node refs of a way into runs of nodes that exist in the file
+def present_runs(nd_refs):
+    runs = []
+    run = []
+    for ref in nd_refs:
+        if ref in nodes:
+            run.append(ref)
+        else:
+            if len(run) > 1:
+                runs.append(run)
+            run = []
+    if len(run) > 1:
+        runs.append(run)
+    return runs
+
+# first pass - collect the drivable ways and count how many ways use each node
+ways = []
+node_usage = defaultdict(int)
+
 for way in root.findall('way'):
     highway = way.find("tag[@k='highway']")
     if highway is None:
@@ -98,6 +156,11 @@ for way in root.findall('way'):
     if oneway_tag is not None and oneway_tag.get('v') == 'yes':
         oneway = True
 
+    # roundabouts are one way even without the tag
+    roundabout = get_tag(way, 'junction') == 'roundabout'
+    if roundabout:
+        oneway = True
+
     speed_limit = 50.0
     maxspeed = way.find("tag[@k='maxspeed']")
     if maxspeed is not None:
@@ -106,51 +169,88 @@ for way in root.findall('way'):
         except:
             pass
 
-    for i in range(len(nd_refs) - 1):
-        u_osm = nd_refs[i]
-        v_osm = nd_refs[i+1]
-        
-        if u_osm not in nodes or v_osm not in nodes:
-            continue
-
-        # Remap IDs
-        if u_osm not in internal_id_map:
-            internal_id_map[u_osm] = next_node_id
-            final_nodes_map[next_node_id] = {'id': next_node_id, **nodes[u_osm]}
-            next_node_id += 1
-        
-        if v_osm not in internal_id_map:
-            internal_id_map[v_osm] = next_node_id
-            final_nodes_map[next_node_id] = {'id': next_node_id, **nodes[v_osm]}
-            next_node_id += 1
-            
-        u_id = internal_id_map[u_osm]
-        v_id = internal_id_map[v_osm]
-
-        dist = haversine_distance(nodes[u_osm]['y'], nodes[u_osm]['x'], 
-                                  nodes[v_osm]['y'], nodes[v_osm]['x'])
-        
-        if dist < 0.001: dist = 0.001
-
-        # Add Edge
-        final_edges.append({
-            "id": edge_id_counter,
-            "from": u_id,
-            "to": v_id,
-            "length": dist,
-            "speedLimit": speed_limit
-        })
-        edge_id_counter += 1
-
-        if not oneway:
-            final_edges.append({
-                "id": edge_id_counter,
-                "from": v_id,
-                "to": u_id,
-                "length": dist,
-                "speedLimit": speed_limit
-            })
-            edge_id_counter += 1
+    runs = present_runs(nd_refs)
+    for run in runs:
+        # the ends of a run are always graph nodes
+        node_usage[run[0]] += 1
+        node_usage[run[-1]] += 1
+        for ref in run:
+            node_usage[ref] += 1
+
+    ways.append({
+        'runs': runs,
+        'road_type': road_type,
+        'oneway': oneway,
+        'speed_limit': speed_limit,
+        'lanes': parse_lanes(way, road_type, oneway),
+        'name': get_tag(way, 'name') or '',
+        'toll': get_tag(way, 'toll') == 'yes',
+        'roundabout': roundabout,
+    })
+
+# a node used only once is a shape point of a single road. it goes into the edge geometry
+def is_graph_node(osm_id):
+    return not SIMPLIFY_WAYS or node_usage[osm_id] > 1
+
+def add_edge(u_id, v_id, dist, geometry, way):
+    global edge_id_counter
+    road_info = {
+        "road_class": way['road_type'],
+        "lanes": way['lanes'],
+        "name": way['name'],
+        "oneway": way['oneway'],
+        "toll": way['toll'],
+        "roundabout": way['roundabout'],
+        "capacity": max(1.0, dist * way['lanes'] / CAR_LENGTH_KM)
+    }
+    edge = {
+        "id": edge_id_counter,
+        "from": u_id,
+        "to": v_id,
+        "length": dist,
+        "speedLimit": way['speed_limit'],
+        **road_info
+    }
+    if geometry:
+        edge["geometry"] = geometry
+    final_edges.append(edge)
+    edge_id_counter += 1
+
+# second pass - every stretch of a way between two graph nodes becomes an edge
+for way in ways:
+    for run in way['runs']:
+        start = 0
+        for i in range(1, len(run)):
+            if i < len(run) - 1 and not is_graph_node(run[i]):
+                continue
+
+            u_osm = run[start]
+            v_osm = run[i]
+            shape = run[start:i + 1]
+            start = i
+
+            # a closed way without other junctions
+            if u_osm == v_osm:
+                continue
+
+            dist = 0.0
+            for a, b in zip(shape, shape[1:]):
+                dist += haversine_distance(nodes[a]['y'], nodes[a]['x'],
+                                           nodes[b]['y'], nodes[b]['x'])
+
+            if dist < 0.001: dist = 0.001
+
+            u_id = node_id_of(u_osm)
+            v_id = node_id_of(v_osm)
+
+            # intermediate points only, the edge ends are the nodes themselves
+            geometry = [[nodes[ref]['x'], nodes[ref]['y']] for ref in shape[1:-1]]
+
+            # Add Edge
+            add_edge(u_id, v_id, dist, geometry, way)
+
+            if not way['oneway']:
+                add_edge(v_id, u_id, dist, geometry[::-1], way)
 
 
 nodes_list = list(final_nodes_map.values())
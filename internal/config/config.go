@@ -1,24 +1,62 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"math"
+	"net"
+	"net/url"
 	"os"
-	"sync"
+	"strconv"
+	"sync/atomic"
 	"time"
 )
 
 type Config struct {
 	Server struct {
-		Port    string `json:"server_port"`
-		MapFile string `json:"map_file"`
+		Port     string `json:"server_port"`
+		GRPCPort string `json:"grpc_port"` // the gRPC API, off when empty
+		MapFile  string `json:"map_file"`
+
+		// the learned speeds are saved there and loaded on start, not saved when empty
+		SnapshotFile string `json:"snapshot_file"`
+
+		Auth struct {
+			KeysFile       string `json:"keys_file"`       // the API keys and their roles, the API is open when empty
+			AnonymousRole  string `json:"anonymous_role"`  // of the requests without a key, "none" refuses them
+			AllowedOrigins string `json:"allowed_origins"` // comma separated pages of other origins that may open /ws, * for any
+		} `json:"auth"`
 	} `json:"server"`
 
 	Simulation struct {
-		ServerURL      string  `json:"server_url"`
+		ServerURL      string  `json:"server_url"` // an http URL without a port gets the port of server_port
 		NumCars        int     `json:"num_cars"`
-		SpawnRate      float64 `json:"spawn_rate"`
-		ReportInterval float64 `json:"report_interval"`
+		SpawnRate      float64 `json:"spawn_rate"`      // seconds between spawns
+		ReportInterval float64 `json:"report_interval"` // whole seconds of simulation time
+		MetricsPort    string  `json:"metrics_port"`    // the /metrics of the simulator, off when empty
+		APIKey         string  `json:"api_key"`         // sent with every request, when the server has keys
+		GRPCAddr       string  `json:"grpc_addr"`       // host:port of the gRPC API, the reports and routes use it instead of HTTP when set
+		ReportStream   bool    `json:"report_stream"`   // the reports go on one WebSocket instead of a POST per batch
+
+		// a fake car crawls on the edge nearest to this point, off when both are 0
+		Jam struct {
+			X float64 `json:"x"` // longitude
+			Y float64 `json:"y"` // latitude
+		} `json:"jam"`
+
+		// emulation of GPS probes instead of perfect edge reports
+		Probes struct {
+			Enabled         bool    `json:"enabled"`
+			PenetrationRate float64 `json:"penetration_rate"` // part of the cars that report (0-1)
+			SampleInterval  float64 `json:"sample_interval"`  // seconds between position fixes
+			PositionNoise   float64 `json:"position_noise"`   // standard deviation in meters
+			SpeedNoise      float64 `json:"speed_noise"`      // standard deviation in KM/hour
+			DropoutRate     float64 `json:"dropout_rate"`     // chance of a fix getting lost (0-1)
+		} `json:"probes"`
 	} `json:"simulation"`
 
 	Physics struct {
@@ -30,22 +68,148 @@ type Config struct {
 	} `json:"physics"`
 }
 
-var (
-	Global Config
-	once   sync.Once
-)
+// Default returns the config a missing value gets its value from
+func Default() *Config {
+	c := &Config{}
+	c.Server.Port = ":8080"
+	c.Server.MapFile = "data/filtered_shoham.json"
+	c.Server.Auth.AnonymousRole = "viewer"
+
+	c.Simulation.ServerURL = "http://localhost"
+	c.Simulation.NumCars = 1000
+	c.Simulation.SpawnRate = 2
+	c.Simulation.ReportInterval = 5
+	c.Simulation.Jam.X = 34.94904
+	c.Simulation.Jam.Y = 32.00028
+	c.Simulation.Probes.PenetrationRate = 0.3
+	c.Simulation.Probes.SampleInterval = 1
+	c.Simulation.Probes.PositionNoise = 8
+	c.Simulation.Probes.SpeedNoise = 3
+	c.Simulation.Probes.DropoutRate = 0.05
+
+	c.Physics.CarLengthKm = 0.005
+	c.Physics.DensityThreshold = 0.85
+	c.Physics.EdgeDensityThreshold = 0.3
+	c.Physics.SpeedFactor = 0.2
+	c.Physics.Alpha = 0.2
+	return c
+}
+
+// the config of the process, swapped as a whole on a reload so a reader never sees half of a new one
+var current atomic.Pointer[Config]
+
+// Get returns the config of the process, the defaults until Set is called.
+// the values of a single call belong together, a caller that reads several values should keep the pointer
+func Get() *Config {
+	if c := current.Load(); c != nil {
+		return c
+	}
+	return defaults
+}
+
+var defaults = Default()
+
+// Set makes c the config of the process
+func Set(c *Config) {
+	current.Store(c)
+}
+
+// Load reads a config file over the defaults, applies the WAZE_ environment variables and validates it
+func Load(filename string) (*Config, error) {
+	return (&Loader{File: filename}).Load()
+}
 
-func Load(filename string) error {
-	var err error
-	once.Do(func() {
-		data, e := os.ReadFile(filename)
-		if e != nil {
-			err = e
-			return
+// reads the file over c. fields that are not in Config are errors, they are usually typos
+func (c *Config) readFile(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	return nil
+}
+
+// Validate returns all the values that are out of their range
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, key string, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s %s", key, fmt.Sprintf(format, args...)))
 		}
-		err = json.Unmarshal(data, &Global)
-	})
-	return err
+	}
+
+	check(validAddr(c.Server.Port), "server.server_port", "must be [host]:port, got %q", c.Server.Port)
+	check(c.Server.GRPCPort == "" || validAddr(c.Server.GRPCPort), "server.grpc_port", "must be empty or [host]:port, got %q", c.Server.GRPCPort)
+	check(c.Server.MapFile != "", "server.map_file", "is required")
+
+	sim := c.Simulation
+	if u, err := url.Parse(sim.ServerURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		check(false, "simulation.server_url", "must be an http or https URL, got %q", sim.ServerURL)
+	}
+	check(sim.NumCars >= 0, "simulation.num_cars", "must not be negative, got %d", sim.NumCars)
+	check(sim.SpawnRate > 0, "simulation.spawn_rate", "must be positive, got %g", sim.SpawnRate)
+	// the simulation sends its reports every this many whole ticks
+	check(sim.ReportInterval >= 1 && sim.ReportInterval <= math.MaxInt32 && sim.ReportInterval == math.Trunc(sim.ReportInterval),
+		"simulation.report_interval", "must be a whole number of seconds between 1 and %d, got %g", math.MaxInt32, sim.ReportInterval)
+	check(sim.MetricsPort == "" || validAddr(sim.MetricsPort), "simulation.metrics_port", "must be empty or [host]:port, got %q", sim.MetricsPort)
+	check(sim.GRPCAddr == "" || validAddr(sim.GRPCAddr), "simulation.grpc_addr", "must be empty or host:port, got %q", sim.GRPCAddr)
+	check(!sim.ReportStream || sim.GRPCAddr == "", "simulation.report_stream", "can't be used with simulation.grpc_addr, the reports go over gRPC then")
+
+	check(between(sim.Jam.X, -180, 180), "simulation.jam.x", "must be a longitude, got %g", sim.Jam.X)
+	check(between(sim.Jam.Y, -90, 90), "simulation.jam.y", "must be a latitude, got %g", sim.Jam.Y)
+
+	probes := sim.Probes
+	check(between(probes.PenetrationRate, 0, 1), "simulation.probes.penetration_rate", "must be between 0 and 1, got %g", probes.PenetrationRate)
+	check(probes.SampleInterval > 0, "simulation.probes.sample_interval", "must be positive, got %g", probes.SampleInterval)
+	check(probes.PositionNoise >= 0, "simulation.probes.position_noise", "must not be negative, got %g", probes.PositionNoise)
+	check(probes.SpeedNoise >= 0, "simulation.probes.speed_noise", "must not be negative, got %g", probes.SpeedNoise)
+	check(probes.DropoutRate >= 0 && probes.DropoutRate < 1, "simulation.probes.dropout_rate", "must be at least 0 and below 1, got %g", probes.DropoutRate)
+
+	physics := c.Physics
+	check(physics.CarLengthKm > 0, "physics.car_length_km", "must be positive, got %g", physics.CarLengthKm)
+	check(between(physics.DensityThreshold, 0, 1), "physics.density_threshold", "must be between 0 and 1, got %g", physics.DensityThreshold)
+	check(between(physics.EdgeDensityThreshold, 0, 1), "physics.edge_density", "must be between 0 and 1, got %g", physics.EdgeDensityThreshold)
+	check(between(physics.SpeedFactor, 0, 1), "physics.speed_factor", "must be between 0 and 1, got %g", physics.SpeedFactor)
+	check(physics.Alpha > 0 && physics.Alpha <= 1, "physics.alpha", "must be above 0 and at most 1, got %g", physics.Alpha)
+
+	return errors.Join(errs...)
+}
+
+func between(v, low, high float64) bool {
+	return v >= low && v <= high
+}
+
+// host:port or :port, as net.Listen takes it
+func validAddr(addr string) bool {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	n, err := strconv.Atoi(port)
+	return err == nil && n >= 0 && n <= 65535
+}
+
+// ServerURL returns the URL the simulator sends to. an http URL without a port gets the port of the server
+func (c *Config) ServerURL() string {
+	u, err := url.Parse(c.Simulation.ServerURL)
+	if err != nil || u.Scheme != "http" || u.Port() != "" {
+		return c.Simulation.ServerURL
+	}
+	if _, port, err := net.SplitHostPort(c.Server.Port); err == nil && port != "" {
+		u.Host = net.JoinHostPort(u.Hostname(), port)
+	}
+	return u.String()
+}
+
+// Print writes the config as JSON
+func (c *Config) Print(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(c)
 }
 
 func TimeTrack(start time.Time, name string) {
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// the prefix of the environment variables that override the config file
+const ENV_PREFIX = "WAZE_"
+
+// Loader builds the config of a command. every value is the first of: a flag, an environment variable,
+// the config file and the default. it is kept, so a reload reads the file again with the same flags
+type Loader struct {
+	File  string
+	Print bool // -print-config, the command prints the config and exits
+
+	flags map[string]string // by key, the flags that were set
+}
+
+// Parse reads the flags of a command. every value of the config has a flag named by its key,
+// like -simulation.num_cars, and there are -config and -print-config
+func Parse(name string, args []string) (*Loader, error) {
+	l := &Loader{flags: make(map[string]string)}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&l.File, "config", "config.json", "the config file")
+	fs.BoolVar(&l.Print, "print-config", false, "print the config, after the environment and the flags, and exit")
+	for _, f := range fields(Default()) {
+		key := f.key
+		value := f.value
+		usage := fmt.Sprintf("overrides %s (env %s)", key, envName(key))
+		if !value.IsZero() {
+			usage = fmt.Sprintf("overrides %s (env %s, default %v)", key, envName(key), value.Interface())
+		}
+		parse := func(s string) error {
+			// checked now, applied in Load
+			if err := set(reflect.New(value.Type()).Elem(), s); err != nil {
+				return err
+			}
+			l.flags[key] = s
+			return nil
+		}
+		if value.Kind() == reflect.Bool {
+			// -simulation.probes.enabled without a value
+			fs.BoolFunc(key, usage, parse)
+		} else {
+			fs.Func(key, usage, parse)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	return l, nil
+}
+
+// Load builds a validated config: the defaults, the file, the environment and the flags
+func (l *Loader) Load() (*Config, error) {
+	c := Default()
+	if err := c.readFile(l.File); err != nil {
+		return nil, err
+	}
+	for _, f := range fields(c) {
+		if s, ok := os.LookupEnv(envName(f.key)); ok {
+			if err := set(f.value, s); err != nil {
+				return nil, fmt.Errorf("%s: %w", envName(f.key), err)
+			}
+		}
+		if s, ok := l.flags[f.key]; ok {
+			if err := set(f.value, s); err != nil {
+				return nil, fmt.Errorf("-%s: %w", f.key, err)
+			}
+		}
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s:\n%w", l.File, err)
+	}
+	return c, nil
+}
+
+// a value of the config and its key, the JSON names of its fields joined by dots
+type field struct {
+	key   string
+	value reflect.Value
+}
+
+func fields(c *Config) []field {
+	return appendFields(nil, "", reflect.ValueOf(c).Elem())
+}
+
+func appendFields(list []field, prefix string, v reflect.Value) []field {
+	for i := 0; i < v.NumField(); i++ {
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+		key := prefix + name
+		if v.Field(i).Kind() == reflect.Struct {
+			list = appendFields(list, key+".", v.Field(i))
+		} else {
+			list = append(list, field{key: key, value: v.Field(i)})
+		}
+	}
+	return list
+}
+
+// WAZE_ and the key in upper case, simulation.num_cars is WAZE_SIMULATION_NUM_CARS
+func envName(key string) string {
+	return ENV_PREFIX + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+func set(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", s)
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", s)
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", s)
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("values of type %s can't be set", v.Type())
+	}
+	return nil
+}
+
+// Setup loads the config of a command from its args and makes it the config of the process.
+// like flag.Parse it exits on an error, and it exits after printing the config with -print-config
+func Setup(name string, args []string) *Loader {
+	l, err := Parse(name, args)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		// the flag package already printed the error with the usage
+		os.Exit(2)
+	}
+	c, err := l.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if l.Print {
+		if err := c.Print(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	Set(c)
+	return l
+}
@@ -3,9 +3,19 @@ package graph
 import (
 	"math"
 	"sync/atomic"
+	"time"
 	"waze/internal/config"
 )
 
+// the current speed of an edge never goes above its speed limit times this factor
+const MaxSpeedFactor float64 = 1.5
+
+// the confidence in the current speed, after this many reports it is about 2/3
+const CONFIDENCE_REPORTS = 3
+
+// the confidence in the current speed drops to about 1/3 when it is this old
+const CONFIDENCE_DECAY = 5 * time.Minute
+
 type Edge struct {
 	Id         int     `json:"id"`
 	From       int     `json:"from"`
@@ -13,7 +23,22 @@ type Edge struct {
 	Length     float64 `json:"length"`     // in KM
 	SpeedLimit float64 `json:"speedlimit"` // in KM/hour
 
+	// optional road metadata, filled by the OSM conversion
+	RoadClass  RoadClass `json:"road_class,omitempty"`
+	Lanes      int       `json:"lanes,omitempty"` // in the direction of the edge
+	Name       string    `json:"name,omitempty"`
+	OneWay     bool      `json:"oneway,omitempty"`
+	Toll       bool      `json:"toll,omitempty"`
+	Roundabout bool      `json:"roundabout,omitempty"`
+	Capacity   float64   `json:"capacity,omitempty"` // max number of cars on the edge
+
+	// optional [x, y] points between the From and To nodes, for curved roads
+	Geometry [][2]float64 `json:"geometry,omitempty"`
+
 	currentSpeed uint64 // in KM per hour
+	lastUpdate   int64  // unix nano of the last report, 0 when never reported
+	reports      uint64 // number of reports applied to the speed
+	override     uint64 // a speed set by an operator in KM per hour, 0 when none
 }
 
 /*
@@ -34,12 +59,17 @@ func (e *Edge) SetCurrentSpeed(speed float64) {
 }
 
 func (e *Edge) UpdateSpeed(measuredSpeed float64) {
+	e.UpdateSpeedWeighted(measuredSpeed, 1)
+}
+
+// UpdateSpeedWeighted is UpdateSpeed for a report that is trusted by weight (0-1), it moves the speed that much less
+func (e *Edge) UpdateSpeedWeighted(measuredSpeed, weight float64) {
 	// check for negative time
-	if measuredSpeed <= 0 {
+	if measuredSpeed <= 0 || weight <= 0 {
 		return
 	}
 
-	alpha := config.Global.Physics.Alpha
+	alpha := config.Get().Physics.Alpha * min(weight, 1)
 
 	for {
 		oldBits := atomic.LoadUint64(&e.currentSpeed)
@@ -59,8 +89,8 @@ func (e *Edge) UpdateSpeed(measuredSpeed float64) {
 		newSpeed := e.Length / updateTime
 
 		// check new speed boundries. not much more then speed limit
-		if newSpeed > (e.SpeedLimit * 1.5) {
-			newSpeed = e.SpeedLimit * 1.5
+		if newSpeed > (e.SpeedLimit * MaxSpeedFactor) {
+			newSpeed = e.SpeedLimit * MaxSpeedFactor
 		}
 		if newSpeed < 1 {
 			newSpeed = 1
@@ -69,7 +99,67 @@ func (e *Edge) UpdateSpeed(measuredSpeed float64) {
 		newBits := math.Float64bits(newSpeed)
 
 		if atomic.CompareAndSwapUint64(&e.currentSpeed, oldBits, newBits) {
+			atomic.AddUint64(&e.reports, 1)
+			atomic.StoreInt64(&e.lastUpdate, time.Now().UnixNano())
 			return
 		}
 	}
 }
+
+// SetOverride makes the routing use speed instead of the reported speed until ClearOverride.
+// the reports keep updating the current speed under it
+func (e *Edge) SetOverride(speed float64) {
+	atomic.StoreUint64(&e.override, math.Float64bits(speed))
+}
+
+func (e *Edge) ClearOverride() {
+	atomic.StoreUint64(&e.override, 0)
+}
+
+// Override returns the speed set by SetOverride, 0 when there is none
+func (e *Edge) Override() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&e.override))
+}
+
+// RoutingSpeed returns the speed the routing uses: the override when there is one, the current speed otherwise
+func (e *Edge) RoutingSpeed() float64 {
+	if speed := e.Override(); speed > 0 {
+		return speed
+	}
+	return e.GetCurrentSpeed()
+}
+
+// LastUpdate returns the time of the last report on the edge, the zero time when there was none
+func (e *Edge) LastUpdate() time.Time {
+	nanos := atomic.LoadInt64(&e.lastUpdate)
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+func (e *Edge) ReportCount() uint64 {
+	return atomic.LoadUint64(&e.reports)
+}
+
+// Restore sets the speed and the report history of the edge, as they were saved before a restart
+func (e *Edge) Restore(speed float64, reports uint64, lastUpdate time.Time) {
+	e.SetCurrentSpeed(speed)
+	atomic.StoreUint64(&e.reports, reports)
+	nanos := int64(0)
+	if !lastUpdate.IsZero() {
+		nanos = lastUpdate.UnixNano()
+	}
+	atomic.StoreInt64(&e.lastUpdate, nanos)
+}
+
+// Confidence returns how much the current speed can be trusted, 0-1.
+// it grows with the number of reports and decays as the last report gets older
+func (e *Edge) Confidence(now time.Time) float64 {
+	reports := e.ReportCount()
+	if reports == 0 {
+		return 0
+	}
+	age := now.Sub(e.LastUpdate())
+	return (1 - math.Exp(-float64(reports)/CONFIDENCE_REPORTS)) * math.Exp(-max(age, 0).Seconds()/CONFIDENCE_DECAY.Seconds())
+}
@@ -0,0 +1,86 @@
+package graph
+
+import "math"
+
+// EdgePoints returns the full polyline of the edge as [x, y] pairs,
+// from its source node, through its geometry, to its destination node
+func (g *Graph) EdgePoints(e *Edge) [][2]float64 {
+	fromNode := g.Nodes[e.From]
+	toNode := g.Nodes[e.To]
+
+	points := make([][2]float64, 0, len(e.Geometry)+2)
+	points = append(points, [2]float64{fromNode.X, fromNode.Y})
+	points = append(points, e.Geometry...)
+	points = append(points, [2]float64{toNode.X, toNode.Y})
+	return points
+}
+
+// RoutePoints returns the polyline of a whole route of edge ids
+func (g *Graph) RoutePoints(route []int) [][2]float64 {
+	points := make([][2]float64, 0)
+	for _, edgeId := range route {
+		edge, exists := g.Edges[edgeId]
+		if !exists {
+			continue
+		}
+		edgePoints := g.EdgePoints(edge)
+		// the first point is the last point of the previous edge
+		if len(points) > 0 {
+			edgePoints = edgePoints[1:]
+		}
+		points = append(points, edgePoints...)
+	}
+	return points
+}
+
+// PositionOnEdge returns the point at the given progress (0-1 of the edge length) along the edge polyline,
+// and the heading there in degrees clockwise from north (the y axis)
+func (g *Graph) PositionOnEdge(e *Edge, progress float64) (x, y, heading float64) {
+	points := g.EdgePoints(e)
+	progress = math.Max(0, math.Min(1, progress))
+
+	// the length of every segment and of the whole polyline
+	segments := make([]float64, len(points)-1)
+	total := 0.0
+	for i := range segments {
+		segments[i] = math.Hypot(points[i+1][0]-points[i][0], points[i+1][1]-points[i][1])
+		total += segments[i]
+	}
+
+	// walk the segments until reaching the target distance
+	target := progress * total
+	for i, length := range segments {
+		last := i == len(segments)-1
+		if target > length && !last {
+			target -= length
+			continue
+		}
+		t := 0.0
+		if length > 0 {
+			t = math.Min(1, target/length)
+		}
+		dx := points[i+1][0] - points[i][0]
+		dy := points[i+1][1] - points[i][1]
+		return points[i][0] + dx*t, points[i][1] + dy*t, headingOf(dx, dy)
+	}
+	return points[0][0], points[0][1], 0
+}
+
+// degrees clockwise from north, in [0, 360)
+func headingOf(dx, dy float64) float64 {
+	heading := math.Atan2(dx, dy) * 180 / math.Pi
+	if heading < 0 {
+		heading += 360
+	}
+	return heading
+}
+
+// EdgeHeadings returns the heading at the start and at the end of the edge polyline,
+// in degrees clockwise from north
+func (g *Graph) EdgeHeadings(e *Edge) (start, end float64) {
+	points := g.EdgePoints(e)
+	n := len(points)
+	start = headingOf(points[1][0]-points[0][0], points[1][1]-points[0][1])
+	end = headingOf(points[n-1][0]-points[n-2][0], points[n-1][1]-points[n-2][1])
+	return start, end
+}
@@ -2,16 +2,21 @@ package graph
 
 import (
 	"fmt"
+	"math"
 	"sort"
 	"strings"
 )
 
 type Graph struct {
-	Nodes    map[int]*Node
-	Edges    map[int]*Edge
-	NodesArr []int
+	Nodes          map[int]*Node
+	Edges          map[int]*Edge
+	NodesArr       []int
 	AdjList        map[int][]*Edge
 	ReverseAdjList map[int][]*Edge
+
+	// lower bounds used by the A* heuristics, updated on every AddEdge
+	kmPerUnit     float64 // smallest ratio between edge length and the coordinate distance of its nodes
+	maxSpeedLimit float64 // highest speed limit in the graph
 }
 
 func NewGraph() *Graph {
@@ -71,9 +76,32 @@ func (g *Graph) AddEdge(e *Edge) error {
 	g.AdjList[e.From] = append(g.AdjList[e.From], e)
 	g.ReverseAdjList[e.To] = append(g.ReverseAdjList[e.To], e)
 
+	// keep the heuristic bounds valid for the new edge
+	if e.SpeedLimit > g.maxSpeedLimit {
+		g.maxSpeedLimit = e.SpeedLimit
+	}
+	if dist := g.Nodes[e.From].DistanceTo(g.Nodes[e.To]); dist > 0 {
+		ratio := e.Length / dist
+		if g.kmPerUnit == 0 || ratio < g.kmPerUnit {
+			g.kmPerUnit = ratio
+		}
+	}
+
 	return nil
 }
 
+// KmPerUnit returns a factor that turns a coordinate distance into a lower bound in KM.
+// every edge is at least this long relative to the straight line between its nodes,
+// so no path between two nodes can be shorter than their coordinate distance times it
+func (g *Graph) KmPerUnit() float64 {
+	return g.kmPerUnit
+}
+
+// MaxSpeed returns the highest speed a car can be estimated to drive in the graph (KM/hour)
+func (g *Graph) MaxSpeed() float64 {
+	return math.Max(g.maxSpeedLimit, 1) * MaxSpeedFactor
+}
+
 func (g *Graph) GetNeighbors(nodeId int) []*Edge {
 	return g.AdjList[nodeId]
-}
\ No newline at end of file
+}
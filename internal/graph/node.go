@@ -1,8 +1,15 @@
 package graph
 
+import "math"
+
 type Node struct {
 	Id   int     `json:"id"`
 	Name string  `json:"name"`
 	X    float64 `json:"x"`
 	Y    float64 `json:"y"`
 }
+
+// straight line distance between two nodes, in coordinate units
+func (n *Node) DistanceTo(other *Node) float64 {
+	return math.Hypot(n.X-other.X, n.Y-other.Y)
+}
@@ -0,0 +1,85 @@
+package graph
+
+import "strings"
+
+// RoadClass is the OSM highway type of a road
+type RoadClass string
+
+const (
+	Motorway     RoadClass = "motorway"
+	Trunk        RoadClass = "trunk"
+	Primary      RoadClass = "primary"
+	Secondary    RoadClass = "secondary"
+	Tertiary     RoadClass = "tertiary"
+	Unclassified RoadClass = "unclassified"
+	Residential  RoadClass = "residential"
+	LivingStreet RoadClass = "living_street"
+	Service      RoadClass = "service"
+)
+
+// all the road classes, without the links
+var RoadClasses = []RoadClass{Motorway, Trunk, Primary, Secondary, Tertiary, Unclassified, Residential, LivingStreet, Service}
+
+// lanes per direction when the map doesn't say
+var defaultLanes = map[RoadClass]int{
+	Motorway:  3,
+	Trunk:     2,
+	Primary:   2,
+	Secondary: 1,
+	Tertiary:  1,
+}
+
+// Base returns the class without the "_link" suffix, so a motorway ramp is a motorway
+func (c RoadClass) Base() RoadClass {
+	return RoadClass(strings.TrimSuffix(string(c), "_link"))
+}
+
+func (c RoadClass) IsHighway() bool {
+	base := c.Base()
+	return base == Motorway || base == Trunk
+}
+
+// Class returns the road class of the edge. maps without road classes get one guessed by the speed limit
+func (e *Edge) Class() RoadClass {
+	if e.RoadClass != "" {
+		return e.RoadClass
+	}
+	switch {
+	case e.SpeedLimit >= 100:
+		return Motorway
+	case e.SpeedLimit >= 80:
+		return Trunk
+	case e.SpeedLimit >= 60:
+		return Primary
+	default:
+		return Residential
+	}
+}
+
+func (e *Edge) IsHighway() bool {
+	return e.Class().IsHighway()
+}
+
+// LaneCount returns the number of lanes in the direction of the edge
+func (e *Edge) LaneCount() int {
+	if e.Lanes > 0 {
+		return e.Lanes
+	}
+	if lanes, ok := defaultLanes[e.Class().Base()]; ok {
+		return lanes
+	}
+	return 1
+}
+
+// CarCapacity returns how many cars fit on the edge at the same time.
+// an explicit capacity from the map wins, otherwise it is calculated from the length and the lanes
+func (e *Edge) CarCapacity(carLengthKm float64) float64 {
+	capacity := e.Capacity
+	if capacity <= 0 && carLengthKm > 0 {
+		capacity = e.Length * float64(e.LaneCount()) / carLengthKm
+	}
+	if capacity < 1 {
+		capacity = 1
+	}
+	return capacity
+}
@@ -0,0 +1,81 @@
+package matching
+
+import (
+	"container/heap"
+	"waze/internal/graph"
+)
+
+// result of a bounded shortest path search (by length) from a single node
+type searchResult struct {
+	dist     map[int]float64
+	prevEdge map[int]*graph.Edge
+}
+
+type queueItem struct {
+	nodeId int
+	dist   float64
+}
+
+type distQueue []queueItem
+
+func (q distQueue) Len() int            { return len(q) }
+func (q distQueue) Less(i, j int) bool  { return q[i].dist < q[j].dist }
+func (q distQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *distQueue) Push(x interface{}) { *q = append(*q, x.(queueItem)) }
+func (q *distQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	*q = old[:n-1]
+	return item
+}
+
+// shortest distances in KM from srcId to every node closer than maxDist
+func boundedSearch(g *graph.Graph, srcId int, maxDist float64) *searchResult {
+	res := &searchResult{
+		dist:     map[int]float64{srcId: 0},
+		prevEdge: make(map[int]*graph.Edge),
+	}
+	closed := make(map[int]bool)
+
+	q := &distQueue{{nodeId: srcId, dist: 0}}
+	for q.Len() > 0 {
+		current := heap.Pop(q).(queueItem)
+		u := current.nodeId
+		if closed[u] {
+			continue
+		}
+		closed[u] = true
+
+		for _, edge := range g.GetNeighbors(u) {
+			newDist := current.dist + edge.Length
+			if newDist > maxDist {
+				continue
+			}
+			if old, exists := res.dist[edge.To]; !exists || newDist < old {
+				res.dist[edge.To] = newDist
+				res.prevEdge[edge.To] = edge
+				heap.Push(q, queueItem{nodeId: edge.To, dist: newDist})
+			}
+		}
+	}
+	return res
+}
+
+// the edges from the search source to dstId, in driving order
+func (res *searchResult) pathTo(dstId int) []*graph.Edge {
+	path := make([]*graph.Edge, 0)
+	for {
+		edge, ok := res.prevEdge[dstId]
+		if !ok {
+			break
+		}
+		path = append(path, edge)
+		dstId = edge.From
+	}
+	// reverse
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
@@ -0,0 +1,377 @@
+// Package matching matches noisy GPS traces to the edges of the map,
+// using a hidden markov model solved with the Viterbi algorithm
+package matching
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"waze/internal/graph"
+	"waze/internal/spatial"
+	"waze/internal/types"
+)
+
+// how far back on the same edge (in GPS sigmas) a fix may be and still count as not moving
+const BACKWARD_NOISE = 3
+
+type Params struct {
+	SearchRadius  float64 // KM, edges farther than this from a fix are not candidates
+	GPSSigma      float64 // KM, standard deviation of the GPS noise
+	Beta          float64 // KM, how much the driven distance usually differs from the straight distance
+	MaxCandidates int     // candidates kept per fix, the closest ones
+	MaxDetour     float64 // the driven distance between fixes is at most this times the straight distance
+	MinMove       float64 // KM, fixes closer than this to the previous fix are skipped
+}
+
+var DefaultParams = Params{
+	SearchRadius:  0.05,
+	GPSSigma:      0.02,
+	Beta:          0.05,
+	MaxCandidates: 8,
+	MaxDetour:     3,
+	MinMove:       0.04,
+}
+
+type Matcher struct {
+	Graph  *graph.Graph
+	Index  *spatial.EdgeIndex
+	Proj   spatial.Projection
+	Params Params
+}
+
+func NewMatcher(g *graph.Graph, params Params) *Matcher {
+	return &Matcher{
+		Graph:  g,
+		Index:  spatial.NewEdgeIndex(g),
+		Proj:   spatial.ProjectionFor(g),
+		Params: params,
+	}
+}
+
+// a fix matched to the road
+type MatchedEdge struct {
+	EdgeID    int     `json:"edge_id"`
+	EntryTime float64 `json:"entry_time"` // unix seconds
+	ExitTime  float64 `json:"exit_time"`
+	Distance  float64 `json:"distance"` // KM driven on the edge inside the trace
+	Speed     float64 `json:"speed"`    // KM/hour, 0 when the time on the edge is unknown
+}
+
+// a possible position of a fix on an edge
+type candidate struct {
+	edge   *graph.Edge
+	offset float64 // KM from the start of the edge
+	dist   float64 // KM from the fix
+}
+
+// a single step of the Viterbi algorithm
+type layer struct {
+	point      types.GPSPoint
+	candidates []candidate
+	score      []float64       // best log probability of ending at each candidate
+	back       []int           // the previous candidate of the best path, -1 when the path starts here
+	path       [][]*graph.Edge // the full edges driven from the previous candidate
+}
+
+// Match returns the edges driven by the trace, in order, with the time the car entered and left each one.
+// fixes without any edge nearby are ignored, and the trace is split where two fixes can't be connected
+func (m *Matcher) Match(points []types.GPSPoint) ([]MatchedEdge, error) {
+	sorted := make([]types.GPSPoint, len(points))
+	copy(sorted, points)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Timestamp < sorted[j].Timestamp })
+
+	layers := make([]*layer, 0, len(sorted))
+	for i, point := range sorted {
+		// fixes too close to the previous one only add noise (the car may even seem to drive backwards).
+		// the last fix is always kept so the trace covers its whole time
+		if n := len(layers); n > 0 && i < len(sorted)-1 {
+			prev := layers[n-1].point
+			if m.Proj.Distance(prev.X, prev.Y, point.X, point.Y) < m.Params.MinMove {
+				continue
+			}
+		}
+
+		candidates := m.findCandidates(point)
+		if len(candidates) == 0 {
+			continue
+		}
+		current := &layer{
+			point:      point,
+			candidates: candidates,
+			score:      make([]float64, len(candidates)),
+			back:       make([]int, len(candidates)),
+			path:       make([][]*graph.Edge, len(candidates)),
+		}
+		if len(layers) == 0 {
+			m.startLayer(current)
+		} else {
+			m.stepLayer(layers[len(layers)-1], current)
+		}
+		layers = append(layers, current)
+	}
+
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("no fix of the trace is near a road")
+	}
+	return m.buildEdges(layers), nil
+}
+
+func (m *Matcher) findCandidates(point types.GPSPoint) []candidate {
+	radius := m.Params.SearchRadius
+	box := spatial.BoxAround(point.X, point.Y, m.Proj.DegreesFor(radius))
+
+	candidates := make([]candidate, 0)
+	for _, edge := range m.Index.InBox(box) {
+		dist, along, total := m.Proj.ClosestOnPolyline(m.Graph.EdgePoints(edge), point.X, point.Y)
+		if dist > radius {
+			continue
+		}
+		// the offset is measured in the edge length, which may differ a bit from the drawn polyline
+		offset := 0.0
+		if total > 0 {
+			offset = along / total * edge.Length
+		}
+		candidates = append(candidates, candidate{edge: edge, offset: offset, dist: dist})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].dist < candidates[j].dist })
+	if len(candidates) > m.Params.MaxCandidates {
+		candidates = candidates[:m.Params.MaxCandidates]
+	}
+	return candidates
+}
+
+// ProgressOn returns where the fix is along the edge, 0-1 of its length
+func (m *Matcher) ProgressOn(edgeID int, point types.GPSPoint) float64 {
+	edge, exists := m.Graph.Edges[edgeID]
+	if !exists {
+		return 0
+	}
+	_, along, total := m.Proj.ClosestOnPolyline(m.Graph.EdgePoints(edge), point.X, point.Y)
+	return fraction(along, total)
+}
+
+// log probability of measuring the fix when the car is at the candidate
+func (m *Matcher) emission(c candidate) float64 {
+	z := c.dist / m.Params.GPSSigma
+	return -0.5 * z * z
+}
+
+// log probability of driving between two candidates, by how much the driven distance is longer than the straight one
+func (m *Matcher) transition(routeDist, straightDist float64) float64 {
+	return -math.Abs(routeDist-straightDist) / m.Params.Beta
+}
+
+func (m *Matcher) startLayer(current *layer) {
+	for i, c := range current.candidates {
+		current.score[i] = m.emission(c)
+		current.back[i] = -1
+	}
+}
+
+func (m *Matcher) stepLayer(prev, current *layer) {
+	straight := m.Proj.Distance(prev.point.X, prev.point.Y, current.point.X, current.point.Y)
+	maxDist := straight*m.Params.MaxDetour + 2*m.Params.SearchRadius
+
+	// a single search from the end of every previous edge
+	searches := make(map[int]*searchResult)
+
+	connected := false
+	for j, to := range current.candidates {
+		current.score[j] = math.Inf(-1)
+		current.back[j] = -1
+
+		for i, from := range prev.candidates {
+			if math.IsInf(prev.score[i], -1) {
+				continue
+			}
+			routeDist, path, ok := m.routeBetween(from, to, maxDist, searches)
+			if !ok {
+				continue
+			}
+			score := prev.score[i] + m.transition(routeDist, straight) + m.emission(to)
+			if score > current.score[j] {
+				current.score[j] = score
+				current.back[j] = i
+				current.path[j] = path
+			}
+		}
+		if current.back[j] != -1 {
+			connected = true
+		}
+	}
+
+	// no way to get here from the previous fix. the trace breaks and starts again
+	if !connected {
+		m.startLayer(current)
+	}
+}
+
+// the driven distance between two candidates and the full edges driven between them
+func (m *Matcher) routeBetween(from, to candidate, maxDist float64, searches map[int]*searchResult) (float64, []*graph.Edge, bool) {
+	if m.sameEdgeForward(from, to) {
+		return math.Max(0, to.offset-from.offset), nil, true
+	}
+
+	search, ok := searches[from.edge.To]
+	if !ok {
+		search = boundedSearch(m.Graph, from.edge.To, maxDist)
+		searches[from.edge.To] = search
+	}
+	between, ok := search.dist[to.edge.From]
+	if !ok {
+		return 0, nil, false
+	}
+
+	routeDist := (from.edge.Length - from.offset) + between + to.offset
+	if routeDist > maxDist {
+		return 0, nil, false
+	}
+	return routeDist, search.pathTo(to.edge.From), true
+}
+
+// true when the car stayed on the same edge. moving back a little is just GPS noise of a slow car,
+// not a drive around the block
+func (m *Matcher) sameEdgeForward(from, to candidate) bool {
+	return from.edge.Id == to.edge.Id && to.offset >= from.offset-BACKWARD_NOISE*m.Params.GPSSigma
+}
+
+// a part of an edge driven between two fixes
+type piece struct {
+	edge     *graph.Edge
+	distance float64
+	start    float64
+	end      float64
+}
+
+// backtrack the best path and turn it into timed edges
+func (m *Matcher) buildEdges(layers []*layer) []MatchedEdge {
+	// the chosen candidate of every layer, from the last layer back
+	chosen := make([]int, len(layers))
+	chosen[len(layers)-1] = argmax(layers[len(layers)-1].score)
+	for i := len(layers) - 1; i > 0; i-- {
+		back := layers[i].back[chosen[i]]
+		if back == -1 {
+			back = argmax(layers[i-1].score)
+		}
+		chosen[i-1] = back
+	}
+
+	pieces := make([]piece, 0)
+	first := layers[0].candidates[chosen[0]]
+	startTime := float64(layers[0].point.Timestamp)
+	pieces = append(pieces, piece{edge: first.edge, start: startTime, end: startTime})
+
+	for i := 1; i < len(layers); i++ {
+		from := layers[i-1].candidates[chosen[i-1]]
+		to := layers[i].candidates[chosen[i]]
+		t1 := float64(layers[i-1].point.Timestamp)
+		t2 := float64(layers[i].point.Timestamp)
+
+		// a break in the trace. nothing is known about what happened in between
+		if layers[i].back[chosen[i]] == -1 {
+			pieces = append(pieces, piece{edge: to.edge, start: t2, end: t2})
+			continue
+		}
+
+		var driven []piece
+		if m.sameEdgeForward(from, to) {
+			driven = []piece{{edge: to.edge, distance: math.Max(0, to.offset-from.offset)}}
+		} else {
+			driven = append(driven, piece{edge: from.edge, distance: from.edge.Length - from.offset})
+			for _, edge := range layers[i].path[chosen[i]] {
+				driven = append(driven, piece{edge: edge, distance: edge.Length})
+			}
+			driven = append(driven, piece{edge: to.edge, distance: to.offset})
+		}
+
+		// the time between the fixes is split by the driven distance
+		total := 0.0
+		for _, p := range driven {
+			total += p.distance
+		}
+		done := 0.0
+		for _, p := range driven {
+			p.start = t1 + (t2-t1)*fraction(done, total)
+			done += p.distance
+			p.end = t1 + (t2-t1)*fraction(done, total)
+			pieces = append(pieces, p)
+		}
+	}
+
+	edges := mergePieces(pieces)
+
+	// an edge seen for no time at all gets the speed the probe measured on it
+	fixSpeeds := make(map[int][]float64)
+	for i, l := range layers {
+		if l.point.Speed > 0 {
+			edgeId := l.candidates[chosen[i]].edge.Id
+			fixSpeeds[edgeId] = append(fixSpeeds[edgeId], l.point.Speed)
+		}
+	}
+	for i := range edges {
+		speeds := fixSpeeds[edges[i].EdgeID]
+		if edges[i].ExitTime > edges[i].EntryTime || len(speeds) == 0 {
+			continue
+		}
+		sum := 0.0
+		for _, speed := range speeds {
+			sum += speed
+		}
+		edges[i].Speed = sum / float64(len(speeds))
+	}
+	return edges
+}
+
+// join consecutive pieces of the same edge
+func mergePieces(pieces []piece) []MatchedEdge {
+	result := make([]MatchedEdge, 0)
+	for _, p := range pieces {
+		if n := len(result); n > 0 && result[n-1].EdgeID == p.edge.Id {
+			result[n-1].ExitTime = p.end
+			result[n-1].Distance += p.distance
+			continue
+		}
+		result = append(result, MatchedEdge{
+			EdgeID:    p.edge.Id,
+			EntryTime: p.start,
+			ExitTime:  p.end,
+			Distance:  p.distance,
+		})
+	}
+
+	// a fix right on a junction touches the edges around it without driving on them
+	driven := make([]MatchedEdge, 0, len(result))
+	for _, edge := range result {
+		if edge.Distance > 0 || edge.ExitTime > edge.EntryTime {
+			driven = append(driven, edge)
+		}
+	}
+	if len(driven) == 0 && len(result) > 0 {
+		driven = result[len(result)-1:]
+	}
+
+	for i := range driven {
+		if hours := (driven[i].ExitTime - driven[i].EntryTime) / 3600; hours > 0 {
+			driven[i].Speed = driven[i].Distance / hours
+		}
+	}
+	return driven
+}
+
+func fraction(part, total float64) float64 {
+	if total <= 0 {
+		return 1
+	}
+	return part / total
+}
+
+func argmax(values []float64) int {
+	best := 0
+	for i, v := range values {
+		if v > values[best] {
+			best = i
+		}
+	}
+	return best
+}
@@ -0,0 +1,345 @@
+// Package metrics keeps counters, gauges and histograms and writes them in the Prometheus text format,
+// so the server and the simulator can be scraped without a client library
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// the buckets of a latency histogram, in seconds
+var DefaultBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// Collector is a metric family, written with its HELP and TYPE lines
+type Collector interface {
+	Name() string
+	Expose(w io.Writer)
+}
+
+type Registry struct {
+	mu         sync.Mutex
+	collectors map[string]Collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]Collector)}
+}
+
+// Register adds a collector. a name can be registered once
+func (r *Registry) Register(c Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.collectors[c.Name()]; exists {
+		panic(fmt.Sprintf("metrics: %s is already registered", c.Name()))
+	}
+	r.collectors[c.Name()] = c
+}
+
+// Expose writes all the metrics, sorted by name
+func (r *Registry) Expose(w io.Writer) {
+	r.mu.Lock()
+	collectors := make([]Collector, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		collectors = append(collectors, c)
+	}
+	r.mu.Unlock()
+
+	sort.Slice(collectors, func(i, j int) bool { return collectors[i].Name() < collectors[j].Name() })
+	for _, c := range collectors {
+		c.Expose(w)
+	}
+}
+
+func (r *Registry) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Expose(w)
+	}
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	help = strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func formatLabel(name, value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+	return fmt.Sprintf(`%s="%s"`, name, value)
+}
+
+// a float64 that is updated without a lock
+type atomicFloat struct {
+	bits atomic.Uint64
+}
+
+func (f *atomicFloat) Load() float64 {
+	return math.Float64frombits(f.bits.Load())
+}
+
+func (f *atomicFloat) Store(v float64) {
+	f.bits.Store(math.Float64bits(v))
+}
+
+func (f *atomicFloat) Add(v float64) {
+	for {
+		old := f.bits.Load()
+		if f.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+// ---------- counter ----------
+
+// Counter is a value that only goes up
+type Counter struct {
+	name  string
+	help  string
+	value atomicFloat
+}
+
+func (r *Registry) NewCounter(name, help string) *Counter {
+	c := &Counter{name: name, help: help}
+	r.Register(c)
+	return c
+}
+
+func (c *Counter) Inc() { c.value.Add(1) }
+
+// Add adds v, negative values are ignored
+func (c *Counter) Add(v float64) {
+	if v > 0 {
+		c.value.Add(v)
+	}
+}
+
+func (c *Counter) Value() float64 { return c.value.Load() }
+
+func (c *Counter) Name() string { return c.name }
+
+func (c *Counter) Expose(w io.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+	fmt.Fprintf(w, "%s %s\n", c.name, formatFloat(c.Value()))
+}
+
+// CounterVec is a family of counters that differ by the value of a single label
+type CounterVec struct {
+	name     string
+	help     string
+	label    string
+	mu       sync.Mutex
+	counters map[string]*Counter
+}
+
+func (r *Registry) NewCounterVec(name, help, label string) *CounterVec {
+	v := &CounterVec{name: name, help: help, label: label, counters: make(map[string]*Counter)}
+	r.Register(v)
+	return v
+}
+
+// With returns the counter of the label value, creating it on the first use
+func (v *CounterVec) With(value string) *Counter {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	c, exists := v.counters[value]
+	if !exists {
+		c = &Counter{name: v.name}
+		v.counters[value] = c
+	}
+	return c
+}
+
+func (v *CounterVec) Name() string { return v.name }
+
+func (v *CounterVec) Expose(w io.Writer) {
+	v.mu.Lock()
+	values := make([]string, 0, len(v.counters))
+	for value := range v.counters {
+		values = append(values, value)
+	}
+	v.mu.Unlock()
+	sort.Strings(values)
+
+	writeHeader(w, v.name, v.help, "counter")
+	for _, value := range values {
+		fmt.Fprintf(w, "%s{%s} %s\n", v.name, formatLabel(v.label, value), formatFloat(v.With(value).Value()))
+	}
+}
+
+// ---------- gauge ----------
+
+// Gauge is a value that goes up and down
+type Gauge struct {
+	name  string
+	help  string
+	value atomicFloat
+}
+
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	g := &Gauge{name: name, help: help}
+	r.Register(g)
+	return g
+}
+
+func (g *Gauge) Set(v float64) { g.value.Store(v) }
+
+func (g *Gauge) Add(v float64) { g.value.Add(v) }
+
+func (g *Gauge) Value() float64 { return g.value.Load() }
+
+func (g *Gauge) Name() string { return g.name }
+
+func (g *Gauge) Expose(w io.Writer) {
+	writeHeader(w, g.name, g.help, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.Value()))
+}
+
+// a metric whose value is read when it is scraped, for values that are already counted somewhere else
+type funcMetric struct {
+	name string
+	help string
+	kind string
+	fn   func() float64
+}
+
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) Collector {
+	m := &funcMetric{name: name, help: help, kind: "gauge", fn: fn}
+	r.Register(m)
+	return m
+}
+
+// NewCounterFunc is like NewGaugeFunc, fn must never go down
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) Collector {
+	m := &funcMetric{name: name, help: help, kind: "counter", fn: fn}
+	r.Register(m)
+	return m
+}
+
+func (m *funcMetric) Name() string { return m.name }
+
+func (m *funcMetric) Expose(w io.Writer) {
+	writeHeader(w, m.name, m.help, m.kind)
+	fmt.Fprintf(w, "%s %s\n", m.name, formatFloat(m.fn()))
+}
+
+// ---------- histogram ----------
+
+// LinearBuckets returns count buckets, the first at start and each width after the previous
+func LinearBuckets(start, width float64, count int) []float64 {
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start + float64(i)*width
+	}
+	return buckets
+}
+
+// ExponentialBuckets returns count buckets, the first at start and each factor times the previous
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start * math.Pow(factor, float64(i))
+	}
+	return buckets
+}
+
+// Histogram counts observations in buckets by their upper bounds.
+// an observation updates its bucket, the count and the sum together, so a scrape sees all of them or none
+type Histogram struct {
+	name    string
+	help    string
+	buckets []float64 // sorted upper bounds, without +Inf
+
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (r *Registry) NewHistogram(name, help string, buckets []float64) *Histogram {
+	h := &Histogram{name: name, help: help, buckets: buckets, counts: make([]uint64, len(buckets))}
+	r.Register(h)
+	return h
+}
+
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if i < len(h.buckets) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += v
+}
+
+func (h *Histogram) Name() string { return h.name }
+
+func (h *Histogram) Expose(w io.Writer) {
+	h.mu.Lock()
+	counts := append([]uint64{}, h.counts...)
+	count, sum := h.count, h.sum
+	h.mu.Unlock()
+
+	writeHistogram(w, h.name, h.help, h.buckets, counts, count, sum)
+}
+
+// a histogram of values that are read when it is scraped
+type histogramFunc struct {
+	name    string
+	help    string
+	buckets []float64
+	fn      func() []float64
+}
+
+// NewHistogramFunc makes a histogram of the values fn returns on every scrape, like the distribution of a state
+func (r *Registry) NewHistogramFunc(name, help string, buckets []float64, fn func() []float64) Collector {
+	h := &histogramFunc{name: name, help: help, buckets: buckets, fn: fn}
+	r.Register(h)
+	return h
+}
+
+func (h *histogramFunc) Name() string { return h.name }
+
+func (h *histogramFunc) Expose(w io.Writer) {
+	values := h.fn()
+	counts := make([]uint64, len(h.buckets))
+	sum := 0.0
+	for _, v := range values {
+		if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+			counts[i]++
+		}
+		sum += v
+	}
+	writeHistogram(w, h.name, h.help, h.buckets, counts, uint64(len(values)), sum)
+}
+
+// counts are per bucket, the written buckets are cumulative
+func writeHistogram(w io.Writer, name, help string, buckets []float64, counts []uint64, count uint64, sum float64) {
+	writeHeader(w, name, help, "histogram")
+	cumulative := uint64(0)
+	for i, bound := range buckets {
+		cumulative += counts[i]
+		fmt.Fprintf(w, "%s_bucket{%s} %d\n", name, formatLabel("le", formatFloat(bound)), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, count)
+	fmt.Fprintf(w, "%s_sum %s\n", name, formatFloat(sum))
+	fmt.Fprintf(w, "%s_count %d\n", name, count)
+}
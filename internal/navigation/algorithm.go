@@ -0,0 +1,55 @@
+package navigation
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"waze/internal/graph"
+)
+
+// Algorithm finds the cheapest route between two nodes by a profile, like FindPathAstar.
+// the routing uses A*, the others are there to compare their search spaces
+type Algorithm func(ctx context.Context, g *graph.Graph, srcId, dstId int, profile Profile) (*PathResult, error)
+
+const DEFAULT_ALGORITHM = "astar"
+
+var algorithms = map[string]Algorithm{
+	"astar":               FindPathAstar,
+	"bidirectional-astar": FindPathBidirectionalAstar,
+	"dijkstra":            FindPathDijkstra,
+}
+
+// GetAlgorithm returns the algorithm registered under name. an empty name means A*
+func GetAlgorithm(name string) (Algorithm, error) {
+	if name == "" {
+		name = DEFAULT_ALGORITHM
+	}
+	a, ok := algorithms[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown search algorithm %q", name)
+	}
+	return a, nil
+}
+
+// AlgorithmNames returns the names of all registered algorithms, sorted
+func AlgorithmNames() []string {
+	names := make([]string, 0, len(algorithms))
+	for name := range algorithms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FindPathDijkstra is FindPathAstar without the heuristic of the profile, it expands every node
+// that is cheaper to reach than the destination
+func FindPathDijkstra(ctx context.Context, g *graph.Graph, srcId, dstId int, profile Profile) (*PathResult, error) {
+	return FindPathAstar(ctx, g, srcId, dstId, noHeuristic{profile})
+}
+
+// a profile without its heuristic
+type noHeuristic struct {
+	Profile
+}
+
+func (noHeuristic) Heuristic(*graph.Graph, *graph.Node, *graph.Node) float64 { return 0 }
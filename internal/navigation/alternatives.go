@@ -0,0 +1,119 @@
+package navigation
+
+import (
+	"context"
+	"math"
+	"waze/internal/graph"
+)
+
+const (
+	MAX_ALTERNATIVES = 3
+	// the edges of the routes found so far cost this much more in the next search, for every route using them
+	ALTERNATIVE_PENALTY = 1.4
+	// searches per wanted alternative, the penalized searches may find a route that was already found
+	ALTERNATIVE_ATTEMPTS = 3
+	// an alternative shares at most this fraction of its length with a better route
+	MAX_OVERLAP = 0.7
+	// an alternative costs at most this times the best route
+	MAX_STRETCH = 1.5
+)
+
+// FindAlternatives returns the best route followed by up to count other routes, best first.
+// every search penalizes the edges of the routes found so far, so the next one is pushed away from them.
+// routes that mostly overlap a better one, or cost much more than the best, are dropped
+func FindAlternatives(ctx context.Context, g *graph.Graph, srcId, dstId int, profile Profile, count int) ([]*PathResult, error) {
+	best, err := FindPathAstar(ctx, g, srcId, dstId, profile)
+	if err != nil {
+		return nil, err
+	}
+	routes := []*PathResult{best}
+	if count <= 0 || len(best.Route) == 0 {
+		return routes, nil
+	}
+
+	used := make(map[int]int)
+	for _, id := range best.Route {
+		used[id]++
+	}
+	penalized := &penalizedProfile{base: profile, used: used}
+
+	for attempt := 0; attempt < count*ALTERNATIVE_ATTEMPTS && len(routes) <= count; attempt++ {
+		result, err := FindPathAstar(ctx, g, srcId, dstId, penalized)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			break
+		}
+		best.Expanded += result.Expanded
+		// penalized whether it is kept or not, so the next search goes elsewhere
+		for _, id := range result.Route {
+			used[id]++
+		}
+
+		result.Cost = routeCost(g, result.Route, profile)
+		// the penalties only grow, the next routes would be even worse
+		if result.Cost > best.Cost*MAX_STRETCH {
+			break
+		}
+		if isDistinct(g, result, routes) {
+			routes = append(routes, result)
+		}
+	}
+	return routes, nil
+}
+
+// a profile whose edges cost more the more routes already use them
+type penalizedProfile struct {
+	base Profile
+	used map[int]int // edge id -> number of routes driving it
+}
+
+func (p *penalizedProfile) Name() string { return p.base.Name() }
+
+func (p *penalizedProfile) EdgeCost(e *graph.Edge) float64 {
+	cost := p.base.EdgeCost(e)
+	if n := p.used[e.Id]; n > 0 {
+		cost *= math.Pow(ALTERNATIVE_PENALTY, float64(n))
+	}
+	return cost
+}
+
+func (p *penalizedProfile) Heuristic(g *graph.Graph, from, to *graph.Node) float64 {
+	return p.base.Heuristic(g, from, to)
+}
+
+func (p *penalizedProfile) UsesSpeeds() bool { return UsesSpeeds(p.base) }
+
+func routeCost(g *graph.Graph, route []int, profile Profile) float64 {
+	cost := 0.0
+	for _, id := range route {
+		if edge, exists := g.Edges[id]; exists {
+			cost += profile.EdgeCost(edge)
+		}
+	}
+	return cost
+}
+
+// whether the route shares at most MAX_OVERLAP of its length with each of the routes
+func isDistinct(g *graph.Graph, result *PathResult, routes []*PathResult) bool {
+	if result.Distance <= 0 {
+		return false
+	}
+	for _, other := range routes {
+		edges := make(map[int]bool, len(other.Route))
+		for _, id := range other.Route {
+			edges[id] = true
+		}
+		shared := 0.0
+		for _, id := range result.Route {
+			if edges[id] {
+				shared += g.Edges[id].Length
+			}
+		}
+		if shared/result.Distance > MAX_OVERLAP {
+			return false
+		}
+	}
+	return true
+}
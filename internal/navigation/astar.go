@@ -2,19 +2,27 @@ package navigation
 
 import (
 	"container/heap"
+	"context"
 	"fmt"
+	"math"
 	"slices"
 	"waze/internal/graph"
 )
 
-const V_REF float64 = 70
+// the search checks whether it was canceled every this many nodes
+const CANCEL_CHECK_INTERVAL = 256
 
-func FindPathAstar(g *graph.Graph, srcId, dstId int) (*PathResult, error) {
+// FindPathAstar finds the cheapest route from srcId to dstId according to the given profile.
+// it stops with the error of ctx when ctx is done, and reports to the Tracer of ctx when there is one
+func FindPathAstar(ctx context.Context, g *graph.Graph, srcId, dstId int, profile Profile) (*PathResult, error) {
 	srcNode, ok1 := g.Nodes[srcId]
 	dstNode, ok2 := g.Nodes[dstId]
 
-	if !ok1 || !ok2 {
-		return nil, fmt.Errorf("one of the nodes does not exist inside the graph")
+	if !ok1 {
+		return nil, fmt.Errorf("%w: %d", ErrNodeNotFound, srcId)
+	}
+	if !ok2 {
+		return nil, fmt.Errorf("%w: %d", ErrNodeNotFound, dstId)
 	}
 
 	pq := newPriorityQueue()
@@ -23,28 +31,46 @@ func FindPathAstar(g *graph.Graph, srcId, dstId int) (*PathResult, error) {
 	gScore := make(map[int]float64)
 	gScore[srcId] = 0
 
-	cameFrom := make(map[int]int)
+	// the edge every node was reached by, there may be more than one edge between two nodes
+	cameFrom := make(map[int]*graph.Edge)
 	closed := make(map[int]bool)
+	expanded := 0
+	maxOpen := 1
+	tracer := tracerFrom(ctx)
 
 	heap.Push(pq, &AstarNode{
 		NodeId:   srcId,
 		Gscore:   0,
-		Priority: heuristic(srcNode, dstNode) / V_REF,
+		Priority: profile.Heuristic(g, srcNode, dstNode),
 	})
 
-	for pq.Len() > 0 {
+	for popped := 1; pq.Len() > 0; popped++ {
+		if popped%CANCEL_CHECK_INTERVAL == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
 		current := heap.Pop(pq).(*AstarNode)
 		u := current.NodeId
 
 		// we reached the dst node
 		if u == dstId {
-			route := reconstructRoute(cameFrom, u, g)
+			if tracer != nil {
+				tracer.Done(FORWARD, openNodes(pq), gScore)
+			}
+			route := reconstructRoute(cameFrom, u)
 			distance := calcDist(g, route)
 
 			return &PathResult{
 				Route:    route,
-				ETA:      current.Gscore * 60, // convert to minutes
+				ETA:      calcETA(g, route) * 60, // convert to minutes
 				Distance: distance,
+				Cost:     current.Gscore,
+				Profile:  profile.Name(),
+				Expanded: expanded,
+				Open:     pq.Len(),
+				MaxOpen:  maxOpen,
 			}, nil
 		}
 
@@ -54,6 +80,10 @@ func FindPathAstar(g *graph.Graph, srcId, dstId int) (*PathResult, error) {
 		}
 		// else put the node in the closed set
 		closed[u] = true
+		expanded++
+		if tracer != nil {
+			tracer.Expand(FORWARD, u, current.Gscore)
+		}
 
 		for _, edge := range g.GetNeighbors(u) {
 			v := edge.To
@@ -61,20 +91,18 @@ func FindPathAstar(g *graph.Graph, srcId, dstId int) (*PathResult, error) {
 			if closed[v] {
 				continue
 			}
-			speed := edge.GetCurrentSpeed()
-			// safety check
-			if speed <= 0 {
-				speed = 1.0
+			cost := profile.EdgeCost(edge)
+			// a closed edge
+			if math.IsInf(cost, 1) {
+				continue
 			}
-
-			timeCost := edge.Length / speed
-			newGscore := gScore[u] + timeCost
+			newGscore := gScore[u] + cost
 
 			oldScore, exists := gScore[v]
 			if !exists || newGscore < oldScore {
 				gScore[v] = newGscore
 
-				h := heuristic(g.Nodes[v], dstNode) / V_REF
+				h := profile.Heuristic(g, g.Nodes[v], dstNode)
 				f := newGscore + h
 
 				// if v already in pq
@@ -86,34 +114,45 @@ func FindPathAstar(g *graph.Graph, srcId, dstId int) (*PathResult, error) {
 						Gscore:   newGscore,
 						Priority: f,
 					})
+					maxOpen = max(maxOpen, pq.Len())
 				}
-				cameFrom[v] = u
+				cameFrom[v] = edge
 			}
 		}
 	}
 
 	// no path was found
-	return nil, fmt.Errorf("No path found between %d and %d", srcId, dstId)
+	if tracer != nil {
+		tracer.Done(FORWARD, openNodes(pq), gScore)
+	}
+	return nil, fmt.Errorf("%w between %d and %d", ErrNoRoute, srcId, dstId)
 }
 
 // return the total distance of the route in KM
 func calcDist(g *graph.Graph, route []int) float64 {
 	total_distance := 0.0
 
-	for i := 0; i < len(route)-1; i++ {
-		u := route[i]
-		v := route[i+1]
-
-		for _, edge := range g.GetNeighbors(u) {
-			if edge.To == v {
-				total_distance += edge.Length
-				break
-			}
+	// the route is made of edge ids
+	for _, edgeId := range route {
+		if edge, exists := g.Edges[edgeId]; exists {
+			total_distance += edge.Length
 		}
 	}
 	return total_distance
 }
 
+// return the travel time of the route in hours, by the live edge speeds
+func calcETA(g *graph.Graph, route []int) float64 {
+	total_time := 0.0
+
+	for _, edgeId := range route {
+		if edge, exists := g.Edges[edgeId]; exists {
+			total_time += travelTime(edge)
+		}
+	}
+	return total_time
+}
+
 // func reconstructRoute(cameFrom map[int]int, current int) []int {
 // 	path := make([]int, 0, len(cameFrom))
 // 	for {
@@ -128,31 +167,19 @@ func calcDist(g *graph.Graph, route []int) float64 {
 // 	return path
 // }
 
-func reconstructRoute(cameFrom map[int]int, current int, g *graph.Graph) []int {
+// the edge ids of the route to current, by the edges the nodes were reached by
+func reconstructRoute(cameFrom map[int]*graph.Edge, current int) []int {
 	path := make([]int, 0)
 
 	for {
-		prev, ok := cameFrom[current]
+		edge, ok := cameFrom[current]
 
-		// prev doesn't exists. That means current is the src node
+		// no edge. That means current is the src node
 		if !ok {
 			break
 		}
-		// The edge that connects prev and current
-		edgeId := -1
-
-		for _, edge := range g.GetNeighbors(prev) {
-			if edge.To == current {
-				edgeId = edge.Id
-				break
-			}
-		}
-
-		// we find the edge
-		if edgeId != -1 {
-			path = append(path, edgeId)
-		}
-		current = prev
+		path = append(path, edge.Id)
+		current = edge.From
 	}
 	// return the current path (in reverse)
 	slices.Reverse(path)
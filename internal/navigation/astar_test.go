@@ -0,0 +1,98 @@
+package navigation
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+	"waze/internal/graph"
+)
+
+// two parallel edges from 1 to 2, the first one added is the longer, then on to 3
+func parallelGraph(t *testing.T) *graph.Graph {
+	t.Helper()
+	g := graph.NewGraph()
+	g.AddNode(&graph.Node{Id: 1, X: 0, Y: 0})
+	g.AddNode(&graph.Node{Id: 2, X: 0.01, Y: 0})
+	g.AddNode(&graph.Node{Id: 3, X: 0.02, Y: 0})
+	for _, e := range []*graph.Edge{
+		{Id: 10, From: 1, To: 2, Length: 1.5, SpeedLimit: 50},
+		{Id: 11, From: 1, To: 2, Length: 1.0, SpeedLimit: 50},
+		{Id: 12, From: 2, To: 3, Length: 1.0, SpeedLimit: 50},
+	} {
+		e.SetCurrentSpeed(e.SpeedLimit)
+		if err := g.AddEdge(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return g
+}
+
+func TestRouteTakesTheRelaxedParallelEdge(t *testing.T) {
+	g := parallelGraph(t)
+	fastest, err := GetProfile("fastest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		avoid []int
+		route []int // nil when there is none
+	}{
+		{"the shorter edge", nil, []int{11, 12}},
+		{"avoiding the shorter edge", []int{11}, []int{10, 12}},
+		{"avoiding the longer edge", []int{10}, []int{11, 12}},
+		{"avoiding both", []int{10, 11}, nil},
+	}
+
+	for _, name := range AlgorithmNames() {
+		algorithm, err := GetAlgorithm(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				profile := Restrict(fastest, AvoidEdges(tt.avoid...), math.Inf(1))
+
+				result, err := algorithm(context.Background(), g, 1, 3, profile)
+				if tt.route == nil {
+					if !errors.Is(err, ErrNoRoute) {
+						t.Fatalf("want no route, got %v, %v", result, err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !slices.Equal(result.Route, tt.route) {
+					t.Errorf("route %v, want %v", result.Route, tt.route)
+				}
+				if want := calcDist(g, tt.route); math.Abs(result.Distance-want) > 1e-9 {
+					t.Errorf("distance %v, want %v", result.Distance, want)
+				}
+			})
+		}
+	}
+
+	// the rows of the matrix too
+	for _, tt := range tests {
+		t.Run("matrix/"+tt.name, func(t *testing.T) {
+			profile := Restrict(fastest, AvoidEdges(tt.avoid...), math.Inf(1))
+			results, err := FindPathsToMany(context.Background(), g, 1, []int{3}, profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.route == nil {
+				if results[0] != nil {
+					t.Fatalf("want no route, got %v", results[0].Route)
+				}
+				return
+			}
+			if results[0] == nil || !slices.Equal(results[0].Route, tt.route) {
+				t.Errorf("route %v, want %v", results[0], tt.route)
+			}
+		})
+	}
+}
@@ -1,5 +1,157 @@
 package navigation
 
-// func FindPathBidirecAstar (g *graph.Graph, srcId, dstId int) (*PathResult, error) {
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"waze/internal/graph"
+)
 
-// }
+// a direction of the bidirectional search
+type searchSide struct {
+	dir    Direction
+	pq     *PriorityQueue
+	gScore map[int]float64
+	via    map[int]*graph.Edge // the edge a node was reached by
+	closed map[int]bool
+
+	sign      float64 // of the potential in the priority
+	neighbors func(nodeId int) []*graph.Edge
+	next      func(e *graph.Edge) int // the node an edge leads to in this direction
+}
+
+// FindPathBidirectionalAstar finds the same route as FindPathAstar with two searches that meet in the middle,
+// one from srcId and one from dstId against the edges. both aim with the average of the heuristics to the two ends,
+// so they can stop once no route through the nodes they have left can be cheaper than the best meeting so far.
+// it stops with the error of ctx when ctx is done, and reports to the Tracer of ctx when there is one
+func FindPathBidirectionalAstar(ctx context.Context, g *graph.Graph, srcId, dstId int, profile Profile) (*PathResult, error) {
+	srcNode, ok1 := g.Nodes[srcId]
+	dstNode, ok2 := g.Nodes[dstId]
+
+	if !ok1 {
+		return nil, fmt.Errorf("%w: %d", ErrNodeNotFound, srcId)
+	}
+	if !ok2 {
+		return nil, fmt.Errorf("%w: %d", ErrNodeNotFound, dstId)
+	}
+
+	// positive near the destination, negative near the source. the forward search adds it and the backward search
+	// subtracts it, with a consistent heuristic neither sees a negative edge
+	potential := func(node *graph.Node) float64 {
+		return (profile.Heuristic(g, node, dstNode) - profile.Heuristic(g, srcNode, node)) / 2
+	}
+
+	forward := &searchSide{
+		dir: FORWARD, pq: newPriorityQueue(), gScore: map[int]float64{srcId: 0},
+		via: make(map[int]*graph.Edge), closed: make(map[int]bool),
+		sign: 1, neighbors: g.GetNeighbors, next: func(e *graph.Edge) int { return e.To },
+	}
+	backward := &searchSide{
+		dir: BACKWARD, pq: newPriorityQueue(), gScore: map[int]float64{dstId: 0},
+		via: make(map[int]*graph.Edge), closed: make(map[int]bool),
+		sign:      -1,
+		neighbors: func(nodeId int) []*graph.Edge { return g.ReverseAdjList[nodeId] },
+		next:      func(e *graph.Edge) int { return e.From },
+	}
+	heap.Push(forward.pq, &AstarNode{NodeId: srcId, Gscore: 0, Priority: potential(srcNode)})
+	heap.Push(backward.pq, &AstarNode{NodeId: dstId, Gscore: 0, Priority: -potential(dstNode)})
+
+	// the cheapest route found so far goes through meeting
+	best, meeting := math.Inf(1), -1
+	if srcId == dstId {
+		best, meeting = 0, srcId
+	}
+	expanded, maxOpen := 0, 2
+	tracer := tracerFrom(ctx)
+
+	for popped := 1; forward.pq.Len() > 0 && backward.pq.Len() > 0; popped++ {
+		if popped%CANCEL_CHECK_INTERVAL == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		// the priorities are lower bounds, their sum bounds every route that is left
+		if forward.pq.items[0].Priority+backward.pq.items[0].Priority >= best {
+			break
+		}
+
+		// the side with the smaller open set goes on
+		this, other := forward, backward
+		if backward.pq.Len() < forward.pq.Len() {
+			this, other = backward, forward
+		}
+		current := heap.Pop(this.pq).(*AstarNode)
+		u := current.NodeId
+		this.closed[u] = true
+		expanded++
+		if tracer != nil {
+			tracer.Expand(this.dir, u, current.Gscore)
+		}
+
+		for _, edge := range this.neighbors(u) {
+			v := this.next(edge)
+			if this.closed[v] {
+				continue
+			}
+			cost := profile.EdgeCost(edge)
+			// a closed edge
+			if math.IsInf(cost, 1) {
+				continue
+			}
+			newGscore := current.Gscore + cost
+			if oldScore, exists := this.gScore[v]; exists && newGscore >= oldScore {
+				continue
+			}
+			this.gScore[v] = newGscore
+			this.via[v] = edge
+
+			f := newGscore + this.sign*potential(g.Nodes[v])
+			if _, exists := this.pq.index[v]; exists {
+				this.pq.Update(v, f, newGscore)
+			} else {
+				heap.Push(this.pq, &AstarNode{NodeId: v, Gscore: newGscore, Priority: f})
+				maxOpen = max(maxOpen, forward.pq.Len()+backward.pq.Len())
+			}
+
+			// the other side reached v too
+			if otherScore, exists := other.gScore[v]; exists && newGscore+otherScore < best {
+				best, meeting = newGscore+otherScore, v
+			}
+		}
+	}
+
+	if tracer != nil {
+		tracer.Done(FORWARD, openNodes(forward.pq), forward.gScore)
+		tracer.Done(BACKWARD, openNodes(backward.pq), backward.gScore)
+	}
+	if meeting == -1 {
+		return nil, fmt.Errorf("%w between %d and %d", ErrNoRoute, srcId, dstId)
+	}
+
+	// from the meeting back to the source, and then on to the destination
+	route := make([]int, 0)
+	for node := meeting; node != srcId; {
+		edge := forward.via[node]
+		route = append(route, edge.Id)
+		node = edge.From
+	}
+	slices.Reverse(route)
+	for node := meeting; node != dstId; {
+		edge := backward.via[node]
+		route = append(route, edge.Id)
+		node = edge.To
+	}
+
+	return &PathResult{
+		Route:    route,
+		ETA:      calcETA(g, route) * 60, // convert to minutes
+		Distance: calcDist(g, route),
+		Cost:     best,
+		Profile:  profile.Name(),
+		Expanded: expanded,
+		Open:     forward.pq.Len() + backward.pq.Len(),
+		MaxOpen:  maxOpen,
+	}, nil
+}
@@ -0,0 +1,123 @@
+package navigation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+	"waze/internal/graph"
+)
+
+const TEST_MAP_FILE = "../../data/filtered_shoham.json"
+
+// random pairs of nodes of the bundled map, the same ones every run
+const (
+	RANDOM_PAIRS = 40
+	RANDOM_SEED  = 1
+)
+
+// the edge ids of a route must lead from one node to the next, from srcId to dstId
+func checkRoute(t *testing.T, g *graph.Graph, route []int, srcId, dstId int) {
+	t.Helper()
+	at := srcId
+	for _, id := range route {
+		edge, exists := g.Edges[id]
+		if !exists || edge.From != at {
+			t.Fatalf("route %v doesn't go on from node %d at edge %d", route, at, id)
+		}
+		at = edge.To
+	}
+	if at != dstId {
+		t.Fatalf("route %v ends at %d, want %d", route, at, dstId)
+	}
+}
+
+// a search between two nodes, avoiding the edges with these ids
+type pairTest struct {
+	name     string
+	from, to int
+	avoid    []int
+	route    bool // whether there is one
+}
+
+func TestBidirectionalAstarCostsTheSame(t *testing.T) {
+	g, err := graph.LoadGraph(TEST_MAP_FILE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]int, 0, len(g.Nodes))
+	for id := range g.Nodes {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	random := rand.New(rand.NewSource(RANDOM_SEED))
+	// the map is connected, a node is unreachable once every edge into it is avoided
+	into := func(nodeId int) []int {
+		edges := make([]int, 0)
+		for _, edge := range g.ReverseAdjList[nodeId] {
+			edges = append(edges, edge.Id)
+		}
+		return edges
+	}
+
+	tests := []pairTest{
+		{"from == to", ids[0], ids[0], nil, true},
+		{"from == to, nothing leads to it", ids[1], ids[1], into(ids[1]), true},
+		{"nothing leads to the destination", ids[0], ids[len(ids)-1], into(ids[len(ids)-1]), false},
+	}
+	for i := 0; i < RANDOM_PAIRS; i++ {
+		from, to := ids[random.Intn(len(ids))], ids[random.Intn(len(ids))]
+		tests = append(tests, pairTest{fmt.Sprintf("%d to %d", from, to), from, to, nil, true})
+	}
+	for i := 0; i < RANDOM_PAIRS/4; i++ {
+		from, to := ids[random.Intn(len(ids))], ids[random.Intn(len(ids))]
+		if from == to {
+			continue
+		}
+		tests = append(tests, pairTest{fmt.Sprintf("%d to unreachable %d", from, to), from, to, into(to), false})
+	}
+
+	for _, profileName := range ProfileNames() {
+		base, err := GetProfile(profileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tt := range tests {
+			t.Run(profileName+"/"+tt.name, func(t *testing.T) {
+				profile := Restrict(base, AvoidEdges(tt.avoid...), math.Inf(1))
+				ctx := context.Background()
+
+				bidirectional, err := FindPathBidirectionalAstar(ctx, g, tt.from, tt.to, profile)
+				astar, astarErr := FindPathAstar(ctx, g, tt.from, tt.to, profile)
+				dijkstra, dijkstraErr := FindPathDijkstra(ctx, g, tt.from, tt.to, profile)
+				if !tt.route {
+					for name, err := range map[string]error{"bidirectional-astar": err, "astar": astarErr, "dijkstra": dijkstraErr} {
+						if !errors.Is(err, ErrNoRoute) {
+							t.Errorf("%s: want no route, got %v", name, err)
+						}
+					}
+					return
+				}
+				if err := errors.Join(err, astarErr, dijkstraErr); err != nil {
+					t.Fatal(err)
+				}
+
+				checkRoute(t, g, bidirectional.Route, tt.from, tt.to)
+				if tt.from == tt.to && (len(bidirectional.Route) != 0 || bidirectional.Cost != 0) {
+					t.Errorf("route %v of cost %v from a node to itself", bidirectional.Route, bidirectional.Cost)
+				}
+				for name, want := range map[string]float64{"astar": astar.Cost, "dijkstra": dijkstra.Cost} {
+					if math.Abs(bidirectional.Cost-want) > 1e-9*math.Max(1, want) {
+						t.Errorf("cost %v, %s found %v", bidirectional.Cost, name, want)
+					}
+				}
+				if cost := routeCost(g, bidirectional.Route, profile); math.Abs(bidirectional.Cost-cost) > 1e-9*math.Max(1, cost) {
+					t.Errorf("cost %v, the edges of the route cost %v", bidirectional.Cost, cost)
+				}
+			})
+		}
+	}
+}
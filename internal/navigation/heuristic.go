@@ -1,10 +1,14 @@
 package navigation
 
 import (
-	"math"
 	"waze/internal/graph"
 )
 
 func heuristic(n1, n2 *graph.Node) float64 {
-	return math.Sqrt((n1.X-n2.X)*(n1.X-n2.X) + (n1.Y-n2.Y)*(n1.Y-n2.Y))
+	return n1.DistanceTo(n2)
+}
+
+// the shortest distance in KM any route from n1 to n2 can have
+func distanceLowerBound(g *graph.Graph, n1, n2 *graph.Node) float64 {
+	return heuristic(n1, n2) * g.KmPerUnit()
 }
@@ -0,0 +1,222 @@
+package navigation
+
+import (
+	"fmt"
+	"math"
+	"waze/internal/graph"
+	"waze/internal/types"
+)
+
+// turn angle limits in degrees, by the absolute angle between two edges
+const (
+	STRAIGHT_ANGLE = 20
+	SLIGHT_ANGLE   = 45
+	TURN_ANGLE     = 135
+	UTURN_ANGLE    = 170
+)
+
+// BuildInstructions turns a route of edge ids into turn-by-turn instructions.
+// straight continuations on the same street are merged into a single step
+func BuildInstructions(g *graph.Graph, route []int) []types.Instruction {
+	edges := make([]*graph.Edge, 0, len(route))
+	for _, edgeId := range route {
+		if edge, exists := g.Edges[edgeId]; exists {
+			edges = append(edges, edge)
+		}
+	}
+	if len(edges) == 0 {
+		return nil
+	}
+
+	first := edges[0]
+	startHeading, _ := g.EdgeHeadings(first)
+	instructions := []types.Instruction{{
+		Type:     "depart",
+		Modifier: compassDirection(startHeading),
+		Street:   first.Name,
+		NodeId:   first.From,
+		Text:     withStreet("head "+compassDirection(startHeading), "on", first.Name),
+	}}
+
+	// distance and time driven since the last instruction
+	distance, duration := 0.0, 0.0
+
+	for i, edge := range edges {
+		distance += edge.Length
+		duration += travelTime(edge) * 60
+
+		if i == len(edges)-1 {
+			break
+		}
+		next := edges[i+1]
+
+		// inside a roundabout everything was already said when entering it
+		if edge.Roundabout {
+			continue
+		}
+
+		var step types.Instruction
+		if next.Roundabout {
+			step = roundaboutInstruction(g, edges[i+1:])
+		} else {
+			var ok bool
+			step, ok = turnInstruction(g, edge, next)
+			if !ok {
+				continue
+			}
+		}
+
+		step.NodeId = edge.To
+		step.Distance = distance
+		step.Duration = duration
+		step.Text += " in " + formatDistance(distance)
+		instructions = append(instructions, step)
+
+		distance, duration = 0, 0
+	}
+
+	last := edges[len(edges)-1]
+	instructions = append(instructions, types.Instruction{
+		Type:     "arrive",
+		NodeId:   last.To,
+		Distance: distance,
+		Duration: duration,
+		Text:     "arrive at your destination in " + formatDistance(distance),
+	})
+	return instructions
+}
+
+// the maneuver between two edges. returns false when there is nothing to say
+func turnInstruction(g *graph.Graph, from, to *graph.Edge) (types.Instruction, bool) {
+	angle := turnAngle(g, from, to)
+	modifier := turnModifier(angle)
+	sameStreet := from.Name == to.Name
+
+	// the road just goes on (or bends where there is no other way to go)
+	if modifier == "straight" || onlyWayForward(g, from) {
+		if sameStreet {
+			return types.Instruction{}, false
+		}
+		return types.Instruction{
+			Type:     "continue",
+			Modifier: "straight",
+			Street:   to.Name,
+			Text:     withStreet("continue", "onto", to.Name),
+		}, true
+	}
+
+	if math.Abs(angle) >= UTURN_ANGLE {
+		return types.Instruction{
+			Type:     "uturn",
+			Modifier: modifier,
+			Street:   to.Name,
+			Text:     withStreet("make a u-turn", "onto", to.Name),
+		}, true
+	}
+
+	return types.Instruction{
+		Type:     "turn",
+		Modifier: modifier,
+		Street:   to.Name,
+		Text:     withStreet("turn "+modifier, "onto", to.Name),
+	}, true
+}
+
+// the instruction for entering a roundabout. edges starts at the first edge inside the roundabout
+func roundaboutInstruction(g *graph.Graph, edges []*graph.Edge) types.Instruction {
+	exit := 0
+	street := ""
+	for _, edge := range edges {
+		if !edge.Roundabout {
+			street = edge.Name
+			break
+		}
+		// every node on the way with a road out of the roundabout is an exit
+		if hasRoundaboutExit(g, edge.To) {
+			exit++
+		}
+	}
+
+	return types.Instruction{
+		Type:   "roundabout",
+		Exit:   exit,
+		Street: street,
+		Text:   withStreet(fmt.Sprintf("enter the roundabout and take exit %d", exit), "onto", street),
+	}
+}
+
+func hasRoundaboutExit(g *graph.Graph, nodeId int) bool {
+	for _, edge := range g.GetNeighbors(nodeId) {
+		if !edge.Roundabout {
+			return true
+		}
+	}
+	return false
+}
+
+// true when the only way out of the node at the end of the edge (besides going back) is a single road
+func onlyWayForward(g *graph.Graph, from *graph.Edge) bool {
+	count := 0
+	for _, edge := range g.GetNeighbors(from.To) {
+		if edge.To != from.From {
+			count++
+		}
+	}
+	return count <= 1
+}
+
+// the angle between the end of one edge and the start of the next, in (-180, 180]. positive is to the right
+func turnAngle(g *graph.Graph, from, to *graph.Edge) float64 {
+	_, inHeading := g.EdgeHeadings(from)
+	outHeading, _ := g.EdgeHeadings(to)
+
+	angle := math.Mod(outHeading-inHeading, 360)
+	if angle > 180 {
+		angle -= 360
+	} else if angle <= -180 {
+		angle += 360
+	}
+	return angle
+}
+
+func turnModifier(angle float64) string {
+	side := "right"
+	if angle < 0 {
+		side = "left"
+	}
+
+	abs := math.Abs(angle)
+	switch {
+	case abs < STRAIGHT_ANGLE:
+		return "straight"
+	case abs < SLIGHT_ANGLE:
+		return "slight " + side
+	case abs < TURN_ANGLE:
+		return side
+	default:
+		return "sharp " + side
+	}
+}
+
+func compassDirection(heading float64) string {
+	directions := []string{"north", "northeast", "east", "southeast", "south", "southwest", "west", "northwest"}
+	index := int(math.Round(heading/45)) % len(directions)
+	return directions[index]
+}
+
+func withStreet(text, preposition, street string) string {
+	if street == "" {
+		return text
+	}
+	return text + " " + preposition + " " + street
+}
+
+// distance in KM to a readable text: meters rounded to tens, or KM with one digit.
+// rounded before the unit is chosen, 0.995 KM is 1.0 km and not 1000 m
+func formatDistance(km float64) string {
+	meters := math.Round(km*100) * 10
+	if meters < 1000 {
+		return fmt.Sprintf("%d m", int(meters))
+	}
+	return fmt.Sprintf("%.1f km", km)
+}
@@ -0,0 +1,88 @@
+package navigation
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"math"
+	"waze/internal/graph"
+)
+
+// FindPathsToMany finds the cheapest routes from srcId to every target with a single Dijkstra search,
+// a row of a travel time matrix. the result has a route per target, nil for the targets without one.
+// it stops with the error of ctx when ctx is done
+func FindPathsToMany(ctx context.Context, g *graph.Graph, srcId int, targets []int, profile Profile) ([]*PathResult, error) {
+	if _, ok := g.Nodes[srcId]; !ok {
+		return nil, fmt.Errorf("%w: %d", ErrNodeNotFound, srcId)
+	}
+	remaining := make(map[int]bool, len(targets))
+	for _, id := range targets {
+		if _, ok := g.Nodes[id]; !ok {
+			return nil, fmt.Errorf("%w: %d", ErrNodeNotFound, id)
+		}
+		remaining[id] = true
+	}
+
+	pq := newPriorityQueue()
+	heap.Init(pq)
+
+	gScore := map[int]float64{srcId: 0}
+	cameFrom := make(map[int]*graph.Edge)
+	closed := make(map[int]bool)
+
+	heap.Push(pq, &AstarNode{NodeId: srcId, Gscore: 0, Priority: 0})
+
+	// the search ends once every target is settled, it has no goal to aim at so there is no heuristic
+	for popped := 1; pq.Len() > 0 && len(remaining) > 0; popped++ {
+		if popped%CANCEL_CHECK_INTERVAL == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		u := heap.Pop(pq).(*AstarNode).NodeId
+		if closed[u] {
+			continue
+		}
+		closed[u] = true
+		delete(remaining, u)
+
+		for _, edge := range g.GetNeighbors(u) {
+			v := edge.To
+			if closed[v] {
+				continue
+			}
+			cost := profile.EdgeCost(edge)
+			if math.IsInf(cost, 1) {
+				continue
+			}
+			newGscore := gScore[u] + cost
+			if oldScore, exists := gScore[v]; !exists || newGscore < oldScore {
+				gScore[v] = newGscore
+				if _, exists := pq.index[v]; exists {
+					pq.Update(v, newGscore, newGscore)
+				} else {
+					heap.Push(pq, &AstarNode{NodeId: v, Gscore: newGscore, Priority: newGscore})
+				}
+				cameFrom[v] = edge
+			}
+		}
+	}
+
+	results := make([]*PathResult, len(targets))
+	for i, id := range targets {
+		if !closed[id] {
+			continue
+		}
+		route := reconstructRoute(cameFrom, id)
+		results[i] = &PathResult{
+			Route:    route,
+			ETA:      calcETA(g, route) * 60, // convert to minutes
+			Distance: calcDist(g, route),
+			Cost:     gScore[id],
+			Profile:  profile.Name(),
+			Expanded: len(closed),
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,249 @@
+package navigation
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"waze/internal/graph"
+)
+
+// Profile decides what a "good" route is. The A* search minimizes the sum of EdgeCost
+// over the route, and Heuristic must never overestimate the remaining cost to the goal
+type Profile interface {
+	Name() string
+	EdgeCost(e *graph.Edge) float64
+	Heuristic(g *graph.Graph, from, to *graph.Node) float64
+}
+
+const DEFAULT_PROFILE = "fastest"
+
+var profiles = map[string]Profile{
+	"fastest":        FastestProfile{},
+	"shortest":       ShortestProfile{},
+	"avoid-highways": NewAvoidProfile("avoid-highways", (*graph.Edge).IsHighway, AVOID_PENALTY),
+	"avoid-tolls":    NewAvoidProfile("avoid-tolls", isToll, AVOID_PENALTY),
+	"eco":            NewEcoProfile(DefaultConsumption),
+}
+
+// GetProfile returns the profile registered under name. an empty name means the default profile
+func GetProfile(name string) (Profile, error) {
+	if name == "" {
+		name = DEFAULT_PROFILE
+	}
+	p, ok := profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown routing profile %q", name)
+	}
+	return p, nil
+}
+
+// UsesSpeeds returns whether the costs of the profile change with the live speeds.
+// a profile says it doesn't with a UsesSpeeds method, the others do
+func UsesSpeeds(profile Profile) bool {
+	if p, ok := profile.(interface{ UsesSpeeds() bool }); ok {
+		return p.UsesSpeeds()
+	}
+	return true
+}
+
+// ProfileNames returns the names of all registered profiles, sorted
+func ProfileNames() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// the speed the routing uses for an edge, in KM/hour
+func edgeSpeed(e *graph.Edge) float64 {
+	speed := e.RoutingSpeed()
+	// safety check
+	if speed <= 0 {
+		speed = 1.0
+	}
+	return speed
+}
+
+// returns the travel time of the edge in hours
+func travelTime(e *graph.Edge) float64 {
+	return e.Length / edgeSpeed(e)
+}
+
+// lower bound of the travel time in hours: the shortest possible distance at the highest possible speed
+func travelTimeLowerBound(g *graph.Graph, from, to *graph.Node) float64 {
+	return distanceLowerBound(g, from, to) / g.MaxSpeed()
+}
+
+// ---------- fastest ----------
+
+// FastestProfile minimizes the travel time according to the live edge speeds
+type FastestProfile struct{}
+
+func (FastestProfile) Name() string { return "fastest" }
+
+func (FastestProfile) EdgeCost(e *graph.Edge) float64 { return travelTime(e) }
+
+func (FastestProfile) Heuristic(g *graph.Graph, from, to *graph.Node) float64 {
+	return travelTimeLowerBound(g, from, to)
+}
+
+// ---------- shortest ----------
+
+// ShortestProfile minimizes the driven distance and ignores traffic
+type ShortestProfile struct{}
+
+func (ShortestProfile) Name() string { return "shortest" }
+
+func (ShortestProfile) EdgeCost(e *graph.Edge) float64 { return e.Length }
+
+func (ShortestProfile) UsesSpeeds() bool { return false }
+
+func (ShortestProfile) Heuristic(g *graph.Graph, from, to *graph.Node) float64 {
+	return distanceLowerBound(g, from, to)
+}
+
+// ---------- avoid ----------
+
+// multiplier of the travel time on an avoided road
+const AVOID_PENALTY float64 = 5
+
+func isToll(e *graph.Edge) bool {
+	return e.Toll
+}
+
+// AvoidClasses returns a predicate that matches edges of any of the given road classes
+func AvoidClasses(classes ...graph.RoadClass) func(e *graph.Edge) bool {
+	set := make(map[graph.RoadClass]bool, len(classes))
+	for _, c := range classes {
+		set[c.Base()] = true
+	}
+	return func(e *graph.Edge) bool {
+		return set[e.Class().Base()]
+	}
+}
+
+// AvoidProfile is the fastest route, but edges matching Avoid cost Penalty times their travel time.
+// the roads are still usable, so a route exists whenever the fastest profile finds one
+type AvoidProfile struct {
+	name    string
+	Avoid   func(e *graph.Edge) bool
+	Penalty float64
+}
+
+func NewAvoidProfile(name string, avoid func(e *graph.Edge) bool, penalty float64) *AvoidProfile {
+	// a penalty below 1 would make the travel time heuristic overestimate
+	if penalty < 1 {
+		penalty = 1
+	}
+	return &AvoidProfile{name: name, Avoid: avoid, Penalty: penalty}
+}
+
+func (p *AvoidProfile) Name() string { return p.name }
+
+func (p *AvoidProfile) EdgeCost(e *graph.Edge) float64 {
+	cost := travelTime(e)
+	if p.Avoid(e) {
+		cost *= p.Penalty
+	}
+	return cost
+}
+
+// every edge costs at least its travel time, so the fastest heuristic stays admissible
+func (p *AvoidProfile) Heuristic(g *graph.Graph, from, to *graph.Node) float64 {
+	return travelTimeLowerBound(g, from, to)
+}
+
+// RestrictedProfile is another profile with the edges matching Avoid costing Penalty times more.
+// an infinite penalty closes the edges. the costs only grow, so the heuristic of the base stays admissible
+type RestrictedProfile struct {
+	Base    Profile
+	Avoid   func(e *graph.Edge) bool
+	Penalty float64
+}
+
+func Restrict(base Profile, avoid func(e *graph.Edge) bool, penalty float64) *RestrictedProfile {
+	if penalty < 1 {
+		penalty = 1
+	}
+	return &RestrictedProfile{Base: base, Avoid: avoid, Penalty: penalty}
+}
+
+func (p *RestrictedProfile) Name() string { return p.Base.Name() }
+
+func (p *RestrictedProfile) EdgeCost(e *graph.Edge) float64 {
+	if !p.Avoid(e) {
+		return p.Base.EdgeCost(e)
+	}
+	// a closed edge of zero cost would be NaN
+	if math.IsInf(p.Penalty, 1) {
+		return p.Penalty
+	}
+	return p.Base.EdgeCost(e) * p.Penalty
+}
+
+func (p *RestrictedProfile) Heuristic(g *graph.Graph, from, to *graph.Node) float64 {
+	return p.Base.Heuristic(g, from, to)
+}
+
+func (p *RestrictedProfile) UsesSpeeds() bool { return UsesSpeeds(p.Base) }
+
+// AvoidEdges returns a predicate that matches the edges with the given ids
+func AvoidEdges(ids ...int) func(e *graph.Edge) bool {
+	set := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return func(e *graph.Edge) bool {
+		return set[e.Id]
+	}
+}
+
+// ---------- eco ----------
+
+// ConsumptionModel gives fuel consumption in liters per 100 KM at speed v (KM/hour):
+// Idle/v + Base + Drag*v^2. the first part is the engine running at low speeds,
+// the last one is the air drag at high speeds
+type ConsumptionModel struct {
+	Idle float64
+	Base float64
+	Drag float64
+}
+
+var DefaultConsumption = ConsumptionModel{Idle: 250, Base: 3.0, Drag: 0.0006}
+
+func (m ConsumptionModel) LitersPer100Km(v float64) float64 {
+	if v <= 0 {
+		v = 1.0
+	}
+	return m.Idle/v + m.Base + m.Drag*v*v
+}
+
+// the lowest consumption over all speeds. the model is convex, so it is at v = (Idle / 2Drag)^(1/3)
+func (m ConsumptionModel) MinLitersPer100Km() float64 {
+	if m.Drag <= 0 {
+		return m.Base
+	}
+	return m.LitersPer100Km(math.Cbrt(m.Idle / (2 * m.Drag)))
+}
+
+// EcoProfile minimizes the fuel used (in liters), driving each edge at its live speed
+type EcoProfile struct {
+	Model ConsumptionModel
+}
+
+func NewEcoProfile(model ConsumptionModel) *EcoProfile {
+	return &EcoProfile{Model: model}
+}
+
+func (p *EcoProfile) Name() string { return "eco" }
+
+func (p *EcoProfile) EdgeCost(e *graph.Edge) float64 {
+	return e.Length * p.Model.LitersPer100Km(edgeSpeed(e)) / 100
+}
+
+// no edge can be driven with less than the minimal consumption
+func (p *EcoProfile) Heuristic(g *graph.Graph, from, to *graph.Node) float64 {
+	return distanceLowerBound(g, from, to) * p.Model.MinLitersPer100Km() / 100
+}
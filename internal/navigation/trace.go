@@ -0,0 +1,74 @@
+package navigation
+
+import "context"
+
+// the directions of a search. a bidirectional search has both
+type Direction string
+
+const (
+	FORWARD  Direction = "forward"  // from the source along the edges
+	BACKWARD Direction = "backward" // from the destination against the edges
+)
+
+// Tracer is told what a search does, to see its search space. a search reports to the tracer of its context,
+// see WithTracer, and does nothing more when there is none
+type Tracer interface {
+	// a node was taken out of the open set and expanded, gScore is its cost from the start of the direction
+	Expand(dir Direction, nodeId int, gScore float64)
+	// the direction ended: open holds the nodes left in its open set, gScore the cost of every node it reached.
+	// the search doesn't touch them after, the tracer may keep them
+	Done(dir Direction, open []int, gScore map[int]float64)
+}
+
+type tracerKey struct{}
+
+// WithTracer returns a context that makes the searches report to t
+func WithTracer(ctx context.Context, t Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey{}, t)
+}
+
+// the tracer of the search, nil when it is not traced
+func tracerFrom(ctx context.Context) Tracer {
+	t, _ := ctx.Value(tracerKey{}).(Tracer)
+	return t
+}
+
+type TraceStep struct {
+	Node      int       `json:"node"`
+	Direction Direction `json:"direction"`
+	GScore    float64   `json:"g"`
+}
+
+// what a direction of a search left when it ended
+type TraceFrontier struct {
+	Direction Direction       `json:"direction"`
+	Open      []int           `json:"open"`
+	GScores   map[int]float64 `json:"g_scores"`
+}
+
+// Trace is a Tracer that keeps everything: the expansions in their order and the end of every direction
+type Trace struct {
+	Steps     []TraceStep     `json:"steps"`
+	Frontiers []TraceFrontier `json:"frontiers"`
+}
+
+func NewTrace() *Trace {
+	return &Trace{Steps: make([]TraceStep, 0), Frontiers: make([]TraceFrontier, 0)}
+}
+
+func (t *Trace) Expand(dir Direction, nodeId int, gScore float64) {
+	t.Steps = append(t.Steps, TraceStep{Node: nodeId, Direction: dir, GScore: gScore})
+}
+
+func (t *Trace) Done(dir Direction, open []int, gScore map[int]float64) {
+	t.Frontiers = append(t.Frontiers, TraceFrontier{Direction: dir, Open: open, GScores: gScore})
+}
+
+// the nodes of the open set of a search
+func openNodes(pq *PriorityQueue) []int {
+	nodes := make([]int, 0, pq.Len())
+	for _, item := range pq.items {
+		nodes = append(nodes, item.NodeId)
+	}
+	return nodes
+}
@@ -1,11 +1,24 @@
 package navigation
 
+import "errors"
+
 // import "time"
 
+// the errors of a search, the returned errors wrap them
+var (
+	ErrNodeNotFound = errors.New("node not found")
+	ErrNoRoute      = errors.New("no route found")
+)
+
 type PathResult struct {
 	Route    []int
 	Distance float64 // in KM
-	ETA      float64
+	ETA      float64 // in minutes
+	Cost     float64 // in the units of the profile
+	Profile  string
+	Expanded int // nodes the search expanded, its closed set. for the best route of FindAlternatives, all of its searches
+	Open     int // nodes left in the open set when the route was found
+	MaxOpen  int // the most nodes in the open set at once
 }
 
 // Route    []int   `json:"route"`
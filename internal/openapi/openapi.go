@@ -0,0 +1,251 @@
+// Package openapi builds an OpenAPI 3 document of the HTTP API from the Go types it sends and receives,
+// so the document can't drift away from the code
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const OPENAPI_VERSION = "3.0.3"
+
+// Schema is a JSON schema object of the document
+type Schema = map[string]interface{}
+
+type Param struct {
+	Name        string
+	In          string // query, path or header
+	Type        string // integer, number, string or boolean
+	Required    bool
+	Description string
+}
+
+// Operation describes a single endpoint. Body and Response are values of the Go types,
+// only their types are used
+type Operation struct {
+	Method      string
+	Path        string
+	Summary     string
+	Params      []Param
+	Body        interface{} // nil when there is no request body
+	Response    interface{} // nil when the response has no body
+	ContentType string      // of the response, application/json when empty
+	Errors      []int       // the status codes of the error responses
+}
+
+type Spec struct {
+	title     string
+	version   string
+	errorBody reflect.Type
+	paths     map[string]map[string]interface{}
+	schemas   map[string]Schema
+	names     map[reflect.Type]string
+}
+
+// New returns an empty document. errorBody is a value of the body every error response has
+func New(title, version string, errorBody interface{}) *Spec {
+	return &Spec{
+		title:     title,
+		version:   version,
+		errorBody: reflect.TypeOf(errorBody),
+		paths:     make(map[string]map[string]interface{}),
+		schemas:   make(map[string]Schema),
+		names:     make(map[reflect.Type]string),
+	}
+}
+
+func (s *Spec) Add(op Operation) {
+	operation := map[string]interface{}{
+		"summary":     op.Summary,
+		"operationId": operationID(op),
+	}
+
+	if len(op.Params) > 0 {
+		params := make([]interface{}, 0, len(op.Params))
+		for _, p := range op.Params {
+			params = append(params, map[string]interface{}{
+				"name":        p.Name,
+				"in":          p.In,
+				"required":    p.Required,
+				"description": p.Description,
+				"schema":      Schema{"type": p.Type},
+			})
+		}
+		operation["parameters"] = params
+	}
+
+	if op.Body != nil {
+		operation["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": s.SchemaOf(reflect.TypeOf(op.Body))},
+			},
+		}
+	}
+
+	responses := make(map[string]interface{})
+	success := map[string]interface{}{"description": "OK"}
+	if op.Response != nil {
+		contentType := op.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		success["content"] = map[string]interface{}{
+			contentType: map[string]interface{}{"schema": s.SchemaOf(reflect.TypeOf(op.Response))},
+		}
+	}
+	responses["200"] = success
+	for _, status := range op.Errors {
+		responses[strconv.Itoa(status)] = map[string]interface{}{
+			"description": http.StatusText(status),
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": s.SchemaOf(s.errorBody)},
+			},
+		}
+	}
+	operation["responses"] = responses
+
+	if s.paths[op.Path] == nil {
+		s.paths[op.Path] = make(map[string]interface{})
+	}
+	s.paths[op.Path][strings.ToLower(op.Method)] = operation
+}
+
+// the method and the path in camel case, "GET /navigate" is getNavigate
+func operationID(op Operation) string {
+	var sb strings.Builder
+	sb.WriteString(strings.ToLower(op.Method))
+	for _, part := range strings.FieldsFunc(op.Path, func(r rune) bool { return strings.ContainsRune("/_.{}", r) }) {
+		sb.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return sb.String()
+}
+
+func (s *Spec) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"openapi": OPENAPI_VERSION,
+		"info": map[string]interface{}{
+			"title":   s.title,
+			"version": s.version,
+		},
+		"paths": s.paths,
+		"components": map[string]interface{}{
+			"schemas": s.schemas,
+		},
+	})
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// SchemaOf returns the schema of a Go type as encoding/json writes it.
+// named structs are added to the components and referenced
+func (s *Spec) SchemaOf(t reflect.Type) Schema {
+	switch t {
+	case timeType:
+		return Schema{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := s.SchemaOf(t.Elem())
+		if _, isRef := schema["$ref"]; isRef {
+			return Schema{"allOf": []interface{}{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string", "format": "byte"}
+		}
+		return Schema{"type": "array", "items": s.SchemaOf(t.Elem())}
+	case reflect.Array:
+		return Schema{"type": "array", "items": s.SchemaOf(t.Elem()), "minItems": t.Len(), "maxItems": t.Len()}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": s.SchemaOf(t.Elem())}
+	case reflect.Interface:
+		return Schema{}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structSchema(t)
+		}
+		return Schema{"$ref": "#/components/schemas/" + s.register(t)}
+	default:
+		panic(fmt.Sprintf("openapi: no schema for %s", t))
+	}
+}
+
+// adds a named struct to the components, and returns its name there
+func (s *Spec) register(t reflect.Type) string {
+	if name, exists := s.names[t]; exists {
+		return name
+	}
+	name := t.Name()
+	if _, taken := s.schemas[name]; taken {
+		// the same name in another package
+		name = strings.ReplaceAll(t.PkgPath(), "/", "_") + "_" + name
+	}
+	s.names[t] = name
+	// reserved before building, for types that refer to themselves
+	s.schemas[name] = Schema{}
+	s.schemas[name] = s.structSchema(t)
+	return name
+}
+
+func (s *Spec) structSchema(t reflect.Type) Schema {
+	properties := make(map[string]interface{})
+	required := make([]string, 0)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" && options == "" {
+			continue
+		}
+		// encoding/json puts the fields of an untagged embedded struct in the outer object
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			embedded := s.structSchema(field.Type)
+			for key, value := range embedded["properties"].(map[string]interface{}) {
+				properties[key] = value
+			}
+			if fields, ok := embedded["required"].([]string); ok {
+				required = append(required, fields...)
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = s.SchemaOf(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	schema := Schema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
@@ -0,0 +1,87 @@
+// Package polyline implements the encoded polyline algorithm format
+// (https://developers.google.com/maps/documentation/utilities/polylinealgorithm).
+// points are [x, y] pairs, which are written in the (lat, lng) = (y, x) order of the format
+package polyline
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// number of decimal digits kept for every coordinate
+const PRECISION = 5
+
+var factor = math.Pow10(PRECISION)
+
+func Encode(points [][2]float64) string {
+	var sb strings.Builder
+	prevLat, prevLng := 0, 0
+
+	for _, p := range points {
+		lat := int(math.Round(p[1] * factor))
+		lng := int(math.Round(p[0] * factor))
+
+		// every value is written as the difference from the previous point
+		writeValue(&sb, lat-prevLat)
+		writeValue(&sb, lng-prevLng)
+
+		prevLat, prevLng = lat, lng
+	}
+	return sb.String()
+}
+
+func writeValue(sb *strings.Builder, value int) {
+	v := value << 1
+	if value < 0 {
+		v = ^v
+	}
+	// chunks of 5 bits, from the lowest. 0x20 marks that another chunk follows
+	for v >= 0x20 {
+		sb.WriteByte(byte((0x20 | (v & 0x1f)) + 63))
+		v >>= 5
+	}
+	sb.WriteByte(byte(v + 63))
+}
+
+func Decode(encoded string) ([][2]float64, error) {
+	points := make([][2]float64, 0)
+	lat, lng := 0, 0
+
+	for i := 0; i < len(encoded); {
+		dLat, next, err := readValue(encoded, i)
+		if err != nil {
+			return nil, err
+		}
+		dLng, next, err := readValue(encoded, next)
+		if err != nil {
+			return nil, err
+		}
+		i = next
+
+		lat += dLat
+		lng += dLng
+		points = append(points, [2]float64{float64(lng) / factor, float64(lat) / factor})
+	}
+	return points, nil
+}
+
+func readValue(encoded string, i int) (int, int, error) {
+	result, shift := 0, 0
+	for {
+		if i >= len(encoded) {
+			return 0, i, fmt.Errorf("polyline ended in the middle of a value")
+		}
+		b := int(encoded[i]) - 63
+		i++
+		result |= (b & 0x1f) << shift
+		shift += 5
+		if b < 0x20 {
+			break
+		}
+	}
+	if result&1 != 0 {
+		return ^(result >> 1), i, nil
+	}
+	return result >> 1, i, nil
+}
@@ -0,0 +1,525 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"waze/internal/graph"
+	"waze/internal/navigation"
+)
+
+// a speed override lasts OVERRIDE_TTL unless the request says otherwise, and never more than MAX_OVERRIDE_TTL
+const (
+	OVERRIDE_TTL            = time.Hour
+	MAX_OVERRIDE_TTL        = 24 * time.Hour
+	OVERRIDE_CHECK_INTERVAL = time.Second
+)
+
+// the most congested edges listed when the request has no limit, and at most
+const (
+	CONGESTED_LIMIT     = 20
+	MAX_CONGESTED_LIMIT = 1000
+)
+
+// a speed an operator set on an edge, the routing uses it instead of the reports until it expires
+type EdgeOverride struct {
+	EdgeID  int       `json:"edge_id"`
+	Speed   float64   `json:"speed"` // KM/hour
+	Reason  string    `json:"reason,omitempty"`
+	SetBy   string    `json:"set_by"` // the name of the API key
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+type OverrideRequest struct {
+	Speed  float64 `json:"speed"` // KM/hour, up to the speed limit times graph.MaxSpeedFactor
+	TTL    float64 `json:"ttl"`   // seconds until the override expires, OVERRIDE_TTL when 0
+	Reason string  `json:"reason,omitempty"`
+}
+
+// the overrides by edge id. they are set on the edges of the current map, and move to the new map on a reload
+type overrides struct {
+	mu     sync.Mutex
+	byEdge map[int]*EdgeOverride
+}
+
+func newOverrides() *overrides {
+	return &overrides{byEdge: make(map[int]*EdgeOverride)}
+}
+
+// an edge with its live state
+type EdgeInfo struct {
+	Edge *graph.Edge `json:"edge"`
+	EdgeCongestion
+	ReportedSpeed float64       `json:"reported_speed"` // KM/hour, learned from the reports even under an override
+	Reports       uint64        `json:"reports"`
+	LastUpdate    *time.Time    `json:"last_update,omitempty"`
+	TravelTime    float64       `json:"travel_time"` // minutes at the speed
+	Override      *EdgeOverride `json:"override,omitempty"`
+
+	// only for a single edge
+	RecentReports []ReportRecord   `json:"recent_reports,omitempty"` // oldest first
+	History       []SpeedSample    `json:"history,omitempty"`        // a sample every HISTORY_INTERVAL, oldest first
+	Incidents     []IncidentPeriod `json:"incidents,omitempty"`
+}
+
+type NodeInfo struct {
+	Node *graph.Node `json:"node"`
+	Out  []EdgeInfo  `json:"out"` // the edges that leave the node
+	In   []EdgeInfo  `json:"in"`  // the edges that enter it
+}
+
+// how a search went, to explain a route or tune the heuristics
+type SearchStats struct {
+	From       int     `json:"from"`
+	To         int     `json:"to"`
+	Profile    string  `json:"profile"`
+	Algorithm  string  `json:"algorithm"`
+	Edges      int     `json:"edges"` // of the route
+	Cost       float64 `json:"cost"`  // in the units of the profile
+	ETA        float64 `json:"eta"`   // minutes
+	Distance   float64 `json:"distance"`
+	DurationMs float64 `json:"duration_ms"`
+
+	Nodes    int `json:"nodes"`    // of the graph
+	Expanded int `json:"expanded"` // the closed set
+	Frontier int `json:"frontier"` // the open set when the route was found
+	MaxOpen  int `json:"max_open"` // the biggest the open set got
+
+	Heuristic      float64 `json:"heuristic"`       // the estimate of the cost at the start
+	HeuristicRatio float64 `json:"heuristic_ratio"` // heuristic / cost, 1 when the estimate is exact
+	// the nodes the same search expands without the heuristic, A* saves the difference
+	DijkstraExpanded int `json:"dijkstra_expanded"`
+}
+
+// a search with its search space, for the GUI to show how it went
+type SearchTrace struct {
+	SearchStats
+	Route []int `json:"route"` // edge ids
+	navigation.Trace
+}
+
+// the live state of an edge
+func (s *Server) edgeInfo(edge *graph.Edge, now time.Time) EdgeInfo {
+	congestion := congestionOf(edge, now)
+	info := EdgeInfo{
+		Edge:           edge,
+		EdgeCongestion: congestion,
+		ReportedSpeed:  edge.GetCurrentSpeed(),
+		Reports:        edge.ReportCount(),
+		TravelTime:     edge.Length / congestion.Speed * 60,
+		Override:       s.overrides.get(edge.Id),
+	}
+	if last := edge.LastUpdate(); !last.IsZero() {
+		info.LastUpdate = &last
+	}
+	return info
+}
+
+// the id in the path of the request
+func pathId(r *http.Request, name string) (int, error) {
+	id, err := strconv.Atoi(r.PathValue(name))
+	if err != nil {
+		return 0, invalidParams("invalid '%s' in the path", name)
+	}
+	return id, nil
+}
+
+func edgeNotFound(edgeId int) *APIError {
+	return &APIError{Status: http.StatusNotFound, Code: ERR_NOT_FOUND, Message: fmt.Sprintf("edge %d not found", edgeId)}
+}
+
+// HandleEdgeInfo writes an edge with its live speed, its last reports, its speed history and its incidents
+func (s *Server) HandleEdgeInfo(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	edge, exists := s.Map().Graph.Edges[id]
+	if !exists {
+		writeError(w, edgeNotFound(id))
+		return
+	}
+	info := s.edgeInfo(edge, time.Now())
+	info.RecentReports, info.History = s.edgeLog.get(edge.Id)
+	info.Incidents = incidentPeriods(info.History)
+	writeJSON(w, http.StatusOK, info)
+}
+
+// HandleNodeInfo writes a node with the live state of its edges
+func (s *Server) HandleNodeInfo(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	g := s.Map().Graph
+	node, exists := g.Nodes[id]
+	if !exists {
+		writeError(w, fmt.Errorf("%w: %d", navigation.ErrNodeNotFound, id))
+		return
+	}
+
+	now := time.Now()
+	info := NodeInfo{Node: node, Out: make([]EdgeInfo, 0), In: make([]EdgeInfo, 0)}
+	for _, edge := range g.AdjList[id] {
+		info.Out = append(info.Out, s.edgeInfo(edge, now))
+	}
+	for _, edge := range g.ReverseAdjList[id] {
+		info.In = append(info.In, s.edgeInfo(edge, now))
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+// HandleCongested writes the slowest edges relative to their speed limits, slowest first
+func (s *Server) HandleCongested(w http.ResponseWriter, r *http.Request) {
+	limit := CONGESTED_LIMIT
+	if str := r.URL.Query().Get("limit"); str != "" {
+		n, err := strconv.Atoi(str)
+		if err != nil || n <= 0 || n > MAX_CONGESTED_LIMIT {
+			writeError(w, invalidParams("'limit' must be between 1 and %d", MAX_CONGESTED_LIMIT))
+			return
+		}
+		limit = n
+	}
+	minConfidence := 0.0
+	if str := r.URL.Query().Get("min_confidence"); str != "" {
+		c, err := strconv.ParseFloat(str, 64)
+		if err != nil || c < 0 || c > 1 {
+			writeError(w, invalidParams("'min_confidence' must be between 0 and 1"))
+			return
+		}
+		minConfidence = c
+	}
+
+	g := s.Map().Graph
+	now := time.Now()
+	congested := make([]EdgeCongestion, 0)
+	for _, c := range edgeCongestion(g, now) {
+		// the edges at their limit are not congested
+		if c.Ratio < 1 && c.Confidence >= minConfidence {
+			congested = append(congested, c)
+		}
+	}
+	sort.Slice(congested, func(i, j int) bool {
+		if congested[i].Ratio != congested[j].Ratio {
+			return congested[i].Ratio < congested[j].Ratio
+		}
+		return congested[i].EdgeID < congested[j].EdgeID
+	})
+
+	edges := make([]EdgeInfo, 0, min(limit, len(congested)))
+	for _, c := range congested[:min(limit, len(congested))] {
+		edges = append(edges, s.edgeInfo(g.Edges[c.EdgeID], now))
+	}
+	writeJSON(w, http.StatusOK, edges)
+}
+
+// HandleSetOverride sets the speed of an edge for the routing, until it expires or is cleared
+func (s *Server) HandleSetOverride(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var req OverrideRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, invalidParams("invalid JSON: %v", err))
+		return
+	}
+	override, err := s.SetOverride(id, req, IdentityFrom(r.Context()).Name, time.Now())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, override)
+}
+
+// HandleClearOverride gives the edge back its reported speed, and writes the override it had
+func (s *Server) HandleClearOverride(w http.ResponseWriter, r *http.Request) {
+	id, err := pathId(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	override := s.ClearOverride(id)
+	if override == nil {
+		writeError(w, &APIError{Status: http.StatusNotFound, Code: ERR_NOT_FOUND, Message: fmt.Sprintf("edge %d has no override", id)})
+		return
+	}
+	writeJSON(w, http.StatusOK, override)
+}
+
+// HandleOverrides writes the overrides in effect, by edge id
+func (s *Server) HandleOverrides(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.overrides.list())
+}
+
+// a search of the debug endpoints
+type searchQuery struct {
+	from, to      int
+	profile       navigation.Profile
+	algorithmName string
+	algorithm     navigation.Algorithm
+	tracer        navigation.Tracer // of the search of the algorithm, not of Dijkstra. nil when it is not traced
+}
+
+func parseSearchQuery(r *http.Request) (*searchQuery, error) {
+	query := r.URL.Query()
+	from, err1 := strconv.Atoi(query.Get("from"))
+	to, err2 := strconv.Atoi(query.Get("to"))
+	if err1 != nil || err2 != nil {
+		return nil, invalidParams("invalid 'from' or 'to' parameters")
+	}
+	profile, err := navigation.GetProfile(query.Get("profile"))
+	if err != nil {
+		return nil, invalidParams("%v", err)
+	}
+	name := query.Get("algorithm")
+	if name == "" {
+		name = navigation.DEFAULT_ALGORITHM
+	}
+	algorithm, err := navigation.GetAlgorithm(name)
+	if err != nil {
+		return nil, invalidParams("%v", err)
+	}
+	return &searchQuery{from: from, to: to, profile: profile, algorithmName: name, algorithm: algorithm}, nil
+}
+
+// HandleSearchStats runs a search on the routing workers and writes how it went. it doesn't use the route cache
+func (s *Server) HandleSearchStats(w http.ResponseWriter, r *http.Request) {
+	q, err := parseSearchQuery(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	stats, _, err := s.search(r.Context(), q)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// HandleSearchTrace runs a search like HandleSearchStats, and writes every node it expanded in order
+// with the open sets and the g-scores it ended with
+func (s *Server) HandleSearchTrace(w http.ResponseWriter, r *http.Request) {
+	q, err := parseSearchQuery(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	trace := navigation.NewTrace()
+	q.tracer = trace
+	stats, result, err := s.search(r.Context(), q)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, SearchTrace{SearchStats: *stats, Route: result.Route, Trace: *trace})
+}
+
+// sends the search of q to the routing workers, and waits for it as long as a navigation request
+func (s *Server) search(ctx context.Context, q *searchQuery) (*SearchStats, *navigation.PathResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, NAVIGATION_TIMEOUT)
+	defer cancel()
+	req := PathRequest{
+		Ctx:             ctx,
+		Graph:           s.Map().Graph,
+		StartNodeId:     q.from,
+		EndNodeId:       q.to,
+		Profile:         q.profile,
+		Search:          q,
+		ResponseChannel: make(chan PathResult, 1),
+	}
+	select {
+	case s.Jobs <- req:
+	default:
+		return nil, nil, overloaded("too many navigation requests")
+	}
+
+	select {
+	case result := <-req.ResponseChannel:
+		if result.Err != nil {
+			return nil, nil, result.Err
+		}
+		return result.Stats, result.Routes[0], nil
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+}
+
+// runs the search of q, traced when q has a tracer, and then Dijkstra to compare
+func searchStats(ctx context.Context, g *graph.Graph, q *searchQuery) (*SearchStats, *navigation.PathResult, error) {
+	traced := ctx
+	if q.tracer != nil {
+		traced = navigation.WithTracer(ctx, q.tracer)
+	}
+	start := time.Now()
+	result, err := q.algorithm(traced, g, q.from, q.to, q.profile)
+	if err != nil {
+		return nil, nil, err
+	}
+	duration := time.Since(start)
+	dijkstra, err := navigation.FindPathDijkstra(ctx, g, q.from, q.to, q.profile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stats := &SearchStats{
+		From:       q.from,
+		To:         q.to,
+		Profile:    result.Profile,
+		Algorithm:  q.algorithmName,
+		Edges:      len(result.Route),
+		Cost:       result.Cost,
+		ETA:        result.ETA,
+		Distance:   result.Distance,
+		DurationMs: float64(duration.Microseconds()) / 1000,
+
+		Nodes:    len(g.Nodes),
+		Expanded: result.Expanded,
+		Frontier: result.Open,
+		MaxOpen:  result.MaxOpen,
+
+		Heuristic:        q.profile.Heuristic(g, g.Nodes[q.from], g.Nodes[q.to]),
+		DijkstraExpanded: dijkstra.Expanded,
+	}
+	if result.Cost > 0 {
+		stats.HeuristicRatio = stats.Heuristic / result.Cost
+	}
+	return stats, result, nil
+}
+
+// SetOverride makes the routing use req.Speed on the edge until the override expires
+func (s *Server) SetOverride(edgeId int, req OverrideRequest, setBy string, now time.Time) (*EdgeOverride, error) {
+	if req.TTL < 0 || req.TTL > MAX_OVERRIDE_TTL.Seconds() {
+		return nil, invalidParams("'ttl' must be between 0 and %.0f seconds", MAX_OVERRIDE_TTL.Seconds())
+	}
+	ttl := OVERRIDE_TTL
+	if req.TTL > 0 {
+		ttl = time.Duration(req.TTL * float64(time.Second))
+	}
+
+	s.overrides.mu.Lock()
+	defer s.overrides.mu.Unlock()
+
+	edge, exists := s.Map().Graph.Edges[edgeId]
+	if !exists {
+		return nil, edgeNotFound(edgeId)
+	}
+	// a faster speed would make the heuristics overestimate
+	maxSpeed := edge.SpeedLimit * graph.MaxSpeedFactor
+	if !(req.Speed > 0) || req.Speed > maxSpeed {
+		return nil, invalidParams("'speed' must be above 0 and at most %.1f on edge %d", maxSpeed, edgeId)
+	}
+
+	override := &EdgeOverride{
+		EdgeID:  edgeId,
+		Speed:   req.Speed,
+		Reason:  req.Reason,
+		SetBy:   setBy,
+		Created: now,
+		Expires: now.Add(ttl),
+	}
+	s.overrides.byEdge[edgeId] = override
+	edge.SetOverride(req.Speed)
+	s.Routes.UpdateSpeeds([]*graph.Edge{edge})
+	log.Printf("Speed of edge %d overridden to %.1f by %s until %s", edgeId, req.Speed, setBy, override.Expires.Format(time.RFC3339))
+	return override, nil
+}
+
+// ClearOverride gives the edge back its reported speed. it returns the override the edge had, nil when none
+func (s *Server) ClearOverride(edgeId int) *EdgeOverride {
+	s.overrides.mu.Lock()
+	defer s.overrides.mu.Unlock()
+
+	override, exists := s.overrides.byEdge[edgeId]
+	if !exists {
+		return nil
+	}
+	s.clearOverride(edgeId)
+	log.Printf("Speed override of edge %d cleared", edgeId)
+	return override
+}
+
+// must be called with the lock of the overrides held
+func (s *Server) clearOverride(edgeId int) {
+	delete(s.overrides.byEdge, edgeId)
+	if edge, exists := s.Map().Graph.Edges[edgeId]; exists {
+		edge.ClearOverride()
+		s.Routes.UpdateSpeeds([]*graph.Edge{edge})
+	}
+}
+
+// clears the overrides that expired, every OVERRIDE_CHECK_INTERVAL until the server stops
+func (s *Server) expireOverrides() {
+	ticker := time.NewTicker(OVERRIDE_CHECK_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			s.overrides.mu.Lock()
+			for id, override := range s.overrides.byEdge {
+				if !now.Before(override.Expires) {
+					s.clearOverride(id)
+					log.Printf("Speed override of edge %d expired", id)
+				}
+			}
+			s.overrides.mu.Unlock()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// sets the overrides on the edges of to with the same id and ends as on from, the others are dropped.
+// it is called after to became the current map
+func (s *Server) moveOverrides(from, to *graph.Graph) {
+	s.overrides.mu.Lock()
+	defer s.overrides.mu.Unlock()
+
+	for id, override := range s.overrides.byEdge {
+		old, existed := from.Edges[id]
+		if !existed {
+			// set on to after it became the current map
+			continue
+		}
+		edge, exists := to.Edges[id]
+		if !exists || edge.From != old.From || edge.To != old.To || override.Speed > edge.SpeedLimit*graph.MaxSpeedFactor {
+			delete(s.overrides.byEdge, id)
+			log.Printf("Speed override of edge %d dropped, the edge changed on the new map", id)
+			continue
+		}
+		edge.SetOverride(override.Speed)
+	}
+}
+
+func (o *overrides) get(edgeId int) *EdgeOverride {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if override, exists := o.byEdge[edgeId]; exists {
+		copied := *override
+		return &copied
+	}
+	return nil
+}
+
+func (o *overrides) list() []EdgeOverride {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	list := make([]EdgeOverride, 0, len(o.byEdge))
+	for _, override := range o.byEdge {
+		list = append(list, *override)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].EdgeID < list[j].EdgeID })
+	return list
+}
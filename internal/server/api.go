@@ -0,0 +1,378 @@
+package server
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"waze/internal/openapi"
+	"waze/internal/types"
+)
+
+const (
+	API_PREFIX = "/api/v1"
+	// the first API, without a version. the same handlers are served there with the old shapes,
+	// see legacyWriter
+	LEGACY_API_PREFIX = "/api"
+	API_VERSION       = "1.0.0"
+)
+
+// an endpoint of the API, with its documentation
+type Route struct {
+	Method  string
+	Path    string // under the API prefix
+	Role    string // the role the API key needs
+	Limit   string // the rate limit the requests take a token of, none when empty
+	Handler http.HandlerFunc
+	Doc     openapi.Operation
+}
+
+func (s *Server) routes() []Route {
+	return []Route{
+		{
+			Method:  http.MethodPost,
+			Path:    "/traffic",
+			Role:    ROLE_REPORTER,
+			Limit:   LIMIT_REPORT,
+			Handler: s.HandleTrafficBatch,
+			Doc: openapi.Operation{
+				Summary: "Report the speeds of cars on edges",
+				Body:    []types.TrafficReport{},
+				Errors:  []int{http.StatusBadRequest},
+			},
+		},
+		{
+			Method:  http.MethodPost,
+			Path:    "/traffic/gps",
+			Role:    ROLE_REPORTER,
+			Limit:   LIMIT_REPORT,
+			Handler: s.HandleGPSTraces,
+			Doc: openapi.Operation{
+				Summary:  "Report raw GPS traces, they are matched to the map",
+				Body:     []types.GPSTrace{},
+				Response: []MatchedTrace{},
+				Errors:   []int{http.StatusBadRequest},
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/traffic/stream",
+			Role:    ROLE_REPORTER,
+			Handler: s.HandleReportStream,
+			Doc: openapi.Operation{
+				Summary: "Stream traffic reports over a WebSocket as {\"seq\", \"reports\"} messages, each is answered once it was applied. " +
+					"every message takes a token of the report limit",
+				Response: types.ReportAck{},
+				Errors:   []int{http.StatusBadRequest},
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/navigate",
+			Role:    ROLE_NAVIGATOR,
+			Limit:   LIMIT_NAVIGATE,
+			Handler: s.HandleNavigation,
+			Doc: openapi.Operation{
+				Summary: "Find a route between two nodes",
+				Params: []openapi.Param{
+					{Name: "from", In: "query", Type: "integer", Required: true, Description: "source node id"},
+					{Name: "to", In: "query", Type: "integer", Required: true, Description: "destination node id"},
+					{Name: "profile", In: "query", Type: "string", Description: "routing profile, fastest by default"},
+					{Name: "instructions", In: "query", Type: "boolean", Description: "add turn-by-turn instructions"},
+					{Name: "timeout", In: "query", Type: "number", Description: "seconds to wait for the route"},
+				},
+				Response: types.NavigationResponse{},
+				Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusServiceUnavailable},
+			},
+		},
+		{
+			Method:  http.MethodPost,
+			Path:    "/navigate",
+			Role:    ROLE_NAVIGATOR,
+			Limit:   LIMIT_NAVIGATE,
+			Handler: s.HandleNavigationPost,
+			Doc: openapi.Operation{
+				Summary:  "Find routes between nodes or locations, with avoids and alternatives",
+				Body:     types.NavigationRequest{},
+				Response: types.NavigationResponse{},
+				Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusServiceUnavailable},
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/stats/route-cache",
+			Role:    ROLE_VIEWER,
+			Handler: s.HandleRouteCacheStats,
+			Doc: openapi.Operation{
+				Summary:  "Hit rate and size of the route cache",
+				Response: CacheStats{},
+			},
+		},
+		{
+			Method:  http.MethodPost,
+			Path:    "/admin/reload",
+			Role:    ROLE_ADMIN,
+			Handler: s.HandleReload,
+			Doc: openapi.Operation{
+				Summary:  "Reload the config and the map, the live speeds carry over",
+				Response: ReloadResult{},
+				Errors:   []int{http.StatusInternalServerError},
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/admin/edges/{id}",
+			Role:    ROLE_ADMIN,
+			Handler: s.HandleEdgeInfo,
+			Doc: openapi.Operation{
+				Summary: "An edge with its live speed, its last reports, its speed history and its incidents",
+				Params: []openapi.Param{
+					{Name: "id", In: "path", Type: "integer", Required: true, Description: "edge id"},
+				},
+				Response: EdgeInfo{},
+				Errors:   []int{http.StatusBadRequest, http.StatusNotFound},
+			},
+		},
+		{
+			Method:  http.MethodPut,
+			Path:    "/admin/edges/{id}/override",
+			Role:    ROLE_ADMIN,
+			Handler: s.HandleSetOverride,
+			Doc: openapi.Operation{
+				Summary: "Set the speed the routing uses on an edge until it expires, instead of the reported speed",
+				Params: []openapi.Param{
+					{Name: "id", In: "path", Type: "integer", Required: true, Description: "edge id"},
+				},
+				Body:     OverrideRequest{},
+				Response: EdgeOverride{},
+				Errors:   []int{http.StatusBadRequest, http.StatusNotFound},
+			},
+		},
+		{
+			Method:  http.MethodDelete,
+			Path:    "/admin/edges/{id}/override",
+			Role:    ROLE_ADMIN,
+			Handler: s.HandleClearOverride,
+			Doc: openapi.Operation{
+				Summary: "Give an edge back its reported speed",
+				Params: []openapi.Param{
+					{Name: "id", In: "path", Type: "integer", Required: true, Description: "edge id"},
+				},
+				Response: EdgeOverride{},
+				Errors:   []int{http.StatusBadRequest, http.StatusNotFound},
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/admin/overrides",
+			Role:    ROLE_ADMIN,
+			Handler: s.HandleOverrides,
+			Doc: openapi.Operation{
+				Summary:  "The speed overrides in effect",
+				Response: []EdgeOverride{},
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/admin/nodes/{id}",
+			Role:    ROLE_ADMIN,
+			Handler: s.HandleNodeInfo,
+			Doc: openapi.Operation{
+				Summary: "A node with the live state of the edges that leave and enter it",
+				Params: []openapi.Param{
+					{Name: "id", In: "path", Type: "integer", Required: true, Description: "node id"},
+				},
+				Response: NodeInfo{},
+				Errors:   []int{http.StatusBadRequest, http.StatusNotFound},
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/admin/congested",
+			Role:    ROLE_ADMIN,
+			Handler: s.HandleCongested,
+			Doc: openapi.Operation{
+				Summary: "The slowest edges relative to their speed limits, slowest first",
+				Params: []openapi.Param{
+					{Name: "limit", In: "query", Type: "integer", Description: "number of edges, 20 by default"},
+					{Name: "min_confidence", In: "query", Type: "number", Description: "leave out the edges whose speed is trusted less, 0-1"},
+				},
+				Response: []EdgeInfo{},
+				Errors:   []int{http.StatusBadRequest},
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/admin/search",
+			Role:    ROLE_ADMIN,
+			Handler: s.HandleSearchStats,
+			Doc: openapi.Operation{
+				Summary: "Run a search and tell how it went: the nodes it expanded, the open set, and the heuristic",
+				Params: []openapi.Param{
+					{Name: "from", In: "query", Type: "integer", Required: true, Description: "source node id"},
+					{Name: "to", In: "query", Type: "integer", Required: true, Description: "destination node id"},
+					{Name: "profile", In: "query", Type: "string", Description: "routing profile, fastest by default"},
+					{Name: "algorithm", In: "query", Type: "string", Description: "astar (default), bidirectional-astar or dijkstra"},
+				},
+				Response: SearchStats{},
+				Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusServiceUnavailable},
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/admin/search/trace",
+			Role:    ROLE_ADMIN,
+			Handler: s.HandleSearchTrace,
+			Doc: openapi.Operation{
+				Summary: "Run a search and return its search space: the nodes in the order it expanded them, its open sets and g-scores at the end",
+				Params: []openapi.Param{
+					{Name: "from", In: "query", Type: "integer", Required: true, Description: "source node id"},
+					{Name: "to", In: "query", Type: "integer", Required: true, Description: "destination node id"},
+					{Name: "profile", In: "query", Type: "string", Description: "routing profile, fastest by default"},
+					{Name: "algorithm", In: "query", Type: "string", Description: "astar (default), bidirectional-astar or dijkstra"},
+				},
+				Response: SearchTrace{},
+				Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusServiceUnavailable},
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/events",
+			Role:    ROLE_VIEWER,
+			Handler: s.HandleEvents,
+			Doc: openapi.Operation{
+				Summary: "Stream the live updates as Server-Sent Events",
+				Params: []openapi.Param{
+					{Name: "topics", In: "query", Type: "string", Description: "comma separated topics, all by default"},
+					{Name: "last_event_id", In: "query", Type: "integer", Description: "resume after this event, like the Last-Event-ID header"},
+				},
+				Response:    GUIUpdate{},
+				ContentType: "text/event-stream",
+				Errors:      []int{http.StatusBadRequest},
+			},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/events/poll",
+			Role:    ROLE_VIEWER,
+			Handler: s.HandlePoll,
+			Doc: openapi.Operation{
+				Summary: "Long-poll the live updates",
+				Params: []openapi.Param{
+					{Name: "topics", In: "query", Type: "string", Description: "comma separated topics, all by default"},
+					{Name: "since", In: "query", Type: "integer", Description: "the last_id of the previous response"},
+					{Name: "timeout", In: "query", Type: "number", Description: "seconds to wait for new events"},
+				},
+				Response: PollResponse{},
+				Errors:   []int{http.StatusBadRequest},
+			},
+		},
+	}
+}
+
+// OpenAPI returns the document of the API
+func OpenAPI() *openapi.Spec {
+	spec := openapi.New("Waze API", API_VERSION, ErrorResponse{})
+	var s *Server
+	for _, route := range s.routes() {
+		doc := route.Doc
+		doc.Method = route.Method
+		doc.Path = route.Path
+		doc.Errors = append([]int{}, doc.Errors...)
+		if route.Role != "" {
+			doc.Errors = append(doc.Errors, http.StatusUnauthorized, http.StatusForbidden)
+		}
+		if route.Limit != "" {
+			doc.Errors = append(doc.Errors, http.StatusTooManyRequests)
+		}
+		spec.Add(doc)
+	}
+	return spec
+}
+
+// RegisterAPI adds the endpoints to the mux, under /api/v1 and under the legacy /api.
+// every endpoint checks the role and the rate limit of its route
+func (s *Server) RegisterAPI(mux *http.ServeMux) {
+	// a path may have a route for every method
+	paths := make([]string, 0)
+	handlers := make(map[string]map[string]http.HandlerFunc)
+	for _, route := range s.routes() {
+		if handlers[route.Path] == nil {
+			handlers[route.Path] = make(map[string]http.HandlerFunc)
+			paths = append(paths, route.Path)
+		}
+		handlers[route.Path][route.Method] = s.authorize(route.Role, route.Limit, route.Handler)
+	}
+	for _, path := range paths {
+		handler := allowMethods(handlers[path])
+		mux.HandleFunc(API_PREFIX+path, handler)
+		mux.HandleFunc(LEGACY_API_PREFIX+path, legacyAPI(handler))
+	}
+
+	spec := OpenAPI()
+	mux.HandleFunc(API_PREFIX+"/openapi.json", allowMethods(map[string]http.HandlerFunc{
+		http.MethodGet: func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusOK, spec)
+		},
+	}))
+	mux.HandleFunc(API_PREFIX+"/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, &APIError{Status: http.StatusNotFound, Code: ERR_NOT_FOUND, Message: "no endpoint " + r.URL.Path})
+	})
+}
+
+// the responses of the legacy API, for the clients of the first API:
+// the errors are plain text like http.Error and the route has an "error" field.
+// the handlers check for it, everything else is the same as /api/v1
+type legacyWriter struct {
+	http.ResponseWriter
+}
+
+func legacyAPI(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handler(legacyWriter{w}, r)
+	}
+}
+
+func isLegacy(w http.ResponseWriter) bool {
+	_, ok := w.(legacyWriter)
+	return ok
+}
+
+// the events stream
+func (w legacyWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// the report stream
+func (w legacyWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+func (w legacyWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// dispatches by the method, other methods get method_not_allowed
+func allowMethods(handlers map[string]http.HandlerFunc) http.HandlerFunc {
+	methods := make([]string, 0, len(handlers))
+	for method := range handlers {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	allowed := strings.Join(methods, ", ")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		handler, ok := handlers[r.Method]
+		if !ok {
+			w.Header().Set("Allow", allowed)
+			writeError(w, &APIError{
+				Status:  http.StatusMethodNotAllowed,
+				Code:    ERR_METHOD_NOT_ALLOWED,
+				Message: "allowed methods: " + allowed,
+			})
+			return
+		}
+		handler(w, r)
+	}
+}
@@ -0,0 +1,360 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"waze/internal/config"
+)
+
+// the roles of the API keys, an admin may do everything
+const (
+	ROLE_REPORTER  = "reporter"  // sends traffic reports and GPS traces
+	ROLE_NAVIGATOR = "navigator" // asks for routes
+	ROLE_VIEWER    = "viewer"    // follows the live updates
+	ROLE_ADMIN     = "admin"
+	// as the anonymous role, requests without a key are refused
+	ROLE_NONE = "none"
+)
+
+var roles = []string{ROLE_REPORTER, ROLE_NAVIGATOR, ROLE_VIEWER, ROLE_ADMIN}
+
+// the kinds of requests that are rate limited, every identity has a token bucket for each
+const (
+	LIMIT_NAVIGATE = "navigate"
+	LIMIT_REPORT   = "report"
+)
+
+// the limits of a key that has none, and of every anonymous address. rates are requests per second
+const (
+	NAVIGATE_RATE  = 10
+	NAVIGATE_BURST = 20
+	REPORT_RATE    = 5
+	REPORT_BURST   = 10
+)
+
+// past this many buckets the full ones are dropped, a full bucket is the same as a new one
+const LIMITER_MAX_BUCKETS = 10000
+
+// APIKey is an entry of the keys file
+type APIKey struct {
+	Name  string   `json:"name"` // the reports of the key are bound to it
+	Key   string   `json:"key"`
+	Roles []string `json:"roles"`
+	// 0-1, the weight of the reports of the key in the edge speeds. 1 when not set
+	Trust *float64 `json:"trust,omitempty"`
+	// requests per second and the burst above them, the defaults when 0
+	NavigateRate  float64 `json:"navigate_rate,omitempty"`
+	NavigateBurst float64 `json:"navigate_burst,omitempty"`
+	ReportRate    float64 `json:"report_rate,omitempty"`
+	ReportBurst   float64 `json:"report_burst,omitempty"`
+}
+
+type AuthOptions struct {
+	KeysFile       string // the API keys, every request is allowed without limits when empty
+	AnonymousRole  string // of the requests without a key, ROLE_NONE refuses them
+	AllowedOrigins string // comma separated, the web pages that may open the WebSocket besides the server's own. * for any
+}
+
+func AuthOptionsFrom(c *config.Config) AuthOptions {
+	return AuthOptions{
+		KeysFile:       c.Server.Auth.KeysFile,
+		AnonymousRole:  c.Server.Auth.AnonymousRole,
+		AllowedOrigins: c.Server.Auth.AllowedOrigins,
+	}
+}
+
+// a token bucket: rate tokens are added every second, up to burst
+type rateLimit struct {
+	Rate  float64
+	Burst float64
+}
+
+// Identity is who sent a request: the key, or an anonymous address
+type Identity struct {
+	Name   string
+	Trust  float64
+	roles  map[string]bool
+	limits map[string]rateLimit // no limit for the kinds that are missing
+}
+
+func (id *Identity) Has(role string) bool {
+	return id.roles[ROLE_ADMIN] || id.roles[role]
+}
+
+// the identity of every request when there are no keys
+var openIdentity = &Identity{Name: "anonymous", Trust: 1, roles: map[string]bool{ROLE_ADMIN: true}}
+
+// Auth is the keys and the rules, replaced as a whole on a reload
+type Auth struct {
+	opts      AuthOptions
+	keys      map[string]*Identity // by the key
+	anonymous map[string]bool      // the roles of the requests without a key
+	origins   map[string]bool
+	anyOrigin bool
+}
+
+func newAuth(opts AuthOptions) (*Auth, error) {
+	a := &Auth{opts: opts, origins: make(map[string]bool)}
+
+	for _, origin := range strings.Split(opts.AllowedOrigins, ",") {
+		origin = strings.TrimSpace(origin)
+		switch {
+		case origin == "":
+		case origin == "*":
+			a.anyOrigin = true
+		default:
+			u, err := url.Parse(origin)
+			if err != nil || u.Scheme == "" || u.Host == "" {
+				return nil, fmt.Errorf("invalid allowed origin %q, must be scheme://host[:port]", origin)
+			}
+			a.origins[u.Scheme+"://"+u.Host] = true
+		}
+	}
+
+	if opts.KeysFile == "" {
+		return a, nil
+	}
+	switch {
+	case opts.AnonymousRole == ROLE_NONE:
+	case isRole(opts.AnonymousRole):
+		a.anonymous = map[string]bool{opts.AnonymousRole: true}
+	default:
+		return nil, fmt.Errorf("unknown anonymous role %q", opts.AnonymousRole)
+	}
+
+	data, err := os.ReadFile(opts.KeysFile)
+	if err != nil {
+		return nil, err
+	}
+	var keys []APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("invalid keys file %s: %w", opts.KeysFile, err)
+	}
+	a.keys = make(map[string]*Identity, len(keys))
+	// the rate limits and the reports are by the name, two keys with a name would share them
+	names := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		id, err := keyIdentity(key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", opts.KeysFile, err)
+		}
+		if _, exists := a.keys[key.Key]; exists {
+			return nil, fmt.Errorf("%s: the key of %q is used twice", opts.KeysFile, key.Name)
+		}
+		if names[key.Name] {
+			return nil, fmt.Errorf("%s: the name %q is used twice", opts.KeysFile, key.Name)
+		}
+		names[key.Name] = true
+		a.keys[key.Key] = id
+	}
+	return a, nil
+}
+
+func keyIdentity(key APIKey) (*Identity, error) {
+	if key.Name == "" || key.Key == "" {
+		return nil, fmt.Errorf("every key must have a name and a key")
+	}
+	// the requests without a key are limited by these names
+	if key.Name == openIdentity.Name || strings.HasPrefix(key.Name, openIdentity.Name+"@") {
+		return nil, fmt.Errorf("the name %q is reserved for the requests without a key", key.Name)
+	}
+	id := &Identity{Name: key.Name, Trust: 1, roles: make(map[string]bool)}
+	for _, role := range key.Roles {
+		if !isRole(role) {
+			return nil, fmt.Errorf("unknown role %q of key %q", role, key.Name)
+		}
+		id.roles[role] = true
+	}
+	if key.Trust != nil {
+		if *key.Trust < 0 || *key.Trust > 1 {
+			return nil, fmt.Errorf("the trust of key %q must be between 0 and 1", key.Name)
+		}
+		id.Trust = *key.Trust
+	}
+	id.limits = map[string]rateLimit{
+		LIMIT_NAVIGATE: {Rate: orDefault(key.NavigateRate, NAVIGATE_RATE), Burst: orDefault(key.NavigateBurst, NAVIGATE_BURST)},
+		LIMIT_REPORT:   {Rate: orDefault(key.ReportRate, REPORT_RATE), Burst: orDefault(key.ReportBurst, REPORT_BURST)},
+	}
+	return id, nil
+}
+
+func orDefault(v, def float64) float64 {
+	if v > 0 {
+		return v
+	}
+	return def
+}
+
+func isRole(role string) bool {
+	for _, known := range roles {
+		if role == known {
+			return true
+		}
+	}
+	return false
+}
+
+// the identity of a request. the key is in the Authorization header as a bearer token, in X-API-Key,
+// or in the api_key query parameter for the WebSocket and EventSource that can't set headers
+func (a *Auth) identify(r *http.Request) (*Identity, error) {
+	key := r.Header.Get("X-API-Key")
+	if bearer, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); found {
+		key = bearer
+	}
+	if key == "" {
+		key = r.URL.Query().Get("api_key")
+	}
+	return a.identifyKey(key, r.RemoteAddr)
+}
+
+// the identity of a key, or of an anonymous client at addr when the key is empty
+func (a *Auth) identifyKey(key, addr string) (*Identity, error) {
+	if a.keys == nil {
+		return openIdentity, nil
+	}
+	if key != "" {
+		if id, exists := a.keys[key]; exists {
+			return id, nil
+		}
+		return nil, &APIError{Status: http.StatusUnauthorized, Code: ERR_UNAUTHORIZED, Message: "invalid API key"}
+	}
+	if a.anonymous == nil {
+		return nil, &APIError{Status: http.StatusUnauthorized, Code: ERR_UNAUTHORIZED, Message: "an API key is required"}
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	return &Identity{
+		Name:  "anonymous@" + host,
+		Trust: 1,
+		roles: a.anonymous,
+		limits: map[string]rateLimit{
+			LIMIT_NAVIGATE: {Rate: NAVIGATE_RATE, Burst: NAVIGATE_BURST},
+			LIMIT_REPORT:   {Rate: REPORT_RATE, Burst: REPORT_BURST},
+		},
+	}, nil
+}
+
+// whether a browser page of the origin may open the WebSocket. clients that are not browsers send no origin
+func (a *Auth) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || a.anyOrigin {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host) || a.origins[u.Scheme+"://"+u.Host]
+}
+
+type identityKey struct{}
+
+// IdentityFrom returns the identity of an authorized request
+func IdentityFrom(ctx context.Context) *Identity {
+	if id, ok := ctx.Value(identityKey{}).(*Identity); ok {
+		return id
+	}
+	return openIdentity
+}
+
+// authorize lets the request through when its identity has the role and a token of the limit is left.
+// an empty role or limit is not checked
+func (s *Server) authorize(role, limit string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := s.auth.Load().identify(r)
+		if err != nil {
+			s.m.authRejected.With(ERR_UNAUTHORIZED).Inc()
+			writeError(w, err)
+			return
+		}
+		if err := s.admit(id, role, limit); err != nil {
+			writeError(w, err)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, id)))
+	}
+}
+
+// checks that the identity has the role and takes a token of the limit, for the HTTP and the gRPC API
+func (s *Server) admit(id *Identity, role, limit string) error {
+	if role != "" && !id.Has(role) {
+		s.m.authRejected.With(ERR_FORBIDDEN).Inc()
+		return &APIError{Status: http.StatusForbidden, Code: ERR_FORBIDDEN, Message: fmt.Sprintf("the %s role is required", role)}
+	}
+	if l, limited := id.limits[limit]; limited {
+		if wait, ok := s.limiter.take(id.Name+" "+limit, l, time.Now()); !ok {
+			s.m.authRejected.With(ERR_RATE_LIMITED).Inc()
+			return &APIError{
+				Status:     http.StatusTooManyRequests,
+				Code:       ERR_RATE_LIMITED,
+				Message:    fmt.Sprintf("too many %s requests", limit),
+				RetryAfter: int(math.Ceil(wait.Seconds())),
+			}
+		}
+	}
+	return nil
+}
+
+type bucket struct {
+	limit  rateLimit
+	tokens float64
+	last   time.Time
+}
+
+// the token buckets of all the identities
+type limiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func newLimiter() *limiter {
+	return &limiter{buckets: make(map[string]*bucket)}
+}
+
+// takes a token from the bucket of name. when there is none it returns how long until there is
+func (l *limiter) take(name string, limit rateLimit, now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.buckets) >= LIMITER_MAX_BUCKETS {
+		l.dropFull(now)
+	}
+	b, exists := l.buckets[name]
+	if !exists {
+		b = &bucket{tokens: limit.Burst, last: now}
+		l.buckets[name] = b
+	}
+	// the limit of a key may change on a reload
+	b.limit = limit
+	b.tokens = math.Min(limit.Burst, b.refill(now))
+	b.last = now
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second)), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+func (b *bucket) refill(now time.Time) float64 {
+	return b.tokens + now.Sub(b.last).Seconds()*b.limit.Rate
+}
+
+// drops the buckets that refilled by now
+func (l *limiter) dropFull(now time.Time) {
+	for name, b := range l.buckets {
+		if b.refill(now) >= b.limit.Burst {
+			delete(l.buckets, name)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// the time allowed to write a message to the client
+	WRITE_WAIT = 10 * time.Second
+	// the client must answer a ping within this time
+	PONG_WAIT = 60 * time.Second
+	// pings are sent a bit more often than PONG_WAIT
+	PING_PERIOD = PONG_WAIT * 9 / 10
+	// the biggest message a client may send, subscriptions are small
+	MAX_MESSAGE_SIZE = 4096
+
+	// messages waiting to be written to a client. when the queue is full the oldest message is dropped
+	SEND_QUEUE_SIZE = 64
+	// a client that had this many messages dropped without any write in between is disconnected
+	MAX_LAGGING_DROPS = 256
+)
+
+// a message waiting in the queue of a client
+type wsMessage struct {
+	messageType int // websocket.TextMessage or websocket.BinaryMessage
+	data        []byte
+}
+
+// Client is a single WebSocket connection. only its writer goroutine writes to the connection,
+// everyone else puts messages in its send queue and never waits for the network
+type Client struct {
+	conn *websocket.Conn
+	send chan wsMessage
+
+	// the client speaks the binary protocol, otherwise it gets JSON text messages
+	binary bool
+
+	// messages dropped since the last successful write
+	lagging atomic.Int64
+
+	// owned by the hub loop
+	sub      *Subscription
+	view     *clientView
+	pending  bool // the live state changed since the last car update of the client
+	lastSent time.Time
+}
+
+func newClient(conn *websocket.Conn, binary bool) *Client {
+	return &Client{
+		conn:   conn,
+		send:   make(chan wsMessage, SEND_QUEUE_SIZE),
+		binary: binary,
+		sub:    defaultSubscription(),
+		view:   newClientView(),
+	}
+}
+
+// enqueue puts a message in the send queue without blocking, dropping the oldest message when it is full
+// (a binary client that misses a frame asks for a keyframe). it returns whether a message was dropped.
+// must not be called after the queue was closed
+func (c *Client) enqueue(messageType int, data []byte) (dropped bool) {
+	message := wsMessage{messageType: messageType, data: data}
+	for {
+		select {
+		case c.send <- message:
+			return dropped
+		default:
+		}
+		select {
+		case <-c.send:
+			c.lagging.Add(1)
+			dropped = true
+		default:
+		}
+	}
+}
+
+// writePump writes the queued messages and the pings, until the queue is closed or a write fails
+func (c *Client) writePump() {
+	ticker := time.NewTicker(PING_PERIOD)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case message, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(WRITE_WAIT))
+			if !ok {
+				// the hub closed the queue
+				c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+			if err := c.conn.WriteMessage(message.messageType, message.data); err != nil {
+				log.Printf("WebSocket write error: %v", err)
+				return
+			}
+			c.lagging.Store(0)
+
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(WRITE_WAIT))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readPump reads the messages of the client and passes them to handle, until the connection fails.
+// a client that stops answering pings is disconnected
+func (c *Client) readPump(handle func(data []byte)) {
+	c.conn.SetReadLimit(MAX_MESSAGE_SIZE)
+	c.conn.SetReadDeadline(time.Now().Add(PONG_WAIT))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(PONG_WAIT))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		handle(data)
+	}
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"time"
+	"waze/internal/graph"
+)
+
+// how often the congestion of the edges is sent to the GUI
+const CONGESTION_INTERVAL = 2 * time.Second
+
+// an edge this much slower than its limit, with enough confidence, is flagged as a probable incident
+const (
+	INCIDENT_RATIO      = 0.25
+	INCIDENT_CONFIDENCE = 0.5
+)
+
+// the congestion of an edge as the router sees it
+type EdgeCongestion struct {
+	EdgeID     int     `json:"edge_id"`
+	Speed      float64 `json:"speed"`      // KM/hour, the speed limit when nothing was reported
+	Ratio      float64 `json:"ratio"`      // current speed / speed limit
+	Confidence float64 `json:"confidence"` // 0-1
+	Incident   bool    `json:"incident"`
+}
+
+// the congestion of every edge of the graph
+func edgeCongestion(g *graph.Graph, now time.Time) []EdgeCongestion {
+	congestion := make([]EdgeCongestion, 0, len(g.Edges))
+	for _, edge := range g.Edges {
+		congestion = append(congestion, congestionOf(edge, now))
+	}
+	return congestion
+}
+
+func congestionOf(edge *graph.Edge, now time.Time) EdgeCongestion {
+	speed := edge.RoutingSpeed()
+	if speed <= 0 {
+		speed = edge.SpeedLimit
+	}
+	ratio := 1.0
+	if edge.SpeedLimit > 0 {
+		ratio = speed / edge.SpeedLimit
+	}
+	confidence := edge.Confidence(now)
+
+	return EdgeCongestion{
+		EdgeID:     edge.Id,
+		Speed:      speed,
+		Ratio:      ratio,
+		Confidence: confidence,
+		Incident:   ratio < INCIDENT_RATIO && confidence >= INCIDENT_CONFIDENCE,
+	}
+}
+
+// sends the congestion of the edges to the GUI every CONGESTION_INTERVAL until the server stops,
+// the clients get only the edges that changed
+func (s *Server) broadcastCongestion() {
+	ticker := time.NewTicker(CONGESTION_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			s.Hub.BroadcastEdges(edgeCongestion(s.Map().Graph, now))
+		case <-s.stop:
+			return
+		}
+	}
+}
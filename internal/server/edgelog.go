@@ -0,0 +1,152 @@
+package server
+
+import (
+	"sync"
+	"time"
+	"waze/internal/graph"
+	"waze/internal/types"
+)
+
+// what is kept of every edge for the admin API
+const (
+	EDGE_RECENT_REPORTS = 20          // the last reports applied to the edge
+	HISTORY_INTERVAL    = time.Minute // the speed of the reported edges is sampled this often
+	HISTORY_SIZE        = 60          // samples of an edge, an hour
+)
+
+// a report that was applied to an edge
+type ReportRecord struct {
+	CarID    int       `json:"car_id"`
+	Speed    float64   `json:"speed"` // KM/hour
+	Progress float64   `json:"progress"`
+	Reporter string    `json:"reporter,omitempty"`
+	Time     time.Time `json:"time"` // when the server applied it
+}
+
+// the congestion of an edge at a time
+type SpeedSample struct {
+	Time       time.Time `json:"time"`
+	Speed      float64   `json:"speed"` // KM/hour, as the routing saw it
+	Ratio      float64   `json:"ratio"`
+	Confidence float64   `json:"confidence"`
+	Incident   bool      `json:"incident,omitempty"`
+}
+
+// a time the edge was flagged as an incident, by the samples of its history
+type IncidentPeriod struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"` // the last sample that was flagged
+	MinRatio float64   `json:"min_ratio"`
+	Ongoing  bool      `json:"ongoing"`
+}
+
+// the last reports and the speed history of the edges, by edge id. only the reported edges have them
+type edgeLog struct {
+	mu      sync.Mutex
+	reports map[int][]ReportRecord
+	history map[int][]SpeedSample
+}
+
+func newEdgeLog() *edgeLog {
+	return &edgeLog{reports: make(map[int][]ReportRecord), history: make(map[int][]SpeedSample)}
+}
+
+// keeps the reports that were applied to g
+func (l *edgeLog) record(g *graph.Graph, reports []types.TrafficReport, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, report := range reports {
+		if _, exists := g.Edges[report.EdgeID]; !exists || report.CarID == -1 || !validSpeed(g, report.Speed) {
+			continue
+		}
+		records := append(l.reports[report.EdgeID], ReportRecord{
+			CarID:    report.CarID,
+			Speed:    report.Speed,
+			Progress: report.Progress,
+			Reporter: report.Reporter,
+			Time:     now,
+		})
+		if len(records) > EDGE_RECENT_REPORTS {
+			records = records[len(records)-EDGE_RECENT_REPORTS:]
+		}
+		l.reports[report.EdgeID] = records
+	}
+}
+
+// adds a sample of every edge that was reported or has an override
+func (l *edgeLog) sample(g *graph.Graph, now time.Time) {
+	congestion := edgeCongestion(g, now)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, c := range congestion {
+		edge := g.Edges[c.EdgeID]
+		if edge.ReportCount() == 0 && edge.Override() == 0 && l.history[c.EdgeID] == nil {
+			continue
+		}
+		samples := append(l.history[c.EdgeID], SpeedSample{
+			Time:       now,
+			Speed:      c.Speed,
+			Ratio:      c.Ratio,
+			Confidence: c.Confidence,
+			Incident:   c.Incident,
+		})
+		if len(samples) > HISTORY_SIZE {
+			samples = samples[len(samples)-HISTORY_SIZE:]
+		}
+		l.history[c.EdgeID] = samples
+	}
+}
+
+// the reports and the history of an edge, newest last
+func (l *edgeLog) get(edgeId int) ([]ReportRecord, []SpeedSample) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]ReportRecord{}, l.reports[edgeId]...), append([]SpeedSample{}, l.history[edgeId]...)
+}
+
+// forgets everything, the edge ids of a new map may be other roads
+func (l *edgeLog) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.reports = make(map[int][]ReportRecord)
+	l.history = make(map[int][]SpeedSample)
+}
+
+// the incidents in the history of an edge, oldest first
+func incidentPeriods(history []SpeedSample) []IncidentPeriod {
+	periods := make([]IncidentPeriod, 0)
+	var current *IncidentPeriod
+	for _, sample := range history {
+		if !sample.Incident {
+			current = nil
+			continue
+		}
+		if current == nil {
+			periods = append(periods, IncidentPeriod{Start: sample.Time, MinRatio: sample.Ratio})
+			current = &periods[len(periods)-1]
+		}
+		current.End = sample.Time
+		current.MinRatio = min(current.MinRatio, sample.Ratio)
+	}
+	if len(periods) > 0 && len(history) > 0 && history[len(history)-1].Incident {
+		periods[len(periods)-1].Ongoing = true
+	}
+	return periods
+}
+
+// samples the history every HISTORY_INTERVAL until the server stops
+func (s *Server) recordHistory() {
+	ticker := time.NewTicker(HISTORY_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			s.edgeLog.sample(s.Map().Graph, now)
+		case <-s.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"waze/internal/navigation"
+)
+
+// the machine readable codes of the API errors
+const (
+	ERR_INVALID_PARAMS     = "invalid_params"
+	ERR_NODE_NOT_FOUND     = "node_not_found"
+	ERR_NO_ROUTE           = "no_route"
+	ERR_OVERLOADED         = "overloaded"
+	ERR_TIMEOUT            = "timeout"
+	ERR_METHOD_NOT_ALLOWED = "method_not_allowed"
+	ERR_NOT_FOUND          = "not_found"
+	ERR_RELOAD_FAILED      = "reload_failed"
+	ERR_UNAUTHORIZED       = "unauthorized"
+	ERR_FORBIDDEN          = "forbidden"
+	ERR_RATE_LIMITED       = "rate_limited"
+	ERR_INTERNAL           = "internal"
+)
+
+// APIError is the body of every failed API request, under "error"
+type APIError struct {
+	Status     int    `json:"-"`
+	RetryAfter int    `json:"-"` // seconds, sent in the Retry-After header when set
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+type ErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+func invalidParams(format string, args ...interface{}) *APIError {
+	return &APIError{Status: http.StatusBadRequest, Code: ERR_INVALID_PARAMS, Message: fmt.Sprintf(format, args...)}
+}
+
+func overloaded(format string, args ...interface{}) *APIError {
+	return &APIError{
+		Status:     http.StatusServiceUnavailable,
+		Code:       ERR_OVERLOADED,
+		Message:    fmt.Sprintf(format, args...),
+		RetryAfter: RETRY_AFTER,
+	}
+}
+
+// the API error of an error, errors that are not known become internal errors
+func toAPIError(err error) *APIError {
+	var apiErr *APIError
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.Is(err, navigation.ErrNodeNotFound):
+		return &APIError{Status: http.StatusNotFound, Code: ERR_NODE_NOT_FOUND, Message: err.Error()}
+	case errors.Is(err, navigation.ErrNoRoute):
+		return &APIError{Status: http.StatusNotFound, Code: ERR_NO_ROUTE, Message: err.Error()}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &APIError{Status: http.StatusServiceUnavailable, Code: ERR_TIMEOUT, Message: "the request took too long", RetryAfter: RETRY_AFTER}
+	default:
+		log.Printf("Internal error: %v", err)
+		return &APIError{Status: http.StatusInternalServerError, Code: ERR_INTERNAL, Message: "internal error"}
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	apiErr := toAPIError(err)
+	if apiErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(apiErr.RetryAfter))
+	}
+	if isLegacy(w) {
+		http.Error(w, apiErr.Message, apiErr.Status)
+		return
+	}
+	writeJSON(w, apiErr.Status, ErrorResponse{Error: *apiErr})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// how many events are kept for clients that reconnect
+const EVENT_BUFFER_SIZE = 256
+
+// a GUI update with its place in the stream
+type Event struct {
+	ID     uint64          `json:"id"`
+	Type   string          `json:"-"`
+	Update json.RawMessage `json:"update"` // the GUIUpdate
+}
+
+// EventLog keeps the last GUI updates in order, for the SSE and long-poll clients.
+// the ids start at 1 and never repeat, so a client can ask for everything after the last id it saw
+type EventLog struct {
+	mu     sync.Mutex
+	events []Event // oldest first, at most EVENT_BUFFER_SIZE
+	lastID uint64
+	// closed and replaced on every new event, to wake up the waiting clients
+	notify chan struct{}
+}
+
+func NewEventLog() *EventLog {
+	return &EventLog{
+		events: make([]Event, 0, EVENT_BUFFER_SIZE),
+		notify: make(chan struct{}),
+	}
+}
+
+func (l *EventLog) Append(updateType string, data interface{}) error {
+	jsonData, err := json.Marshal(GUIUpdate{Type: updateType, Data: data})
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.lastID++
+	if len(l.events) == EVENT_BUFFER_SIZE {
+		copy(l.events, l.events[1:])
+		l.events = l.events[:len(l.events)-1]
+	}
+	l.events = append(l.events, Event{ID: l.lastID, Type: updateType, Update: jsonData})
+
+	close(l.notify)
+	l.notify = make(chan struct{})
+	return nil
+}
+
+// Since returns the events after lastID, and a channel that is closed when there is a newer event.
+// ok is false when events after lastID were already dropped from the buffer
+func (l *EventLog) Since(lastID uint64) (events []Event, ok bool, changed <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if lastID > l.lastID {
+		// an id of another run of the server
+		return nil, false, l.notify
+	}
+	if len(l.events) > 0 && lastID+1 < l.events[0].ID {
+		return nil, false, l.notify
+	}
+
+	for i, event := range l.events {
+		if event.ID > lastID {
+			events = make([]Event, len(l.events)-i)
+			copy(events, l.events[i:])
+			break
+		}
+	}
+	return events, true, l.notify
+}
+
+func (l *EventLog) LastID() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lastID
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"waze/internal/matching"
+	"waze/internal/types"
+)
+
+// every trace is matched on the request, these bound the work of a single request
+const (
+	MAX_GPS_TRACES_PER_REQUEST = 1000
+	MAX_GPS_POINTS_PER_TRACE   = 600 // ten minutes of a fix every second
+)
+
+// the result of matching a single trace
+type MatchedTrace struct {
+	CarID int                    `json:"car_id"`
+	Edges []matching.MatchedEdge `json:"edges"`
+	Error string                 `json:"error,omitempty"`
+}
+
+// HandleGPSTraces receives raw GPS traces, matches them to the map and updates the edge speeds
+func (s *Server) HandleGPSTraces(w http.ResponseWriter, r *http.Request) {
+	var traces []types.GPSTrace
+	if err := json.NewDecoder(r.Body).Decode(&traces); err != nil {
+		writeError(w, invalidParams("invalid JSON: %v", err))
+		return
+	}
+	if len(traces) > MAX_GPS_TRACES_PER_REQUEST {
+		writeError(w, invalidParams("at most %d traces in a request, got %d", MAX_GPS_TRACES_PER_REQUEST, len(traces)))
+		return
+	}
+	for _, trace := range traces {
+		if len(trace.Points) > MAX_GPS_POINTS_PER_TRACE {
+			writeError(w, invalidParams("at most %d points in a trace, the trace of car %d has %d", MAX_GPS_POINTS_PER_TRACE, trace.CarID, len(trace.Points)))
+			return
+		}
+	}
+
+	current := s.Map()
+	reporter := IdentityFrom(r.Context())
+	results := make([]MatchedTrace, 0, len(traces))
+	reports := make([]types.TrafficReport, 0)
+	// only the last position of every car is shown in the GUI
+	lastReports := make([]types.TrafficReport, 0, len(traces))
+
+	for _, trace := range traces {
+		result := MatchedTrace{CarID: trace.CarID}
+
+		edges, err := current.Matcher.Match(trace.Points)
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+		result.Edges = edges
+		results = append(results, result)
+
+		for _, edge := range edges {
+			reports = append(reports, types.TrafficReport{
+				CarID:     trace.CarID,
+				Reporter:  reporter.Name,
+				EdgeID:    edge.EdgeID,
+				Speed:     edge.Speed,
+				Timestamp: int64(edge.ExitTime),
+			})
+		}
+		if len(edges) > 0 {
+			// the car is where the last fix is, on the last matched edge
+			last := reports[len(reports)-1]
+			last.Progress = current.Matcher.ProgressOn(last.EdgeID, lastFix(trace.Points))
+			lastReports = append(lastReports, last)
+		}
+	}
+
+	s.updateSpeeds(current.Graph, reports, reporter.Trust)
+	s.broadcastCars(current.Graph, lastReports)
+
+	writeJSON(w, http.StatusOK, results)
+}
+
+// the latest fix of the trace
+func lastFix(points []types.GPSPoint) types.GPSPoint {
+	last := points[0]
+	for _, point := range points[1:] {
+		if point.Timestamp >= last.Timestamp {
+			last = point
+		}
+	}
+	return last
+}
@@ -0,0 +1,495 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"strings"
+	"time"
+	"waze/internal/navigation"
+	"waze/internal/spatial"
+	"waze/internal/types"
+	"waze/internal/wazepb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// a watched route is searched again this often by default, and not more often than the minimum.
+// the watch ends after MAX_WATCH_ROUTE_DURATION, the client may watch again
+const (
+	WATCH_ROUTE_INTERVAL     = 5 * time.Second
+	MIN_WATCH_ROUTE_INTERVAL = 1 * time.Second
+	MAX_WATCH_ROUTE_DURATION = time.Hour
+)
+
+// a watched route is sent again when its ETA changed by this many minutes, or when it takes other roads
+const WATCH_ROUTE_ETA_EPSILON = 0.5
+
+// the role and the rate limit of every gRPC method, like the Route of an HTTP endpoint.
+// ReportTraffic takes a report token for every batch instead of one for the stream,
+// and WatchRoute a navigate token for every search after the first
+var grpcMethods = map[string]struct{ role, limit string }{
+	wazepb.Waze_Route_FullMethodName:         {ROLE_NAVIGATOR, LIMIT_NAVIGATE},
+	wazepb.Waze_Matrix_FullMethodName:        {ROLE_NAVIGATOR, LIMIT_NAVIGATE},
+	wazepb.Waze_ReportTraffic_FullMethodName: {ROLE_REPORTER, ""},
+	wazepb.Waze_WatchTraffic_FullMethodName:  {ROLE_VIEWER, ""},
+	wazepb.Waze_WatchRoute_FullMethodName:    {ROLE_NAVIGATOR, LIMIT_NAVIGATE},
+}
+
+// the gRPC status codes of the API errors
+var grpcCodes = map[string]codes.Code{
+	ERR_INVALID_PARAMS: codes.InvalidArgument,
+	ERR_NODE_NOT_FOUND: codes.NotFound,
+	ERR_NO_ROUTE:       codes.NotFound,
+	ERR_OVERLOADED:     codes.Unavailable,
+	ERR_TIMEOUT:        codes.DeadlineExceeded,
+	ERR_UNAUTHORIZED:   codes.Unauthenticated,
+	ERR_FORBIDDEN:      codes.PermissionDenied,
+	ERR_RATE_LIMITED:   codes.ResourceExhausted,
+}
+
+// the gRPC API, on the same server as the HTTP API
+type grpcService struct {
+	wazepb.UnimplementedWazeServer
+	s *Server
+}
+
+// the gRPC server of s, its requests are authorized with the same keys as the HTTP requests
+func (s *Server) newGRPCServer() *grpc.Server {
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(s.unaryAuth),
+		grpc.StreamInterceptor(s.streamAuth),
+	)
+	wazepb.RegisterWazeServer(server, &grpcService{s: s})
+	return server
+}
+
+// the status of an error, errors that are not API errors become internal errors like in writeError
+func grpcError(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return status.Error(codes.Canceled, err.Error())
+	}
+	apiErr := toAPIError(err)
+	code, known := grpcCodes[apiErr.Code]
+	if !known {
+		code = codes.Internal
+	}
+	return status.Error(code, apiErr.Message)
+}
+
+// the identity of a gRPC call, by the key in the authorization metadata as a bearer token or in x-api-key
+func (s *Server) grpcIdentity(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	key := ""
+	if values := md.Get("x-api-key"); len(values) > 0 {
+		key = values[0]
+	}
+	if values := md.Get("authorization"); len(values) > 0 {
+		if bearer, found := strings.CutPrefix(values[0], "Bearer "); found {
+			key = bearer
+		}
+	}
+	addr := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		addr = p.Addr.String()
+	}
+
+	id, err := s.auth.Load().identifyKey(key, addr)
+	if err != nil {
+		s.m.authRejected.With(ERR_UNAUTHORIZED).Inc()
+		return nil, grpcError(err)
+	}
+	rule := grpcMethods[method]
+	if err := s.admit(id, rule.role, rule.limit); err != nil {
+		return nil, grpcError(err)
+	}
+	return context.WithValue(ctx, identityKey{}, id), nil
+}
+
+func (s *Server) unaryAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.grpcIdentity(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) streamAuth(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.grpcIdentity(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &identifiedStream{ServerStream: stream, ctx: ctx})
+}
+
+// a stream whose context has the identity of the caller
+type identifiedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *identifiedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (g *grpcService) Route(ctx context.Context, req *wazepb.RouteRequest) (*wazepb.RouteResponse, error) {
+	response, err := g.s.Navigate(ctx, navigationRequest(req))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	g.s.Hub.BroadcastUpdate(TOPIC_ROUTES, response)
+	return routeResponse(response), nil
+}
+
+func (g *grpcService) Matrix(ctx context.Context, req *wazepb.MatrixRequest) (*wazepb.MatrixResponse, error) {
+	m := g.s.Map()
+	profile, err := navigation.GetProfile(req.Profile)
+	if err != nil {
+		return nil, grpcError(invalidParams("%v", err))
+	}
+
+	response := &wazepb.MatrixResponse{}
+	sources := make([]int, len(req.Sources))
+	for i, waypoint := range req.Sources {
+		if sources[i], err = m.waypointNode("sources", waypoint); err != nil {
+			return nil, grpcError(err)
+		}
+		response.SourceNodes = append(response.SourceNodes, int32(sources[i]))
+	}
+	destinations := make([]int, len(req.Destinations))
+	for i, waypoint := range req.Destinations {
+		if destinations[i], err = m.waypointNode("destinations", waypoint); err != nil {
+			return nil, grpcError(err)
+		}
+		response.DestinationNodes = append(response.DestinationNodes, int32(destinations[i]))
+	}
+
+	matrix, err := g.s.Matrix(ctx, m, sources, destinations, profile)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	for _, routes := range matrix {
+		row := &wazepb.MatrixRow{Cells: make([]*wazepb.MatrixCell, len(routes))}
+		for j, route := range routes {
+			row.Cells[j] = &wazepb.MatrixCell{}
+			if route != nil {
+				row.Cells[j] = &wazepb.MatrixCell{Found: true, Eta: route.ETA, Distance: route.Distance}
+			}
+		}
+		response.Rows = append(response.Rows, row)
+	}
+	return response, nil
+}
+
+// the node of a waypoint of a matrix, a location is snapped to the nearest node
+func (m *Map) waypointNode(name string, waypoint *wazepb.Waypoint) (int, error) {
+	switch point := waypoint.GetPoint().(type) {
+	case *wazepb.Waypoint_NodeId:
+		return int(point.NodeId), nil
+	case *wazepb.Waypoint_Location:
+		return m.nearestNode(types.Location{X: point.Location.GetX(), Y: point.Location.GetY()})
+	default:
+		return 0, invalidParams("every waypoint of '%s' needs a node id or a location", name)
+	}
+}
+
+func (g *grpcService) ReportTraffic(stream wazepb.Waze_ReportTrafficServer) error {
+	reporter := IdentityFrom(stream.Context())
+	summary := &wazepb.ReportSummary{}
+	for {
+		batch, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(summary)
+		}
+		if err != nil {
+			return err
+		}
+		if err := g.s.admit(reporter, "", LIMIT_REPORT); err != nil {
+			return grpcError(err)
+		}
+
+		reports := make([]types.TrafficReport, len(batch.Reports))
+		for i, report := range batch.Reports {
+			reports[i] = types.TrafficReport{
+				CarID:     int(report.CarId),
+				EdgeID:    int(report.EdgeId),
+				Speed:     report.Speed,
+				Progress:  report.Progress,
+				Heading:   report.Heading,
+				Timestamp: report.Timestamp,
+			}
+		}
+		summary.Batches++
+		summary.Received += int64(len(reports))
+		summary.Applied += int64(g.s.Report(reporter, reports))
+
+		// the stream would keep the shutdown waiting, the client opens a new one on another server
+		select {
+		case <-g.s.draining:
+			return status.Error(codes.Unavailable, "the server is shutting down")
+		default:
+		}
+	}
+}
+
+func (g *grpcService) WatchTraffic(req *wazepb.WatchTrafficRequest, stream wazepb.Waze_WatchTrafficServer) error {
+	msg := ClientMessage{Topics: req.Topics, Rate: req.Rate}
+	if len(req.Topics) == 0 {
+		msg.Topics = []string{TOPIC_CARS, TOPIC_EDGES}
+	}
+	if box := req.Bbox; box != nil {
+		msg.Bbox = &spatial.Box{MinX: box.MinX, MinY: box.MinY, MaxX: box.MaxX, MaxY: box.MaxY}
+	}
+	sub, err := newSubscription(msg, g.s.Map().Matcher.Index)
+	if err != nil {
+		return grpcError(invalidParams("%v", err))
+	}
+	return g.s.watchTraffic(stream.Context(), sub, stream.Send)
+}
+
+// sends the frames of a client with the subscription, the same as the binary WebSocket clients get,
+// until ctx is done or the server shuts down. the event log wakes it up on every change of the live state
+func (s *Server) watchTraffic(ctx context.Context, sub *Subscription, send func(*wazepb.TrafficUpdate) error) error {
+	m := s.Map()
+	view := newClientView()
+	lastID := s.Hub.events.LastID()
+	keyframe, reloaded, pending := true, false, true
+	var lastSent time.Time
+
+	for {
+		// the hub logs an init event once the live state of a new map was cleared
+		reset := false
+		events, ok, changed := s.Hub.events.Since(lastID)
+		if !ok {
+			// the events were missed, a reload among them is found by the map
+			lastID = s.Hub.events.LastID()
+			reset = s.Map() != m
+			pending = true
+		}
+		for _, event := range events {
+			lastID = event.ID
+			reset = reset || event.Type == EVENT_INIT
+			pending = true
+		}
+		if reset {
+			m = s.Map()
+			sub = sub.reindex(m.Matcher.Index)
+			view = newClientView()
+			keyframe, reloaded = true, true
+		}
+
+		var retry <-chan time.Time
+		if pending {
+			if now := time.Now(); keyframe || sub.Due(lastSent, now) {
+				s.Hub.live.mu.RLock()
+				frame := view.nextFrame(s.Hub.live, sub, keyframe)
+				s.Hub.live.mu.RUnlock()
+				if frame != nil {
+					if err := send(trafficUpdate(frame, reloaded)); err != nil {
+						return err
+					}
+				}
+				keyframe, reloaded, pending = false, false, false
+				lastSent = now
+			} else {
+				retry = time.After(FLUSH_INTERVAL)
+			}
+		}
+
+		select {
+		case <-changed:
+		case <-retry:
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-s.draining:
+			return status.Error(codes.Unavailable, "the server is shutting down")
+		}
+	}
+}
+
+func (g *grpcService) WatchRoute(req *wazepb.WatchRouteRequest, stream wazepb.Waze_WatchRouteServer) error {
+	interval := WATCH_ROUTE_INTERVAL
+	if req.IntervalSeconds > 0 {
+		// clamped before the conversion, a big interval would overflow the duration
+		seconds := math.Min(req.IntervalSeconds, MAX_WATCH_ROUTE_DURATION.Seconds())
+		interval = max(time.Duration(seconds*float64(time.Second)), MIN_WATCH_ROUTE_INTERVAL)
+	}
+	nav := navigationRequest(req.Route)
+	ctx := stream.Context()
+	id := IdentityFrom(ctx)
+	expired := time.After(MAX_WATCH_ROUTE_DURATION)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last *types.NavigationResponse
+	for searches := 0; ; searches++ {
+		// the first search took its token when the stream was opened. a search without a token is skipped,
+		// the watch searches at most as often as the key may navigate
+		if searches > 0 && g.s.admit(id, "", LIMIT_NAVIGATE) != nil {
+			if err := g.waitWatch(ctx, ticker, expired); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// the search runs on the routing workers like every navigation
+		response, err := g.s.Navigate(ctx, nav)
+		if ctx.Err() != nil {
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		// the first search must succeed, a later failure is usually an overloaded server and the next one may not fail
+		if err != nil && last == nil {
+			return grpcError(err)
+		}
+		if err == nil && (last == nil || routeChanged(last, response)) {
+			if err := stream.Send(routeResponse(response)); err != nil {
+				return err
+			}
+			last = response
+		}
+
+		if err := g.waitWatch(ctx, ticker, expired); err != nil {
+			return err
+		}
+	}
+}
+
+// waits for the next search of a watched route, the error ends the watch
+func (g *grpcService) waitWatch(ctx context.Context, ticker *time.Ticker, expired <-chan time.Time) error {
+	select {
+	case <-ticker.C:
+		return nil
+	case <-expired:
+		return status.Errorf(codes.DeadlineExceeded, "a route is watched for at most %s, watch it again to go on", MAX_WATCH_ROUTE_DURATION)
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	case <-g.s.draining:
+		return status.Error(codes.Unavailable, "the server is shutting down")
+	}
+}
+
+func routeChanged(last, route *types.NavigationResponse) bool {
+	if len(last.RouteNodes) != len(route.RouteNodes) {
+		return true
+	}
+	for i, id := range last.RouteNodes {
+		if route.RouteNodes[i] != id {
+			return true
+		}
+	}
+	return math.Abs(last.ETA-route.ETA) >= WATCH_ROUTE_ETA_EPSILON
+}
+
+func navigationRequest(req *wazepb.RouteRequest) types.NavigationRequest {
+	nav := types.NavigationRequest{
+		Profile:          req.GetProfile(),
+		AvoidRoadClasses: req.GetAvoidRoadClasses(),
+		Alternatives:     int(req.GetAlternatives()),
+		Geometry:         req.Geometry,
+		Instructions:     req.GetInstructions(),
+		Timeout:          req.GetTimeoutSeconds(),
+	}
+	switch from := req.GetFrom().GetPoint().(type) {
+	case *wazepb.Waypoint_NodeId:
+		id := int(from.NodeId)
+		nav.FromNodeId = &id
+	case *wazepb.Waypoint_Location:
+		nav.From = &types.Location{X: from.Location.GetX(), Y: from.Location.GetY()}
+	}
+	switch to := req.GetTo().GetPoint().(type) {
+	case *wazepb.Waypoint_NodeId:
+		id := int(to.NodeId)
+		nav.ToNodeId = &id
+	case *wazepb.Waypoint_Location:
+		nav.To = &types.Location{X: to.Location.GetX(), Y: to.Location.GetY()}
+	}
+	if req.GetDepartureTime() != nil {
+		departure := req.GetDepartureTime().AsTime()
+		nav.DepartureTime = &departure
+	}
+	for _, id := range req.GetAvoidEdges() {
+		nav.AvoidEdges = append(nav.AvoidEdges, int(id))
+	}
+	return nav
+}
+
+func routeResponse(response *types.NavigationResponse) *wazepb.RouteResponse {
+	route := &wazepb.RouteResponse{
+		Route:         make([]int32, len(response.RouteNodes)),
+		Eta:           response.ETA,
+		Distance:      response.Distance,
+		Profile:       response.Profile,
+		Geometry:      response.Geometry,
+		FromNode:      int32(response.FromNodeId),
+		ToNode:        int32(response.ToNodeId),
+		DepartureTime: timestamppb.New(response.DepartureTime),
+		ArrivalTime:   timestamppb.New(response.ArrivalTime),
+	}
+	for i, id := range response.RouteNodes {
+		route.Route[i] = int32(id)
+	}
+	for _, instruction := range response.Instructions {
+		route.Instructions = append(route.Instructions, &wazepb.Instruction{
+			Type:     instruction.Type,
+			Modifier: instruction.Modifier,
+			Street:   instruction.Street,
+			Exit:     int32(instruction.Exit),
+			NodeId:   int32(instruction.NodeId),
+			Distance: instruction.Distance,
+			Duration: instruction.Duration,
+			Text:     instruction.Text,
+		})
+	}
+	for i := range response.Alternatives {
+		route.Alternatives = append(route.Alternatives, routeResponse(&response.Alternatives[i]))
+	}
+	return route
+}
+
+func trafficUpdate(frame *Frame, reloaded bool) *wazepb.TrafficUpdate {
+	update := &wazepb.TrafficUpdate{
+		Sequence:    frame.Sequence,
+		Keyframe:    frame.Type == FRAME_KEYFRAME,
+		MapReloaded: reloaded,
+	}
+	for _, car := range frame.Cars {
+		update.Cars = append(update.Cars, &wazepb.CarPosition{
+			CarId:    int32(car.CarID),
+			EdgeId:   int32(car.EdgeID),
+			Progress: car.Progress,
+			Speed:    car.Speed,
+			Heading:  car.Heading,
+			X:        car.X,
+			Y:        car.Y,
+		})
+	}
+	for _, carID := range frame.Removed {
+		update.Removed = append(update.Removed, int32(carID))
+	}
+	for _, edge := range frame.Edges {
+		update.Edges = append(update.Edges, &wazepb.EdgeCongestion{
+			EdgeId:     int32(edge.EdgeID),
+			Speed:      edge.Speed,
+			Ratio:      edge.Ratio,
+			Confidence: edge.Confidence,
+			Incident:   edge.Incident,
+		})
+	}
+	return update
+}
+
+// GRPCAddr returns the address the gRPC API listens on, nil when it is off
+func (s *Server) GRPCAddr() net.Addr {
+	if s.grpcListener == nil {
+		return nil
+	}
+	return s.grpcListener.Addr()
+}
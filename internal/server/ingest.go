@@ -0,0 +1,245 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+	"waze/internal/types"
+
+	"github.com/gorilla/websocket"
+)
+
+// the reports of all the streams wait in one bounded queue, and are applied in batches
+const (
+	INGEST_QUEUE_SIZE     = 256                    // messages, a stream waits for room when it is full
+	INGEST_BATCH_SIZE     = 2000                   // reports, a batch is applied once it has this many
+	INGEST_FLUSH_INTERVAL = 100 * time.Millisecond // or once its first message waited this long
+)
+
+const (
+	// the biggest message of a report stream
+	MAX_REPORT_MESSAGE_SIZE = 1 << 20
+	// the most reports of a single message
+	MAX_REPORTS_PER_MESSAGE = 5000
+	// messages of a stream that were not answered yet, the stream is not read while it has this many
+	MAX_IN_FLIGHT = 32
+)
+
+// the types of a ReportAck
+const (
+	ACK_OK    = "ack"
+	ACK_ERROR = "error"
+)
+
+// a message of a report stream on its way to be applied
+type ingestItem struct {
+	seq      uint64
+	reports  []types.TrafficReport
+	reporter *Identity
+	// the answer is sent there. it has room for every message in flight, so the ingester never waits for it
+	acks chan<- types.ReportAck
+}
+
+// ingest applies the messages of the report streams in batches, until the server stops.
+// the messages that were already queued are applied before it returns
+func (s *Server) ingest() {
+	batch := make([]ingestItem, 0)
+	size := 0
+	flush := time.NewTimer(INGEST_FLUSH_INTERVAL)
+	flush.Stop()
+
+	apply := func() {
+		flush.Stop()
+		if len(batch) > 0 {
+			s.applyBatch(batch)
+		}
+		batch = batch[:0]
+		size = 0
+	}
+
+	for {
+		select {
+		case item := <-s.ingestQueue:
+			if len(batch) == 0 {
+				flush.Reset(INGEST_FLUSH_INTERVAL)
+			}
+			batch = append(batch, item)
+			if size += len(item.reports); size >= INGEST_BATCH_SIZE {
+				apply()
+			}
+		case <-flush.C:
+			apply()
+		case <-s.stop:
+			// the queue was closed before, nothing is queued anymore
+			for len(s.ingestQueue) > 0 {
+				batch = append(batch, <-s.ingestQueue)
+			}
+			apply()
+			return
+		}
+	}
+}
+
+// queues a message of a report stream, false once the server stops taking them
+func (s *Server) enqueueReports(item ingestItem) bool {
+	s.ingestMu.RLock()
+	defer s.ingestMu.RUnlock()
+	if s.ingestClosed {
+		return false
+	}
+	select {
+	case s.ingestQueue <- item:
+		return true
+	case <-s.draining:
+		return false
+	}
+}
+
+// stops the report streams from queueing. the WebSocket handlers may outlive the HTTP shutdown,
+// so this is done before the ingester drains the queue, and every queued message is answered
+func (s *Server) closeIngest() {
+	s.ingestMu.Lock()
+	defer s.ingestMu.Unlock()
+	s.ingestClosed = true
+}
+
+// applies the reports of the messages, those of a reporter together, and answers every message
+func (s *Server) applyBatch(batch []ingestItem) {
+	g := s.Map().Graph
+
+	byReporter := make(map[*Identity][]types.TrafficReport)
+	all := make([]types.TrafficReport, 0)
+	for _, item := range batch {
+		byReporter[item.reporter] = append(byReporter[item.reporter], item.reports...)
+		all = append(all, item.reports...)
+	}
+	for reporter, reports := range byReporter {
+		s.updateSpeeds(g, reports, reporter.Trust)
+	}
+	s.broadcastCars(g, all)
+
+	for _, item := range batch {
+		item.acks <- types.ReportAck{Type: ACK_OK, Seq: item.seq, Reports: len(item.reports)}
+	}
+}
+
+// HandleReportStream takes traffic reports over a WebSocket for as long as the reporter keeps it open.
+// every message is a types.ReportMessage, answered with a types.ReportAck once it was applied.
+// each message takes a token of the report limit
+func (s *Server) HandleReportStream(w http.ResponseWriter, r *http.Request) {
+	reporter := IdentityFrom(r.Context())
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade error: %v", err)
+		return
+	}
+	defer conn.Close()
+	s.reportStreams.Add(1)
+	defer s.reportStreams.Add(-1)
+
+	// a message takes a slot before it is queued or answered with an error, the writer frees it after the answer
+	inFlight := make(chan struct{}, MAX_IN_FLIGHT)
+	acks := make(chan types.ReportAck, MAX_IN_FLIGHT)
+	done := make(chan struct{})
+	defer close(done)
+	// closed when the writer failed, the slots are not freed anymore
+	broken := make(chan struct{})
+	go func() {
+		defer close(broken)
+		writeAcks(conn, acks, inFlight, done)
+	}()
+
+	conn.SetReadLimit(MAX_REPORT_MESSAGE_SIZE)
+	conn.SetReadDeadline(time.Now().Add(PONG_WAIT))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(PONG_WAIT))
+	})
+
+	// the read below doesn't see the shutdown, the connection is closed under it
+	var draining atomic.Bool
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-s.draining:
+			draining.Store(true)
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "the server is shutting down"), time.Now().Add(WRITE_WAIT))
+			conn.Close()
+		case <-stopped:
+		}
+	}()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if !draining.Load() && !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("Report stream of %s ended: %v", reporter.Name, err)
+			}
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(PONG_WAIT))
+
+		select {
+		case inFlight <- struct{}{}:
+		case <-broken:
+			return
+		case <-s.draining:
+			return
+		}
+
+		var msg types.ReportMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			acks <- reportError(msg.Seq, invalidParams("invalid JSON: %v", err))
+			continue
+		}
+		if len(msg.Reports) > MAX_REPORTS_PER_MESSAGE {
+			acks <- reportError(msg.Seq, invalidParams("at most %d reports in a message, got %d", MAX_REPORTS_PER_MESSAGE, len(msg.Reports)))
+			continue
+		}
+		if err := s.admit(reporter, "", LIMIT_REPORT); err != nil {
+			acks <- reportError(msg.Seq, err)
+			continue
+		}
+		for i := range msg.Reports {
+			msg.Reports[i].Reporter = reporter.Name
+		}
+
+		if !s.enqueueReports(ingestItem{seq: msg.Seq, reports: msg.Reports, reporter: reporter, acks: acks}) {
+			return
+		}
+	}
+}
+
+func reportError(seq uint64, err error) types.ReportAck {
+	apiErr := toAPIError(err)
+	return types.ReportAck{Type: ACK_ERROR, Seq: seq, Code: apiErr.Code, Message: apiErr.Message, RetryAfter: apiErr.RetryAfter}
+}
+
+// writes the answers and the pings of a report stream until done is closed or a write fails
+func writeAcks(conn *websocket.Conn, acks <-chan types.ReportAck, inFlight <-chan struct{}, done <-chan struct{}) {
+	ticker := time.NewTicker(PING_PERIOD)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case ack := <-acks:
+			conn.SetWriteDeadline(time.Now().Add(WRITE_WAIT))
+			if err := conn.WriteJSON(ack); err != nil {
+				conn.Close()
+				return
+			}
+			<-inFlight
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(WRITE_WAIT))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				conn.Close()
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+)
+
+// Handler returns all the endpoints of the server: the API, the WebSocket, the metrics and the GUI files
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	// API endpoints, under /api/v1 and the old /api
+	s.RegisterAPI(mux)
+	mux.HandleFunc("/ws", s.authorize(ROLE_VIEWER, "", s.HandleWebSocket))
+	mux.Handle("/metrics", s.Metrics.Handler())
+	if s.opts.StaticDir != "" {
+		mux.Handle("/", http.FileServer(http.Dir(s.opts.StaticDir)))
+	}
+	return mux
+}
+
+// Start starts the workers and the background loops, and serves HTTP on addr and gRPC on Options.GRPCAddr.
+// it returns once the addresses are listened on, ":0" picks a free port (see Addr and GRPCAddr)
+func (s *Server) Start(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+	if s.opts.GRPCAddr != "" {
+		if s.grpcListener, err = net.Listen("tcp", s.opts.GRPCAddr); err != nil {
+			listener.Close()
+			return err
+		}
+	}
+
+	s.startWorkers(s.opts.Workers)
+	go s.Hub.Run()
+	s.background(s.broadcastCongestion)
+	s.background(s.ingest)
+	s.background(s.recordHistory)
+	s.background(s.expireOverrides)
+	if s.opts.SnapshotFile != "" {
+		s.background(s.saveSnapshots)
+	}
+
+	s.httpServer = &http.Server{Handler: s.Handler()}
+	go func() {
+		if err := s.httpServer.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("HTTP server error: %v", err)
+		}
+	}()
+	if s.grpcListener != nil {
+		s.grpcServer = s.newGRPCServer()
+		go func() {
+			if err := s.grpcServer.Serve(s.grpcListener); err != nil {
+				log.Printf("gRPC server error: %v", err)
+			}
+		}()
+	}
+	return nil
+}
+
+// Addr returns the address the server listens on
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// lets the running gRPC calls finish until ctx is done, and then cancels them
+func (s *Server) stopGRPC(ctx context.Context) {
+	stopped := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.grpcServer.Stop()
+		<-stopped
+	}
+}
+
+func (s *Server) background(loop func()) {
+	s.loops.Add(1)
+	go func() {
+		defer s.loops.Done()
+		loop()
+	}()
+}
+
+// Shutdown stops the server. the streams end first, then the running requests get until ctx is done to finish,
+// the workers and the loops stop, the WebSocket clients get a close frame, and the traffic is saved last.
+// it is called once, after Start
+func (s *Server) Shutdown(ctx context.Context) error {
+	close(s.draining)
+	err := s.httpServer.Shutdown(ctx)
+	if err != nil {
+		// cancels the requests that are still running, their searches stop with them
+		s.httpServer.Close()
+	}
+	if s.grpcServer != nil {
+		s.stopGRPC(ctx)
+	}
+
+	s.closeIngest()
+	close(s.stop)
+	s.loops.Wait()
+
+	if hubErr := s.Hub.Close(ctx); hubErr != nil && err == nil {
+		err = hubErr
+	}
+
+	if s.opts.SnapshotFile != "" {
+		if saveErr := s.SaveSnapshot(s.opts.SnapshotFile); saveErr != nil && err == nil {
+			err = saveErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"waze/internal/metrics"
+)
+
+// the reasons of rejected reports
+const (
+	REJECT_UNKNOWN_EDGE  = "unknown_edge"
+	REJECT_INVALID_SPEED = "invalid_speed"
+	REJECT_UNTRUSTED     = "untrusted" // of a key with trust 0, they never move a speed
+)
+
+// the metrics the server updates. the rest are read from its state on every scrape
+type serverMetrics struct {
+	routingDuration *metrics.Histogram
+	nodesExpanded   *metrics.Histogram
+	reportsIngested *metrics.Counter
+	reportsRejected *metrics.CounterVec
+	reloads         *metrics.CounterVec
+	authRejected    *metrics.CounterVec
+}
+
+// registers the metrics of the server in s.Metrics
+func newServerMetrics(s *Server) *serverMetrics {
+	r := s.Metrics
+	m := &serverMetrics{
+		routingDuration: r.NewHistogram("waze_routing_duration_seconds",
+			"Time of the route searches of a navigation request, without the time in the queue", metrics.DefaultBuckets),
+		nodesExpanded: r.NewHistogram("waze_routing_nodes_expanded",
+			"Nodes expanded by the route searches of a navigation request", metrics.ExponentialBuckets(16, 2, 12)),
+		reportsIngested: r.NewCounter("waze_traffic_reports_ingested_total",
+			"Traffic reports applied to the edge speeds"),
+		reportsRejected: r.NewCounterVec("waze_traffic_reports_rejected_total",
+			"Traffic reports that were not applied, by reason", "reason"),
+		reloads: r.NewCounterVec("waze_reloads_total",
+			"Reloads of the map and the config, by result", "result"),
+		authRejected: r.NewCounterVec("waze_auth_rejected_total",
+			"API requests refused for their key, role or rate limit, by error code", "code"),
+	}
+
+	r.NewGaugeFunc("waze_job_queue_depth", "Navigation requests waiting for a worker", func() float64 {
+		return float64(len(s.Jobs))
+	})
+	r.NewGaugeFunc("waze_job_queue_capacity", "Navigation requests the queue holds before shedding", func() float64 {
+		return float64(cap(s.Jobs))
+	})
+	r.NewGaugeFunc("waze_ingest_queue_depth", "Report stream messages waiting to be applied", func() float64 {
+		return float64(len(s.ingestQueue))
+	})
+	r.NewGaugeFunc("waze_report_streams", "Open report streams", func() float64 {
+		return float64(s.reportStreams.Load())
+	})
+	r.NewGaugeFunc("waze_websocket_clients", "Connected WebSocket clients", func() float64 {
+		return float64(s.Hub.ClientCount())
+	})
+	r.NewCounterFunc("waze_websocket_dropped_messages_total", "Messages dropped for WebSocket clients that were too slow", func() float64 {
+		return float64(s.Hub.dropped.Load())
+	})
+
+	r.NewHistogramFunc("waze_edge_speed_kmh", "Current speeds of the edges",
+		metrics.LinearBuckets(10, 10, 12), func() []float64 {
+			g := s.Map().Graph
+			speeds := make([]float64, 0, len(g.Edges))
+			for _, edge := range g.Edges {
+				speeds = append(speeds, edge.GetCurrentSpeed())
+			}
+			return speeds
+		})
+	r.NewHistogramFunc("waze_edge_speed_ratio", "Current speeds of the edges over their speed limits",
+		metrics.LinearBuckets(0.1, 0.1, 15), func() []float64 {
+			g := s.Map().Graph
+			ratios := make([]float64, 0, len(g.Edges))
+			for _, edge := range g.Edges {
+				if edge.SpeedLimit > 0 {
+					ratios = append(ratios, edge.GetCurrentSpeed()/edge.SpeedLimit)
+				}
+			}
+			return ratios
+		})
+
+	r.NewCounterFunc("waze_route_cache_hits_total", "Navigation requests answered from the route cache", func() float64 {
+		return float64(s.Routes.Stats().Hits)
+	})
+	r.NewCounterFunc("waze_route_cache_misses_total", "Navigation requests that were not in the route cache", func() float64 {
+		return float64(s.Routes.Stats().Misses)
+	})
+	r.NewCounterFunc("waze_route_cache_invalidations_total", "Cached routes dropped because the speeds on their edges changed", func() float64 {
+		return float64(s.Routes.Stats().Invalidations)
+	})
+	r.NewGaugeFunc("waze_route_cache_entries", "Routes in the route cache", func() float64 {
+		return float64(s.Routes.Stats().Size)
+	})
+	return m
+}
@@ -0,0 +1,310 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+	"waze/internal/graph"
+	"waze/internal/navigation"
+	"waze/internal/polyline"
+	"waze/internal/spatial"
+	"waze/internal/types"
+)
+
+// the longest time a navigation request may take, in the queue and in the search
+const NAVIGATION_TIMEOUT = 5 * time.Second
+
+// seconds a client should wait before retrying when the server is overloaded
+const RETRY_AFTER = 1
+
+// the most cells a travel time matrix may have, every source is a search
+const MAX_MATRIX_CELLS = 2500
+
+// a location is snapped to the nearest node within SNAP_RADIUS, the radius doubles up to MAX_SNAP_DISTANCE (KM)
+const (
+	SNAP_RADIUS       = 0.1
+	MAX_SNAP_DISTANCE = 1.0
+)
+
+// HandleNavigationPost is the navigation with a NavigationRequest body, for the options that don't fit a query
+func (s *Server) HandleNavigationPost(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		types.NavigationRequest
+		LegacyToNodeId *int `json:"toNode"` // to_node of the first API
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, invalidParams("invalid JSON: %v", err))
+		return
+	}
+	nav := body.NavigationRequest
+	if body.LegacyToNodeId != nil && isLegacy(w) {
+		nav.ToNodeId = body.LegacyToNodeId
+	}
+	s.navigate(w, r, nav)
+}
+
+// the route as the first API had it
+type legacyNavigationResponse struct {
+	*types.NavigationResponse
+	Err *string `json:"error"` // always null, the errors are not JSON
+}
+
+// navigate runs the request on the workers and writes the route.
+// the search stops when the client disconnects or the deadline passes, and a full queue is answered right away
+func (s *Server) navigate(w http.ResponseWriter, r *http.Request, nav types.NavigationRequest) {
+	response, err := s.Navigate(r.Context(), nav)
+	if err != nil {
+		// the client is gone, nobody reads the answer
+		if r.Context().Err() != nil {
+			return
+		}
+		writeError(w, err)
+		return
+	}
+	// dashboards can follow the routes drivers get
+	s.Hub.BroadcastUpdate(TOPIC_ROUTES, response)
+
+	if isLegacy(w) {
+		writeJSON(w, http.StatusOK, legacyNavigationResponse{NavigationResponse: response})
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// Navigate finds the route of the request, the same for the HTTP and the gRPC API.
+// the search stops when ctx is done or the timeout of the request passes
+func (s *Server) Navigate(ctx context.Context, nav types.NavigationRequest) (*types.NavigationResponse, error) {
+	if nav.Timeout < 0 || math.IsNaN(nav.Timeout) || math.IsInf(nav.Timeout, 0) {
+		return nil, invalidParams("'timeout' must be a positive number of seconds")
+	}
+	timeout := NAVIGATION_TIMEOUT
+	if nav.Timeout > 0 {
+		// clamped before the conversion, a big timeout would overflow the duration
+		timeout = time.Duration(math.Min(nav.Timeout, NAVIGATION_TIMEOUT.Seconds()) * float64(time.Second))
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := s.pathRequest(ctx, s.Map(), nav)
+	if err != nil {
+		return nil, err
+	}
+
+	// the avoids change the profile, such requests are rare enough to always search
+	cacheable := len(nav.AvoidEdges) == 0 && len(nav.AvoidRoadClasses) == 0
+	routes, err := s.findRoutes(req, cacheable)
+	if err != nil {
+		return nil, err
+	}
+
+	departure := time.Now()
+	if nav.DepartureTime != nil {
+		departure = *nav.DepartureTime
+	}
+	response := buildResponse(req.Graph, routes[0], req, departure)
+	for _, alternative := range routes[1:] {
+		response.Alternatives = append(response.Alternatives, buildResponse(req.Graph, alternative, req, departure))
+	}
+	return &response, nil
+}
+
+// the routes of the request, from the cache or from the workers
+func (s *Server) findRoutes(req PathRequest, cacheable bool) ([]*navigation.PathResult, error) {
+	key := routeKey{graph: req.Graph, from: req.StartNodeId, to: req.EndNodeId, profile: req.Profile.Name(), alternatives: req.Alternatives}
+	if cacheable {
+		if routes, hit := s.Routes.Get(key, time.Now()); hit {
+			return routes, nil
+		}
+	}
+	epoch := s.Routes.Epoch()
+
+	// waiting for room in the queue would only make the client time out
+	select {
+	case s.Jobs <- req:
+	default:
+		return nil, overloaded("too many navigation requests")
+	}
+
+	var result PathResult
+	select {
+	case result = <-req.ResponseChannel:
+	case <-req.Ctx.Done():
+		return nil, req.Ctx.Err()
+	}
+	if result.Err != nil {
+		return nil, result.Err
+	}
+
+	if cacheable {
+		s.Routes.Put(key, result.Routes, epoch, time.Now())
+	}
+	return result.Routes, nil
+}
+
+// Matrix finds the routes from every source to every destination of the map m, a search per source on the workers.
+// a cell is nil when there is no route between its ends
+func (s *Server) Matrix(ctx context.Context, m *Map, sources, destinations []int, profile navigation.Profile) ([][]*navigation.PathResult, error) {
+	if len(sources) == 0 || len(destinations) == 0 {
+		return nil, invalidParams("the sources and the destinations are required")
+	}
+	if len(sources)*len(destinations) > MAX_MATRIX_CELLS {
+		return nil, invalidParams("at most %d cells, got %d sources and %d destinations", MAX_MATRIX_CELLS, len(sources), len(destinations))
+	}
+	ctx, cancel := context.WithTimeout(ctx, NAVIGATION_TIMEOUT)
+	// the rows that are still queued or running stop when one fails
+	defer cancel()
+
+	rows := make([]PathRequest, len(sources))
+	for i, source := range sources {
+		rows[i] = PathRequest{
+			Ctx:             ctx,
+			Graph:           m.Graph,
+			StartNodeId:     source,
+			Profile:         profile,
+			Targets:         destinations,
+			ResponseChannel: make(chan PathResult, 1),
+		}
+		select {
+		case s.Jobs <- rows[i]:
+		default:
+			return nil, overloaded("too many navigation requests")
+		}
+	}
+
+	matrix := make([][]*navigation.PathResult, len(sources))
+	for i, row := range rows {
+		var result PathResult
+		select {
+		case result = <-row.ResponseChannel:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		matrix[i] = result.Routes
+	}
+	return matrix, nil
+}
+
+func buildResponse(g *graph.Graph, pathRes *navigation.PathResult, req PathRequest, departure time.Time) types.NavigationResponse {
+	response := types.NavigationResponse{
+		RouteNodes:    pathRes.Route,
+		ETA:           pathRes.ETA,
+		Distance:      pathRes.Distance,
+		Profile:       pathRes.Profile,
+		FromNodeId:    req.StartNodeId,
+		ToNodeId:      req.EndNodeId,
+		DepartureTime: departure,
+		ArrivalTime:   departure.Add(time.Duration(pathRes.ETA * float64(time.Minute))),
+	}
+	if req.Geometry {
+		response.Geometry = polyline.Encode(g.RoutePoints(pathRes.Route))
+	}
+	if req.Instructions {
+		response.Instructions = navigation.BuildInstructions(g, pathRes.Route)
+	}
+	return response
+}
+
+// the work of a navigation request on the map m, after checking its options
+func (s *Server) pathRequest(ctx context.Context, m *Map, nav types.NavigationRequest) (PathRequest, error) {
+	req := PathRequest{
+		Ctx:             ctx,
+		Graph:           m.Graph,
+		Alternatives:    nav.Alternatives,
+		Geometry:        nav.Geometry == nil || *nav.Geometry,
+		Instructions:    nav.Instructions,
+		ResponseChannel: make(chan PathResult, 1),
+	}
+
+	var err error
+	if req.StartNodeId, err = m.endNode("from", nav.FromNodeId, nav.From); err != nil {
+		return req, err
+	}
+	if req.EndNodeId, err = m.endNode("to", nav.ToNodeId, nav.To); err != nil {
+		return req, err
+	}
+
+	if nav.Alternatives < 0 || nav.Alternatives > navigation.MAX_ALTERNATIVES {
+		return req, invalidParams("'alternatives' must be between 0 and %d", navigation.MAX_ALTERNATIVES)
+	}
+
+	profile, err := navigation.GetProfile(nav.Profile)
+	if err != nil {
+		return req, invalidParams("%v", err)
+	}
+	if len(nav.AvoidRoadClasses) > 0 {
+		classes := make([]graph.RoadClass, 0, len(nav.AvoidRoadClasses))
+		for _, name := range nav.AvoidRoadClasses {
+			class, ok := parseRoadClass(name)
+			if !ok {
+				return req, invalidParams("unknown road class %q", name)
+			}
+			classes = append(classes, class)
+		}
+		profile = navigation.Restrict(profile, navigation.AvoidClasses(classes...), navigation.AVOID_PENALTY)
+	}
+	if len(nav.AvoidEdges) > 0 {
+		for _, id := range nav.AvoidEdges {
+			if _, exists := m.Graph.Edges[id]; !exists {
+				return req, invalidParams("unknown edge %d in 'avoid_edges'", id)
+			}
+		}
+		profile = navigation.Restrict(profile, navigation.AvoidEdges(nav.AvoidEdges...), math.Inf(1))
+	}
+	req.Profile = profile
+
+	return req, nil
+}
+
+// the node of an end of the route, given by its id or by a location
+func (m *Map) endNode(name string, nodeId *int, location *types.Location) (int, error) {
+	if location == nil {
+		if nodeId == nil {
+			return 0, invalidParams("'%s' or '%s_node' is required", name, name)
+		}
+		return *nodeId, nil
+	}
+	return m.nearestNode(*location)
+}
+
+func parseRoadClass(name string) (graph.RoadClass, bool) {
+	class := graph.RoadClass(name).Base()
+	for _, known := range graph.RoadClasses {
+		if class == known {
+			return class, true
+		}
+	}
+	return "", false
+}
+
+// nearestNode returns the closest node that has roads, searching farther and farther up to MAX_SNAP_DISTANCE
+func (m *Map) nearestNode(location types.Location) (int, error) {
+	matcher := m.Matcher
+	for radius := SNAP_RADIUS; ; radius = math.Min(radius*2, MAX_SNAP_DISTANCE) {
+		box := spatial.BoxAround(location.X, location.Y, matcher.Proj.DegreesFor(radius))
+
+		best, bestDist := -1, math.Inf(1)
+		for _, edge := range matcher.Index.InBox(box) {
+			for _, id := range [2]int{edge.From, edge.To} {
+				node := m.Graph.Nodes[id]
+				dist := matcher.Proj.Distance(location.X, location.Y, node.X, node.Y)
+				// a closer node may be out of the box
+				if dist <= radius && dist < bestDist {
+					best, bestDist = id, dist
+				}
+			}
+		}
+		if best != -1 {
+			return best, nil
+		}
+		if radius >= MAX_SNAP_DISTANCE {
+			break
+		}
+	}
+	return 0, fmt.Errorf("%w: no road within %g KM of (%g, %g)", navigation.ErrNodeNotFound, MAX_SNAP_DISTANCE, location.X, location.Y)
+}
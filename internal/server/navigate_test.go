@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"waze/internal/types"
+	"waze/internal/wazepb"
+)
+
+// a map whose first node is 0
+const GRID_MAP_FILE = "../../data/grid_map.json"
+
+func TestNavigateFromNodeZero(t *testing.T) {
+	s := testServerOn(t, GRID_MAP_FILE)
+	handler := s.Handler()
+	from, to := 0, 99
+
+	t.Run("Navigate", func(t *testing.T) {
+		response, err := s.Navigate(context.Background(), types.NavigationRequest{FromNodeId: &from, ToNodeId: &to})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.FromNodeId != 0 || len(response.RouteNodes) == 0 {
+			t.Errorf("route %v from node %d", response.RouteNodes, response.FromNodeId)
+		}
+	})
+
+	requests := []struct {
+		name string
+		req  *http.Request
+	}{
+		{"GET /api/v1", httptest.NewRequest(http.MethodGet, "/api/v1/navigate?from=0&to=99", nil)},
+		{"GET /api", httptest.NewRequest(http.MethodGet, "/api/navigate?from=0&to=99", nil)},
+		{"POST /api/v1", httptest.NewRequest(http.MethodPost, "/api/v1/navigate", strings.NewReader(`{"from_node":0,"to_node":99}`))},
+		{"POST /api/v1 to node 0", httptest.NewRequest(http.MethodPost, "/api/v1/navigate", strings.NewReader(`{"from_node":99,"to_node":0}`))},
+	}
+	for _, tt := range requests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, tt.req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			var response types.NavigationResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if len(response.RouteNodes) == 0 {
+				t.Error("no route")
+			}
+		})
+	}
+
+	t.Run("without a start", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/navigate", strings.NewReader(`{"to_node":99}`)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status %d, want %d", w.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("gRPC waypoint", func(t *testing.T) {
+		nav := navigationRequest(&wazepb.RouteRequest{
+			From: &wazepb.Waypoint{Point: &wazepb.Waypoint_NodeId{NodeId: 0}},
+			To:   &wazepb.Waypoint{Point: &wazepb.Waypoint_NodeId{NodeId: 99}},
+		})
+		if _, err := s.Navigate(context.Background(), nav); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
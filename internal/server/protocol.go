@@ -0,0 +1,170 @@
+package server
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// the binary protocol of the GUI updates (clients that connect with /ws?v=PROTOCOL_VERSION).
+// everything the hub sends them is binary frames, the replies to their messages are JSON text.
+//
+// every frame is little endian:
+//
+//	header  version u8 | type u8 | sequence u32
+//	cars    count u32, then per car: car id u32 | edge id u32 | progress u16 | speed u16 | heading u16
+//	removed count u32, then per car: car id u32
+//	edges   count u32, then per edge: edge id u32 | speed u16 | confidence u8 | flags u8
+//
+// progress is scaled to 0-65535, speeds are in 1/100 KM/hour, the heading in 1/100 degrees
+// and the confidence to 0-255. the flags of an edge are EDGE_FLAG_*.
+//
+// the graph comes first, in an init frame with sequence 0 (again after the server reloaded its map):
+//
+//	header  version u8 | type u8 | sequence u32
+//	strings count u32, then per string: length u32 | utf-8 bytes. string 0 is empty
+//	nodes   count u32, then per node: node id u32 | x i32 | y i32
+//	edges   count u32, then per edge: edge id u32 | from u32 | to u32 | length u32 | speed limit u16 |
+//	        road class u32 | name u32 | lanes u8 | flags u8 | geometry length u32 | geometry bytes
+//
+// the coordinates are in 1e-7 degrees, the length in centimeters and the speed limit in 1/100 KM/hour.
+// the road class and the name are indexes in the strings, the flags are EDGE_INIT_*
+// and the geometry is the encoded polyline of the edge, empty for a straight edge.
+// a keyframe holds every car and edge the client is subscribed to, a delta only what changed since
+// the previous frame of the client. every client has its own sequence, and a delta applies only on top
+// of the frame with the previous sequence. a client that misses one sends {"type":"resync"} and gets a keyframe
+const PROTOCOL_VERSION = 4
+
+const (
+	FRAME_KEYFRAME byte = 1
+	FRAME_DELTA    byte = 2
+	FRAME_INIT     byte = 3
+)
+
+const (
+	EDGE_FLAG_INCIDENT byte = 1 << 0
+)
+
+const (
+	EDGE_INIT_ONEWAY byte = 1 << 0
+	EDGE_INIT_TOLL   byte = 1 << 1
+)
+
+// the scale of the coordinates in an init frame
+const COORDINATE_SCALE = 1e7
+
+const (
+	frameHeaderSize = 6
+	carRecordSize   = 14
+	edgeRecordSize  = 8
+)
+
+// a frame before encoding
+type Frame struct {
+	Type     byte
+	Sequence uint32
+	Cars     []CarPosition
+	Removed  []int
+	Edges    []EdgeCongestion
+}
+
+func (f *Frame) Encode() []byte {
+	size := frameHeaderSize + 12 + len(f.Cars)*carRecordSize + len(f.Removed)*4 + len(f.Edges)*edgeRecordSize
+	buf := make([]byte, 0, size)
+
+	buf = append(buf, PROTOCOL_VERSION, f.Type)
+	buf = binary.LittleEndian.AppendUint32(buf, f.Sequence)
+
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(f.Cars)))
+	for _, car := range f.Cars {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(car.CarID))
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(car.EdgeID))
+		buf = binary.LittleEndian.AppendUint16(buf, scaleUint16(car.Progress, math.MaxUint16))
+		buf = binary.LittleEndian.AppendUint16(buf, scaleUint16(car.Speed, 100))
+		buf = binary.LittleEndian.AppendUint16(buf, scaleUint16(car.Heading, 100))
+	}
+
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(f.Removed)))
+	for _, carID := range f.Removed {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(carID))
+	}
+
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(f.Edges)))
+	for _, edge := range f.Edges {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(edge.EdgeID))
+		buf = binary.LittleEndian.AppendUint16(buf, scaleUint16(edge.Speed, 100))
+		buf = append(buf, byte(math.Round(math.Max(0, math.Min(1, edge.Confidence))*255)))
+		flags := byte(0)
+		if edge.Incident {
+			flags |= EDGE_FLAG_INCIDENT
+		}
+		buf = append(buf, flags)
+	}
+	return buf
+}
+
+// EncodeInit is the graph of the GUI as an init frame
+func EncodeInit(data GraphData) []byte {
+	// every name and road class once
+	strings := []string{""}
+	stringIndex := map[string]uint32{"": 0}
+	index := func(s string) uint32 {
+		i, exists := stringIndex[s]
+		if !exists {
+			i = uint32(len(strings))
+			stringIndex[s] = i
+			strings = append(strings, s)
+		}
+		return i
+	}
+	classes := make([]uint32, len(data.Edges))
+	names := make([]uint32, len(data.Edges))
+	for i, edge := range data.Edges {
+		classes[i] = index(edge.RoadClass)
+		names[i] = index(edge.Name)
+	}
+
+	buf := make([]byte, 0, frameHeaderSize+12+len(data.Nodes)*12+len(data.Edges)*32)
+	buf = append(buf, PROTOCOL_VERSION, FRAME_INIT)
+	buf = binary.LittleEndian.AppendUint32(buf, 0)
+
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(strings)))
+	for _, s := range strings {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(s)))
+		buf = append(buf, s...)
+	}
+
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(data.Nodes)))
+	for _, node := range data.Nodes {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(node.ID))
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(int32(math.Round(node.X*COORDINATE_SCALE))))
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(int32(math.Round(node.Y*COORDINATE_SCALE))))
+	}
+
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(data.Edges)))
+	for i, edge := range data.Edges {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(edge.ID))
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(edge.From))
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(edge.To))
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(math.Max(0, math.Min(math.MaxUint32, math.Round(edge.Length*100000)))))
+		buf = binary.LittleEndian.AppendUint16(buf, scaleUint16(edge.SpeedLimit, 100))
+		buf = binary.LittleEndian.AppendUint32(buf, classes[i])
+		buf = binary.LittleEndian.AppendUint32(buf, names[i])
+		buf = append(buf, byte(max(0, min(edge.Lanes, math.MaxUint8))))
+		flags := byte(0)
+		if edge.OneWay {
+			flags |= EDGE_INIT_ONEWAY
+		}
+		if edge.Toll {
+			flags |= EDGE_INIT_TOLL
+		}
+		buf = append(buf, flags)
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(edge.Geometry)))
+		buf = append(buf, edge.Geometry...)
+	}
+	return buf
+}
+
+// value*scale rounded and clamped into a uint16
+func scaleUint16(value, scale float64) uint16 {
+	return uint16(math.Max(0, math.Min(math.MaxUint16, math.Round(value*scale))))
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+	"waze/internal/config"
+	"waze/internal/graph"
+	"waze/internal/matching"
+)
+
+// the results of a reload, by the waze_reloads_total metric
+const (
+	RELOAD_OK     = "ok"
+	RELOAD_FAILED = "failed"
+)
+
+// Map is the graph the server routes on, with its matcher. a reload replaces it as a whole,
+// so a request that took it keeps using the same graph until it is done
+type Map struct {
+	Graph   *graph.Graph
+	Matcher *matching.Matcher
+	File    string
+}
+
+func newMap(g *graph.Graph, file string) *Map {
+	return &Map{Graph: g, Matcher: matching.NewMatcher(g, matching.DefaultParams), File: file}
+}
+
+// Map returns the current map
+func (s *Server) Map() *Map {
+	return s.current.Load()
+}
+
+type ReloadResult struct {
+	MapFile        string  `json:"map_file"`
+	Nodes          int     `json:"nodes"`
+	Edges          int     `json:"edges"`
+	CarriedOver    int     `json:"carried_over"` // edges that kept their live speed
+	ConfigReloaded bool    `json:"config_reloaded"`
+	DurationMs     float64 `json:"duration_ms"`
+}
+
+// Reload reads the config file again and loads the map file of the config. the live speeds move to the edges
+// of the new map with the same id and ends. on an error nothing changes and the old map keeps serving.
+// it is called after Start
+func (s *Server) Reload() (*ReloadResult, error) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	result, err := s.reload()
+	if err != nil {
+		s.m.reloads.With(RELOAD_FAILED).Inc()
+		return nil, err
+	}
+	s.m.reloads.With(RELOAD_OK).Inc()
+	return result, nil
+}
+
+func (s *Server) reload() (*ReloadResult, error) {
+	start := time.Now()
+	result := &ReloadResult{MapFile: s.Map().File}
+
+	// the new config is used once the new map loaded
+	var cfg *config.Config
+	if s.opts.Config != nil {
+		var err error
+		if cfg, err = s.opts.Config.Load(); err != nil {
+			return nil, err
+		}
+		if cfg.Server.MapFile != "" {
+			result.MapFile = cfg.Server.MapFile
+		}
+	}
+
+	authOpts := s.auth.Load().opts
+	if cfg != nil {
+		authOpts = AuthOptionsFrom(cfg)
+	}
+	auth, err := newAuth(authOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := graph.LoadGraph(result.MapFile)
+	if err != nil {
+		return nil, err
+	}
+	// a map that was cut short is not better than the old one
+	if len(g.Edges) == 0 {
+		return nil, fmt.Errorf("%s has no edges", result.MapFile)
+	}
+	m := newMap(g, result.MapFile)
+	old := s.Map()
+
+	if cfg != nil {
+		if cfg.Server.Port != config.Get().Server.Port {
+			log.Printf("The server port changed to %s, it is used after a restart", cfg.Server.Port)
+		}
+		config.Set(cfg)
+		result.ConfigReloaded = true
+	}
+	s.auth.Store(auth)
+
+	result.CarriedOver = carryOverSpeeds(old.Graph, g)
+	s.current.Store(m)
+	// reports that were applied to the old graph while it was being replaced
+	carryOverSpeeds(old.Graph, g)
+	s.moveOverrides(old.Graph, g)
+	s.edgeLog.reset()
+
+	s.Routes.Reset(g)
+	s.Hub.Reset(m.GraphData(), m.Matcher.Index)
+
+	result.Nodes = len(g.Nodes)
+	result.Edges = len(g.Edges)
+	result.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	log.Printf("Reloaded %s: %d nodes, %d edges, the speeds of %d edges carried over",
+		result.MapFile, result.Nodes, result.Edges, result.CarriedOver)
+	return result, nil
+}
+
+// copies the speeds of the reported edges of from to the edges of to with the same id and ends,
+// unless the edge of to has a newer report. it returns the number of edges copied
+func carryOverSpeeds(from, to *graph.Graph) int {
+	copied := 0
+	for id, old := range from.Edges {
+		reports := old.ReportCount()
+		if reports == 0 {
+			continue
+		}
+		// the same id may be another road on the new map
+		edge, exists := to.Edges[id]
+		if !exists || edge.From != old.From || edge.To != old.To {
+			continue
+		}
+		if !old.LastUpdate().After(edge.LastUpdate()) {
+			continue
+		}
+		// the speed limit may have changed
+		speed := min(old.GetCurrentSpeed(), edge.SpeedLimit*graph.MaxSpeedFactor)
+		edge.Restore(speed, reports, old.LastUpdate())
+		copied++
+	}
+	return copied
+}
+
+// HandleReload reloads the config and the map, like SIGHUP
+func (s *Server) HandleReload(w http.ResponseWriter, r *http.Request) {
+	result, err := s.Reload()
+	if err != nil {
+		writeError(w, &APIError{Status: http.StatusInternalServerError, Code: ERR_RELOAD_FAILED, Message: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
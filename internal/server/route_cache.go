@@ -0,0 +1,234 @@
+package server
+
+import (
+	"container/list"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+	"waze/internal/graph"
+	"waze/internal/navigation"
+)
+
+const (
+	ROUTE_CACHE_SIZE = 4096
+	// only the edges of a route are watched, so a road that got faster elsewhere is noticed when the entry expires
+	ROUTE_CACHE_TTL = 30 * time.Second
+	// a change of the speed of an edge by more than this fraction starts a new traffic epoch for it
+	ROUTE_CACHE_THRESHOLD = 0.2
+)
+
+// the requests with the same key get the same routes. the routes of a map that was reloaded are never found again
+type routeKey struct {
+	graph        *graph.Graph
+	from         int
+	to           int
+	profile      string
+	alternatives int
+}
+
+type cacheEntry struct {
+	key     routeKey
+	routes  []*navigation.PathResult
+	edges   []int // the edges of all the routes, each once
+	created time.Time
+	element *list.Element
+}
+
+type CacheStats struct {
+	Size          int     `json:"size"`
+	Hits          uint64  `json:"hits"`
+	Misses        uint64  `json:"misses"`
+	HitRate       float64 `json:"hit_rate"` // 0-1, of all the lookups
+	Invalidations uint64  `json:"invalidations"`
+	Evictions     uint64  `json:"evictions"`
+	Epoch         uint64  `json:"epoch"`
+}
+
+// RouteCache keeps the results of the route searches. the traffic epoch advances whenever the speed of an
+// edge moves away from its speed in the last epoch by more than ROUTE_CACHE_THRESHOLD, and then only
+// the routes driving that edge are dropped
+type RouteCache struct {
+	mu      sync.Mutex
+	graph   *graph.Graph // the routes of other graphs are not kept
+	entries map[routeKey]*cacheEntry
+	lru     *list.List // of *cacheEntry, the most recently used first
+	byEdge  map[int]map[*cacheEntry]bool
+
+	epoch     uint64
+	refSpeed  map[int]float64 // the speed of an edge when its epoch started
+	edgeEpoch map[int]uint64  // the epoch of the last big change of an edge
+
+	hits          uint64
+	misses        uint64
+	invalidations uint64
+	evictions     uint64
+}
+
+func NewRouteCache(g *graph.Graph) *RouteCache {
+	return &RouteCache{
+		graph:     g,
+		entries:   make(map[routeKey]*cacheEntry),
+		lru:       list.New(),
+		byEdge:    make(map[int]map[*cacheEntry]bool),
+		refSpeed:  make(map[int]float64),
+		edgeEpoch: make(map[int]uint64),
+	}
+}
+
+// Epoch returns the current traffic epoch. a search passes the epoch it started at to Put
+func (c *RouteCache) Epoch() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.epoch
+}
+
+func (c *RouteCache) Get(key routeKey, now time.Time) ([]*navigation.PathResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, exists := c.entries[key]
+	if exists && now.Sub(entry.created) > ROUTE_CACHE_TTL {
+		c.remove(entry)
+		exists = false
+	}
+	if !exists {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.lru.MoveToFront(entry.element)
+	return entry.routes, true
+}
+
+// Put adds the routes of a search that started at epoch. they are not kept when the speed of one of their edges
+// changed during the search. the routes of a profile that ignores the speeds stay until they expire
+func (c *RouteCache) Put(key routeKey, routes []*navigation.PathResult, epoch uint64, now time.Time) {
+	// the routes whose edges are watched for speed changes
+	watched := routes
+	if profile, err := navigation.GetProfile(key.profile); err == nil && !navigation.UsesSpeeds(profile) {
+		watched = nil
+	}
+	edges := make([]int, 0)
+	seen := make(map[int]bool)
+	for _, route := range watched {
+		for _, id := range route.Route {
+			if !seen[id] {
+				seen[id] = true
+				edges = append(edges, id)
+			}
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// found on the map before a reload
+	if key.graph != c.graph {
+		return
+	}
+	for _, id := range edges {
+		if c.edgeEpoch[id] > epoch {
+			return
+		}
+	}
+
+	if old, exists := c.entries[key]; exists {
+		c.remove(old)
+	}
+	for c.lru.Len() >= ROUTE_CACHE_SIZE {
+		c.remove(c.lru.Back().Value.(*cacheEntry))
+		c.evictions++
+	}
+
+	entry := &cacheEntry{key: key, routes: routes, edges: edges, created: now}
+	entry.element = c.lru.PushFront(entry)
+	c.entries[key] = entry
+	for _, id := range edges {
+		if c.byEdge[id] == nil {
+			c.byEdge[id] = make(map[*cacheEntry]bool)
+		}
+		c.byEdge[id][entry] = true
+		if _, exists := c.refSpeed[id]; !exists {
+			c.refSpeed[id] = key.graph.Edges[id].RoutingSpeed()
+		}
+	}
+}
+
+// UpdateSpeeds checks the edges whose speeds were just updated, and drops the routes of the edges that changed too much
+func (c *RouteCache) UpdateSpeeds(edges []*graph.Edge) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, edge := range edges {
+		// an edge of the map before a reload
+		if c.graph.Edges[edge.Id] != edge {
+			continue
+		}
+		speed := edge.RoutingSpeed()
+		ref, exists := c.refSpeed[edge.Id]
+		if !exists {
+			c.refSpeed[edge.Id] = speed
+			continue
+		}
+		if math.Abs(speed-ref) <= ROUTE_CACHE_THRESHOLD*ref {
+			continue
+		}
+
+		c.epoch++
+		c.edgeEpoch[edge.Id] = c.epoch
+		c.refSpeed[edge.Id] = speed
+		for entry := range c.byEdge[edge.Id] {
+			c.remove(entry)
+			c.invalidations++
+		}
+	}
+}
+
+// Reset drops all the routes and the speeds of the edges, the new routes are of g.
+// the counters keep counting
+func (c *RouteCache) Reset(g *graph.Graph) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.graph = g
+	c.entries = make(map[routeKey]*cacheEntry)
+	c.lru.Init()
+	c.byEdge = make(map[int]map[*cacheEntry]bool)
+	c.refSpeed = make(map[int]float64)
+	c.edgeEpoch = make(map[int]uint64)
+}
+
+// must be called with the lock held
+func (c *RouteCache) remove(entry *cacheEntry) {
+	delete(c.entries, entry.key)
+	c.lru.Remove(entry.element)
+	for _, id := range entry.edges {
+		delete(c.byEdge[id], entry)
+		if len(c.byEdge[id]) == 0 {
+			delete(c.byEdge, id)
+		}
+	}
+}
+
+func (s *Server) HandleRouteCacheStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Routes.Stats())
+}
+
+func (c *RouteCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := CacheStats{
+		Size:          len(c.entries),
+		Hits:          c.hits,
+		Misses:        c.misses,
+		Invalidations: c.invalidations,
+		Evictions:     c.evictions,
+		Epoch:         c.epoch,
+	}
+	if lookups := c.hits + c.misses; lookups > 0 {
+		stats.HitRate = float64(c.hits) / float64(lookups)
+	}
+	return stats
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+	"waze/internal/graph"
+	"waze/internal/types"
+)
+
+const TEST_MAP_FILE = "../../data/filtered_shoham.json"
+
+// a server with its routing workers and its hub, on the bundled map
+func testServer(t *testing.T) *Server {
+	t.Helper()
+	return testServerOn(t, TEST_MAP_FILE)
+}
+
+func testServerOn(t *testing.T, mapFile string) *Server {
+	t.Helper()
+	s, err := NewServer(Options{MapFile: mapFile, Workers: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.startWorkers(s.opts.Workers)
+	go s.Hub.Run()
+	t.Cleanup(func() {
+		close(s.stop)
+		s.loops.Wait()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		s.Hub.Close(ctx)
+	})
+	return s
+}
+
+// navigates from 1 to 400 and checks the route is then cached. it returns the route
+func cachedRoute(t *testing.T, s *Server) *types.NavigationResponse {
+	t.Helper()
+	from, to := 1, 400
+	response, err := s.Navigate(context.Background(), types.NavigationRequest{FromNodeId: &from, ToNodeId: &to})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := s.Routes.Stats().Size; size != 1 {
+		t.Fatalf("%d routes cached, want 1", size)
+	}
+	return response
+}
+
+func wantInvalidated(t *testing.T, s *Server, invalidations uint64) {
+	t.Helper()
+	stats := s.Routes.Stats()
+	if stats.Size != 0 {
+		t.Errorf("%d routes still cached", stats.Size)
+	}
+	if stats.Invalidations != invalidations {
+		t.Errorf("%d invalidations, want %d", stats.Invalidations, invalidations)
+	}
+}
+
+func TestRouteCacheSpeedUpdate(t *testing.T) {
+	s := testServer(t)
+	g := s.Map().Graph
+	route := cachedRoute(t, s)
+	edge := g.Edges[route.RouteNodes[0]]
+
+	// a small change keeps the route
+	s.updateSpeeds(g, []types.TrafficReport{{CarID: 1, EdgeID: edge.Id, Speed: edge.GetCurrentSpeed() * 0.95}}, 1)
+	if size := s.Routes.Stats().Size; size != 1 {
+		t.Fatalf("a small speed change dropped the route")
+	}
+
+	// a jam drops it
+	s.updateSpeeds(g, []types.TrafficReport{{CarID: 1, EdgeID: edge.Id, Speed: 1}}, 1)
+	wantInvalidated(t, s, 1)
+}
+
+func TestRouteCacheKeepsShortestRoutes(t *testing.T) {
+	s := testServer(t)
+	g := s.Map().Graph
+	from, to := 1, 400
+	nav := types.NavigationRequest{FromNodeId: &from, ToNodeId: &to, Profile: "shortest"}
+	route, err := s.Navigate(context.Background(), nav)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edge := g.Edges[route.RouteNodes[0]]
+
+	// the same jam drops a fastest route, the distance didn't change
+	s.updateSpeeds(g, []types.TrafficReport{{CarID: 1, EdgeID: edge.Id, Speed: 1}}, 1)
+	if stats := s.Routes.Stats(); stats.Size != 1 || stats.Invalidations != 0 {
+		t.Fatalf("%d routes cached after %d invalidations, want the shortest route", stats.Size, stats.Invalidations)
+	}
+	if _, hit := s.Routes.Get(routeKey{graph: g, from: from, to: to, profile: "shortest"}, time.Now()); !hit {
+		t.Error("the shortest route is not found after the speed changed")
+	}
+}
+
+func TestRouteCacheOverride(t *testing.T) {
+	s := testServer(t)
+	route := cachedRoute(t, s)
+	edgeId := route.RouteNodes[len(route.RouteNodes)-1]
+
+	if _, err := s.SetOverride(edgeId, OverrideRequest{Speed: 2}, "test", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	wantInvalidated(t, s, 1)
+
+	// and so does giving the edge its speed back
+	cachedRoute(t, s)
+	if s.ClearOverride(edgeId) == nil {
+		t.Fatal("the override is gone")
+	}
+	wantInvalidated(t, s, 2)
+}
+
+func TestRouteCacheReload(t *testing.T) {
+	s := testServer(t)
+	old := s.Map().Graph
+	cachedRoute(t, s)
+
+	if _, err := s.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Map().Graph == old {
+		t.Fatal("the map was not replaced")
+	}
+	if size := s.Routes.Stats().Size; size != 0 {
+		t.Errorf("%d routes of the old map still cached", size)
+	}
+	// the route is searched on the new map
+	cachedRoute(t, s)
+}
+
+func TestRouteCacheRefusesStaleRoutes(t *testing.T) {
+	s := testServer(t)
+	g := s.Map().Graph
+	route := cachedRoute(t, s)
+	key := routeKey{graph: g, from: 1, to: 400, profile: "fastest"}
+	routes, hit := s.Routes.Get(key, time.Now())
+	if !hit {
+		t.Fatal("the route is not cached under its key")
+	}
+
+	t.Run("of a map that was replaced", func(t *testing.T) {
+		s.Routes.Reset(graph.NewGraph())
+		s.Routes.Put(key, routes, s.Routes.Epoch(), time.Now())
+		if _, hit := s.Routes.Get(key, time.Now()); hit {
+			t.Error("a route of the old map was cached")
+		}
+		s.Routes.Reset(g)
+	})
+
+	t.Run("searched before a speed changed", func(t *testing.T) {
+		epoch := s.Routes.Epoch()
+		// the edge must have a reference speed to change from
+		s.Routes.Put(key, routes, epoch, time.Now())
+		edge := g.Edges[route.RouteNodes[0]]
+		s.updateSpeeds(g, []types.TrafficReport{{CarID: 1, EdgeID: edge.Id, Speed: 1}}, 1)
+
+		s.Routes.Put(key, routes, epoch, time.Now())
+		if _, hit := s.Routes.Get(key, time.Now()); hit {
+			t.Error("a route searched before its edge got slower was cached")
+		}
+	})
+
+	t.Run("that expired", func(t *testing.T) {
+		now := time.Now()
+		s.Routes.Put(key, routes, s.Routes.Epoch(), now)
+		if _, hit := s.Routes.Get(key, now.Add(ROUTE_CACHE_TTL+time.Second)); hit {
+			t.Error("an expired route was found")
+		}
+	})
+}
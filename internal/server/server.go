@@ -2,48 +2,157 @@ package server
 
 import (
 	"encoding/json"
-	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"runtime"
 	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
+	"waze/internal/config"
 	"waze/internal/graph"
+	"waze/internal/metrics"
 	"waze/internal/types"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/grpc"
 )
 
 type Server struct {
-	Graph *graph.Graph
+	Routes  *RouteCache
+	Hub     *Hub
+	Metrics *metrics.Registry
+
+	// the navigation requests waiting for the routing workers
+	Jobs chan PathRequest
+
+	// the messages of the report streams waiting to be applied, and the number of open streams
+	ingestQueue   chan ingestItem
+	reportStreams atomic.Int64
+	// the streams queue while they hold it for reading, the shutdown closes the queue under it
+	ingestMu     sync.RWMutex
+	ingestClosed bool
+
+	// the last reports and the speed history of the edges, and the speeds set by the admins
+	edgeLog   *edgeLog
+	overrides *overrides
+
+	opts Options
+	m    *serverMetrics
+
+	// the map the requests use, swapped by Reload
+	current  atomic.Pointer[Map]
+	reloadMu sync.Mutex
+
+	// the API keys, swapped by Reload. the buckets of the rate limits stay
+	auth     atomic.Pointer[Auth]
+	limiter  *limiter
+	upgrader websocket.Upgrader
+
+	httpServer *http.Server
+	listener   net.Listener
+	// the gRPC API, nil when it is off
+	grpcServer   *grpc.Server
+	grpcListener net.Listener
+	// draining is closed when the shutdown starts, the streams end then.
+	// stop is closed after the requests were drained, the workers and the loops end then
+	draining chan struct{}
+	stop     chan struct{}
+	loops    sync.WaitGroup
+}
+
+type Options struct {
+	MapFile      string
+	Workers      int    // routing workers, the number of CPUs when 0
+	StaticDir    string // the GUI files, not served when empty
+	SnapshotFile string // the traffic is saved there and loaded on start, nothing is saved when empty
+	GRPCAddr     string // the gRPC API is served there, it is off when empty
+	Auth         AuthOptions
+
+	// loads the config again on Reload, the map file is then taken from it. the config is not reloaded when nil
+	Config *config.Loader
 }
 
-func NewServer(mapFile string) *Server {
-	g, err := graph.LoadGraph(mapFile)
+func NewServer(opts Options) (*Server, error) {
+	g, err := graph.LoadGraph(opts.MapFile)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.NumCPU()
+	}
+	auth, err := newAuth(opts.Auth)
+	if err != nil {
+		return nil, err
 	}
-	return &Server{Graph: g}
-}
 
-func (s *Server) HandleTrafficBatch(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Only POST request allowed", http.StatusMethodNotAllowed)
-		return
+	s := &Server{
+		Routes:   NewRouteCache(g),
+		Hub:      NewHub(),
+		Metrics:  metrics.NewRegistry(),
+		Jobs:     make(chan PathRequest, JOB_QUEUE_SIZE),
+		opts:     opts,
+		draining: make(chan struct{}),
+		stop:     make(chan struct{}),
+		limiter:  newLimiter(),
+
+		ingestQueue: make(chan ingestItem, INGEST_QUEUE_SIZE),
+		edgeLog:     newEdgeLog(),
+		overrides:   newOverrides(),
+	}
+	s.current.Store(newMap(g, opts.MapFile))
+	s.auth.Store(auth)
+	s.upgrader.CheckOrigin = func(r *http.Request) bool {
+		return s.auth.Load().checkOrigin(r)
+	}
+	s.m = newServerMetrics(s)
+
+	if opts.SnapshotFile != "" {
+		// the server can start without the old traffic
+		if err := s.LoadSnapshot(opts.SnapshotFile, time.Now()); err != nil {
+			log.Printf("Traffic snapshot not loaded: %v", err)
+		}
 	}
+	return s, nil
+}
 
+func (s *Server) HandleTrafficBatch(w http.ResponseWriter, r *http.Request) {
 	var reports []types.TrafficReport
 	if err := json.NewDecoder(r.Body).Decode(&reports); err != nil {
-		http.Error(w, "Invalid Json", http.StatusBadRequest)
+		writeError(w, invalidParams("invalid JSON: %v", err))
 		return
 	}
 
+	s.Report(IdentityFrom(r.Context()), reports)
+	w.WriteHeader(http.StatusOK)
+}
+
+// Report applies a batch of traffic reports of the reporter and shows the cars to the clients,
+// the same for the HTTP and the gRPC API. it returns the number of reports applied to an edge
+func (s *Server) Report(reporter *Identity, reports []types.TrafficReport) int {
+	if len(reports) == 0 {
+		return 0
+	}
+	for i := range reports {
+		reports[i].Reporter = reporter.Name
+	}
+
+	g := s.Map().Graph
+	applied := s.updateSpeeds(g, reports, reporter.Trust)
+
+	// שליחת עדכון ל-GUI
+	s.broadcastCars(g, reports)
+	return applied
+}
+
+// update the edge speeds by the reports, in parallel. trust (0-1) is the weight of the reports, by their reporter.
+// it returns the number of reports that were applied
+func (s *Server) updateSpeeds(g *graph.Graph, reports []types.TrafficReport, trust float64) int {
 	// מקביליות בעדכון
 	numWorkers := 8
 	reportsCount := len(reports)
 
-	if reportsCount == 0 {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
 	if reportsCount < numWorkers {
 		numWorkers = 1
 	}
@@ -52,6 +161,7 @@ func (s *Server) HandleTrafficBatch(w http.ResponseWriter, r *http.Request) {
 
 	var wg sync.WaitGroup
 	wg.Add(numWorkers)
+	var total atomic.Int64
 
 	for i := 0; i < numWorkers; i++ {
 		start := i * chunkSize
@@ -59,28 +169,63 @@ func (s *Server) HandleTrafficBatch(w http.ResponseWriter, r *http.Request) {
 
 		go func(startIdx, endIdx int) {
 			defer wg.Done()
+			applied, unknownEdge, invalidSpeed, untrusted := 0, 0, 0, 0
 			for j := startIdx; j < endIdx; j++ {
 				report := reports[j]
-				if edge, exists := s.Graph.Edges[report.EdgeID]; exists && report.CarID != -1 {
-					edge.UpdateSpeed(report.Speed)
+				if report.CarID == -1 {
+					continue
+				}
+				edge, exists := g.Edges[report.EdgeID]
+				switch {
+				case !exists:
+					unknownEdge++
+				case !validSpeed(g, report.Speed):
+					invalidSpeed++
+				case trust <= 0:
+					untrusted++
+				default:
+					edge.UpdateSpeedWeighted(report.Speed, trust)
+					applied++
 				}
 			}
+			total.Add(int64(applied))
+			s.m.reportsIngested.Add(float64(applied))
+			s.m.reportsRejected.With(REJECT_UNKNOWN_EDGE).Add(float64(unknownEdge))
+			s.m.reportsRejected.With(REJECT_INVALID_SPEED).Add(float64(invalidSpeed))
+			s.m.reportsRejected.With(REJECT_UNTRUSTED).Add(float64(untrusted))
 		}(start, end)
 	}
 
 	wg.Wait()
+	if trust > 0 {
+		s.edgeLog.record(g, reports, time.Now())
+	}
 
-	// שליחת עדכון ל-GUI
-	if GlobalHub != nil {
-		carPositions := s.calculateCarPositions(reports)
-		GlobalHub.BroadcastUpdate("cars", carPositions)
+	// the cached routes of the edges that changed a lot are dropped
+	updated := make([]*graph.Edge, 0, len(reports))
+	seen := make(map[int]bool, len(reports))
+	for _, report := range reports {
+		if edge, exists := g.Edges[report.EdgeID]; exists && report.CarID != -1 && !seen[edge.Id] {
+			seen[edge.Id] = true
+			updated = append(updated, edge)
+		}
 	}
+	s.Routes.UpdateSpeeds(updated)
+	return int(total.Load())
+}
 
-	w.WriteHeader(http.StatusOK)
+// a reported speed must be positive and a speed a car can drive on g, NaN is neither
+func validSpeed(g *graph.Graph, speed float64) bool {
+	return speed > 0 && speed <= g.MaxSpeed()
+}
+
+func (s *Server) broadcastCars(g *graph.Graph, reports []types.TrafficReport) {
+	carPositions := calculateCarPositions(g, reports)
+	s.Hub.BroadcastCars(carPositions)
 }
 
 // חישוב מיקומי מכוניות על המפה
-func (s *Server) calculateCarPositions(reports []types.TrafficReport) []CarPosition {
+func calculateCarPositions(g *graph.Graph, reports []types.TrafficReport) []CarPosition {
 	positions := make([]CarPosition, 0, len(reports))
 
 	for _, report := range reports {
@@ -88,24 +233,21 @@ func (s *Server) calculateCarPositions(reports []types.TrafficReport) []CarPosit
 			continue
 		}
 
-		edge, exists := s.Graph.Edges[report.EdgeID]
+		edge, exists := g.Edges[report.EdgeID]
 		if !exists {
 			continue
 		}
 
-		fromNode := s.Graph.Nodes[edge.From]
-		toNode := s.Graph.Nodes[edge.To]
-
-		// נניח progress של 0.5 כברירת מחדל (אפשר לשפר בהמשך)
-		progress := 0.5
-		x := fromNode.X + (toNode.X-fromNode.X)*progress
-		y := fromNode.Y + (toNode.Y-fromNode.Y)*progress
+		// the car follows the edge, so the heading is the one of the road at its position
+		progress := max(0, min(report.Progress, 1))
+		x, y, heading := g.PositionOnEdge(edge, progress)
 
 		positions = append(positions, CarPosition{
 			CarID:    report.CarID,
 			EdgeID:   report.EdgeID,
 			Progress: progress,
 			Speed:    report.Speed,
+			Heading:  heading,
 			X:        x,
 			Y:        y,
 		})
@@ -122,26 +264,31 @@ func (s *Server) HandleNavigation(w http.ResponseWriter, r *http.Request) {
 	toId, err2 := strconv.Atoi(toStr)
 
 	if err1 != nil || err2 != nil {
-		http.Error(w, "Invalid 'from' or 'to' parameters", http.StatusBadRequest)
+		writeError(w, invalidParams("invalid 'from' or 'to' parameters"))
 		return
 	}
-	req := PathRequest{
-		StartNodeId:     fromId,
-		EndNodeId:       toId,
-		ResponseChannel: make(chan PathResult),
-	}
-
-	JobQueue <- req
 
-	result := <-req.ResponseChannel
-
-	// if no route was found
-	if result.Err != nil {
-		fmt.Printf("The Error is: %s\n", result.Err.Error())
-		http.Error(w, result.Err.Error(), http.StatusNotFound)
-		return
+	nav := types.NavigationRequest{
+		FromNodeId: &fromId,
+		ToNodeId:   &toId,
+		Profile:    r.URL.Query().Get("profile"),
+	}
+	if str := r.URL.Query().Get("instructions"); str != "" {
+		withInstructions, err := strconv.ParseBool(str)
+		if err != nil {
+			writeError(w, invalidParams("invalid 'instructions' parameter"))
+			return
+		}
+		nav.Instructions = withInstructions
+	}
+	if str := r.URL.Query().Get("timeout"); str != "" {
+		seconds, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			writeError(w, invalidParams("invalid 'timeout' parameter"))
+			return
+		}
+		nav.Timeout = seconds
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result.Response)
-}
\ No newline at end of file
+	s.navigate(w, r, nav)
+}
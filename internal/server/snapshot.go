@@ -0,0 +1,117 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// how often the traffic is saved while the server runs. older snapshots are not loaded, their speeds say nothing about now
+const (
+	SNAPSHOT_INTERVAL = time.Minute
+	SNAPSHOT_MAX_AGE  = 30 * time.Minute
+)
+
+// the learned speeds of the edges, so a restart doesn't start from the speed limits
+type TrafficSnapshot struct {
+	Time  time.Time      `json:"time"`
+	Edges []EdgeSnapshot `json:"edges"`
+}
+
+type EdgeSnapshot struct {
+	EdgeID     int       `json:"edge_id"`
+	Speed      float64   `json:"speed"`
+	Reports    uint64    `json:"reports"`
+	LastUpdate time.Time `json:"last_update"`
+}
+
+// SaveSnapshot writes the speeds of the edges that had reports. the file is replaced at once, never half written
+func (s *Server) SaveSnapshot(fileName string) error {
+	snapshot := TrafficSnapshot{Time: time.Now(), Edges: make([]EdgeSnapshot, 0)}
+	for _, edge := range s.Map().Graph.Edges {
+		if reports := edge.ReportCount(); reports > 0 {
+			snapshot.Edges = append(snapshot.Edges, EdgeSnapshot{
+				EdgeID:     edge.Id,
+				Speed:      edge.GetCurrentSpeed(),
+				Reports:    reports,
+				LastUpdate: edge.LastUpdate(),
+			})
+		}
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(fileName), filepath.Base(fileName)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	// CreateTemp makes the file readable only by its owner
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), fileName)
+}
+
+// LoadSnapshot restores the speeds of a snapshot. a missing file is not an error
+func (s *Server) LoadSnapshot(fileName string, now time.Time) error {
+	data, err := os.ReadFile(fileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var snapshot TrafficSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("invalid snapshot %s: %w", fileName, err)
+	}
+	if age := now.Sub(snapshot.Time); age > SNAPSHOT_MAX_AGE {
+		log.Printf("Traffic snapshot is %v old, starting from the speed limits", age.Round(time.Minute))
+		return nil
+	}
+
+	g := s.Map().Graph
+	restored := 0
+	for _, saved := range snapshot.Edges {
+		if edge, exists := g.Edges[saved.EdgeID]; exists && saved.Speed > 0 {
+			edge.Restore(saved.Speed, saved.Reports, saved.LastUpdate)
+			restored++
+		}
+	}
+	log.Printf("Restored the traffic of %d edges from %s", restored, fileName)
+	return nil
+}
+
+// saves the traffic every SNAPSHOT_INTERVAL until the server stops, the last save is done by Shutdown
+func (s *Server) saveSnapshots() {
+	ticker := time.NewTicker(SNAPSHOT_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.SaveSnapshot(s.opts.SnapshotFile); err != nil {
+				log.Printf("Error saving traffic snapshot: %v", err)
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// a comment line is sent this often on a quiet SSE stream, so proxies keep the connection open
+const SSE_HEARTBEAT = 15 * time.Second
+
+// the time a long-poll request waits for new events, by default and at most
+const (
+	POLL_TIMEOUT     = 25 * time.Second
+	MAX_POLL_TIMEOUT = 60 * time.Second
+)
+
+// event types that are not updates of the live state, they are sent whatever the topics are
+const (
+	EVENT_INIT  = "init"  // the graph, when starting without a last event id
+	EVENT_RESET = "reset" // the client missed events, a snapshot of the whole state follows
+)
+
+type PollResponse struct {
+	LastID uint64  `json:"last_id"` // the since of the next request
+	Reset  bool    `json:"reset"`   // the events are a snapshot, the state of the client must be cleared first
+	Events []Event `json:"events"`
+}
+
+// the subscription of an SSE or long-poll client, from the topics query parameter
+func (s *Server) querySubscription(r *http.Request) (*Subscription, error) {
+	msg := ClientMessage{}
+	if topics := r.URL.Query().Get("topics"); topics != "" {
+		msg.Topics = strings.Split(topics, ",")
+	}
+	return newSubscription(msg, s.Map().Matcher.Index)
+}
+
+// the last event id of the client, from the Last-Event-ID header of a reconnecting EventSource
+// or from a query parameter. found is false when the client has no history
+func lastEventID(r *http.Request, param string) (id uint64, found bool, err error) {
+	str := r.Header.Get("Last-Event-ID")
+	if str == "" {
+		str = r.URL.Query().Get(param)
+	}
+	if str == "" {
+		return 0, false, nil
+	}
+	id, err = strconv.ParseUint(str, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid last event id '%s'", str)
+	}
+	return id, true, nil
+}
+
+// the events a client starts with: the graph for a new client, or a reset for one that missed events,
+// followed by a snapshot of the live state
+func (s *Server) startEvents(fresh bool) ([]Event, uint64) {
+	snapshot, lastID := s.Hub.snapshot()
+
+	first := GUIUpdate{Type: EVENT_RESET, Data: struct{}{}}
+	if fresh {
+		first = GUIUpdate{Type: EVENT_INIT, Data: s.Map().GraphData()}
+	}
+	data, err := json.Marshal(first)
+	if err != nil {
+		log.Printf("Error marshaling update: %v", err)
+		return snapshot, lastID
+	}
+	return append([]Event{{ID: lastID, Type: first.Type, Update: data}}, snapshot...), lastID
+}
+
+func filterEvents(events []Event, sub *Subscription) []Event {
+	filtered := make([]Event, 0, len(events))
+	for _, event := range events {
+		if event.Type == EVENT_INIT || event.Type == EVENT_RESET || sub.Wants(event.Type) {
+			filtered = append(filtered, event)
+		}
+	}
+	return filtered
+}
+
+// HandleEvents streams the GUI updates as Server-Sent Events.
+// a reconnecting client gets the events it missed, or a reset and a snapshot when they are no longer kept
+func (s *Server) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, fmt.Errorf("streaming not supported"))
+		return
+	}
+	sub, err := s.querySubscription(r)
+	if err != nil {
+		writeError(w, invalidParams("%v", err))
+		return
+	}
+	lastID, found, err := lastEventID(r, "last_event_id")
+	if err != nil {
+		writeError(w, invalidParams("%v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// nginx would buffer the stream otherwise
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	fmt.Fprintf(w, "retry: %d\n\n", 2000)
+	if !found {
+		var events []Event
+		events, lastID = s.startEvents(true)
+		writeSSE(w, filterEvents(events, sub))
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(SSE_HEARTBEAT)
+	defer heartbeat.Stop()
+
+	for {
+		events, ok, changed := s.Hub.events.Since(lastID)
+		if !ok {
+			events, lastID = s.startEvents(false)
+		} else if len(events) > 0 {
+			lastID = events[len(events)-1].ID
+		}
+		if len(events) > 0 {
+			if err := writeSSE(w, filterEvents(events, sub)); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+
+		select {
+		case <-changed:
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-s.draining:
+			// the client reconnects to another server with its last event id
+			return
+		}
+	}
+}
+
+func writeSSE(w http.ResponseWriter, events []Event) error {
+	for _, event := range events {
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HandlePoll is the long-poll fallback of HandleEvents. it returns the events after ?since=,
+// waiting up to ?timeout= seconds when there are none yet
+func (s *Server) HandlePoll(w http.ResponseWriter, r *http.Request) {
+	sub, err := s.querySubscription(r)
+	if err != nil {
+		writeError(w, invalidParams("%v", err))
+		return
+	}
+	since, found, err := lastEventID(r, "since")
+	if err != nil {
+		writeError(w, invalidParams("%v", err))
+		return
+	}
+
+	timeout := POLL_TIMEOUT
+	if str := r.URL.Query().Get("timeout"); str != "" {
+		seconds, err := strconv.ParseFloat(str, 64)
+		if err != nil || seconds < 0 || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+			writeError(w, invalidParams("invalid 'timeout' parameter"))
+			return
+		}
+		// clamped before the conversion, a big timeout would overflow the duration
+		timeout = time.Duration(math.Min(seconds, MAX_POLL_TIMEOUT.Seconds()) * float64(time.Second))
+	}
+
+	response := PollResponse{LastID: since}
+	if !found {
+		response.Events, response.LastID = s.startEvents(true)
+		response.Reset = true
+	} else {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+	wait:
+		for {
+			events, ok, changed := s.Hub.events.Since(since)
+			if !ok {
+				response.Events, response.LastID = s.startEvents(false)
+				response.Reset = true
+				break
+			}
+			// events of other topics only move the last id forward
+			if len(events) > 0 {
+				response.LastID = events[len(events)-1].ID
+				if response.Events = filterEvents(events, sub); len(response.Events) > 0 {
+					break
+				}
+				since = response.LastID
+			}
+
+			select {
+			case <-changed:
+			case <-timer.C:
+				break wait
+			case <-s.draining:
+				break wait
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+
+	if response.Events == nil {
+		response.Events = []Event{}
+	}
+	response.Events = filterEvents(response.Events, sub)
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// a client gets a full keyframe every this many frames, so it never drifts for long
+const KEYFRAME_INTERVAL = 30
+
+// cars without a report for this long left the map
+const CAR_TIMEOUT = 10 * time.Second
+
+// changes smaller than these are not sent in a delta
+const (
+	PROGRESS_EPSILON   = 0.001
+	CAR_SPEED_EPSILON  = 0.5 // KM/hour
+	EDGE_SPEED_EPSILON = 1.0 // KM/hour
+	CONFIDENCE_EPSILON = 0.05
+	HEADING_EPSILON    = 1.0 // degrees
+)
+
+type liveCar struct {
+	position CarPosition
+	lastSeen time.Time
+}
+
+// the latest known cars and edge congestion, that the clients are updated with
+type liveState struct {
+	mu    sync.RWMutex
+	cars  map[int]*liveCar
+	edges map[int]EdgeCongestion
+	// advances on every car update, so the JSON clients get the car list only when it changed
+	carsVersion uint64
+}
+
+func newLiveState() *liveState {
+	return &liveState{
+		cars:  make(map[int]*liveCar),
+		edges: make(map[int]EdgeCongestion),
+	}
+}
+
+// forgets all the cars and edges, they are of a map that was replaced
+func (ls *liveState) reset() {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	ls.cars = make(map[int]*liveCar)
+	ls.edges = make(map[int]EdgeCongestion)
+	ls.carsVersion++
+}
+
+// applies new car positions, and forgets the cars that stopped reporting
+func (ls *liveState) updateCars(cars []CarPosition, now time.Time) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	ls.carsVersion++
+	for _, car := range cars {
+		ls.cars[car.CarID] = &liveCar{position: car, lastSeen: now}
+	}
+	for carID, live := range ls.cars {
+		if now.Sub(live.lastSeen) > CAR_TIMEOUT {
+			delete(ls.cars, carID)
+		}
+	}
+}
+
+func (ls *liveState) updateEdges(edges []EdgeCongestion) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	for _, edge := range edges {
+		ls.edges[edge.EdgeID] = edge
+	}
+}
+
+// the cars a client is subscribed to, ls.mu must be held
+func (ls *liveState) carsFor(sub *Subscription) []CarPosition {
+	cars := make([]CarPosition, 0)
+	if !sub.Wants(TOPIC_CARS) {
+		return cars
+	}
+	for _, live := range ls.cars {
+		if sub.HasEdge(live.position.EdgeID) {
+			cars = append(cars, live.position)
+		}
+	}
+	return cars
+}
+
+// what a client knows, the frames it gets are the difference between this and the live state.
+// every client has its own view, so filtering and throttling never break its sequence
+type clientView struct {
+	sequence    uint32
+	sinceKey    int
+	cars        map[int]CarPosition
+	edges       map[int]EdgeCongestion
+	carsVersion uint64 // the version of the last car list sent to a JSON client
+}
+
+func newClientView() *clientView {
+	return &clientView{
+		cars:  make(map[int]CarPosition),
+		edges: make(map[int]EdgeCongestion),
+	}
+}
+
+// the next frame of the client, a keyframe when asked for or every KEYFRAME_INTERVAL frames.
+// returns nil when nothing the client sees has changed. ls.mu must be held
+func (v *clientView) nextFrame(ls *liveState, sub *Subscription, keyframe bool) *Frame {
+	keyframe = keyframe || v.sinceKey+1 >= KEYFRAME_INTERVAL
+	frame := &Frame{Type: FRAME_DELTA}
+	if keyframe {
+		frame.Type = FRAME_KEYFRAME
+		v.cars = make(map[int]CarPosition)
+		v.edges = make(map[int]EdgeCongestion)
+	}
+
+	visible := ls.carsFor(sub)
+	seen := make(map[int]bool, len(visible))
+	for _, car := range visible {
+		seen[car.CarID] = true
+		if sent, exists := v.cars[car.CarID]; !exists || carMoved(sent, car) {
+			v.cars[car.CarID] = car
+			frame.Cars = append(frame.Cars, car)
+		}
+	}
+	// cars that left the map or the box
+	for carID := range v.cars {
+		if !seen[carID] {
+			delete(v.cars, carID)
+			frame.Removed = append(frame.Removed, carID)
+		}
+	}
+
+	frame.Edges = v.changedEdges(ls, sub)
+
+	if !keyframe && len(frame.Cars) == 0 && len(frame.Removed) == 0 && len(frame.Edges) == 0 {
+		return nil
+	}
+
+	v.sequence++
+	frame.Sequence = v.sequence
+	if keyframe {
+		v.sinceKey = 0
+	} else {
+		v.sinceKey++
+	}
+	return frame
+}
+
+// the edges in the box of the client whose congestion changed since they were last sent. ls.mu must be held
+func (v *clientView) changedEdges(ls *liveState, sub *Subscription) []EdgeCongestion {
+	changed := make([]EdgeCongestion, 0)
+	if !sub.Wants(TOPIC_EDGES) {
+		return changed
+	}
+	for edgeID, edge := range ls.edges {
+		if !sub.HasEdge(edgeID) {
+			continue
+		}
+		if sent, exists := v.edges[edgeID]; !exists || edgeChanged(sent, edge) {
+			v.edges[edgeID] = edge
+			changed = append(changed, edge)
+		}
+	}
+	return changed
+}
+
+func carMoved(sent, car CarPosition) bool {
+	if sent.EdgeID != car.EdgeID {
+		return true
+	}
+	heading := math.Abs(sent.Heading - car.Heading)
+	return math.Abs(sent.Progress-car.Progress) >= PROGRESS_EPSILON ||
+		math.Abs(sent.Speed-car.Speed) >= CAR_SPEED_EPSILON ||
+		math.Min(heading, 360-heading) >= HEADING_EPSILON
+}
+
+func edgeChanged(sent, edge EdgeCongestion) bool {
+	return sent.Incident != edge.Incident ||
+		math.Abs(sent.Speed-edge.Speed) >= EDGE_SPEED_EPSILON ||
+		math.Abs(sent.Confidence-edge.Confidence) >= CONFIDENCE_EPSILON
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"fmt"
+	"time"
+	"waze/internal/spatial"
+)
+
+// the topics a client can subscribe to. the topic of a JSON update is its type
+const (
+	TOPIC_CARS      = "cars"
+	TOPIC_EDGES     = "edges"     // edge congestion
+	TOPIC_ROUTES    = "routes"    // routes found by /api/navigate
+	TOPIC_INCIDENTS = "incidents" // nothing publishes incidents yet
+)
+
+var allTopics = []string{TOPIC_CARS, TOPIC_EDGES, TOPIC_ROUTES, TOPIC_INCIDENTS}
+
+// what a client wants to get. the box limits the cars and the edge speeds,
+// the other topics are not located and are sent whole
+type Subscription struct {
+	topics map[string]bool
+	box    *spatial.Box
+	edges  map[int]bool // edges inside the box, nil when there is no box
+	// at most this many car updates per second, 0 for every update
+	rate float64
+}
+
+// a message from the GUI, {"type": "subscribe"} or {"type": "resync"}
+type ClientMessage struct {
+	Type   string       `json:"type"`
+	Bbox   *spatial.Box `json:"bbox,omitempty"`
+	Topics []string     `json:"topics,omitempty"`
+	Rate   float64      `json:"rate,omitempty"`
+}
+
+// the subscription of a new client: every topic, on the whole map
+func defaultSubscription() *Subscription {
+	sub := &Subscription{topics: make(map[string]bool)}
+	for _, topic := range allTopics {
+		sub.topics[topic] = true
+	}
+	return sub
+}
+
+// builds the subscription of a subscribe message, the edges of the box are found with the index
+func newSubscription(msg ClientMessage, index *spatial.EdgeIndex) (*Subscription, error) {
+	if msg.Rate < 0 {
+		return nil, fmt.Errorf("rate must not be negative")
+	}
+	sub := defaultSubscription()
+	sub.rate = msg.Rate
+
+	if msg.Topics != nil {
+		sub.topics = make(map[string]bool)
+		for _, topic := range msg.Topics {
+			if !isTopic(topic) {
+				return nil, fmt.Errorf("unknown topic '%s'", topic)
+			}
+			sub.topics[topic] = true
+		}
+	}
+
+	if msg.Bbox != nil {
+		if msg.Bbox.MinX > msg.Bbox.MaxX || msg.Bbox.MinY > msg.Bbox.MaxY {
+			return nil, fmt.Errorf("invalid bbox")
+		}
+		sub.box = msg.Bbox
+		sub.edges = boxEdges(*msg.Bbox, index)
+	}
+	return sub, nil
+}
+
+func boxEdges(box spatial.Box, index *spatial.EdgeIndex) map[int]bool {
+	edges := make(map[int]bool)
+	for _, edge := range index.InBox(box) {
+		edges[edge.Id] = true
+	}
+	return edges
+}
+
+// the same subscription on a reloaded map, the edges of its box are found again
+func (sub *Subscription) reindex(index *spatial.EdgeIndex) *Subscription {
+	if sub.box == nil {
+		return sub
+	}
+	reindexed := *sub
+	reindexed.edges = boxEdges(*sub.box, index)
+	return &reindexed
+}
+
+func isTopic(topic string) bool {
+	for _, known := range allTopics {
+		if topic == known {
+			return true
+		}
+	}
+	return false
+}
+
+func (sub *Subscription) Wants(topic string) bool {
+	return sub.topics[topic]
+}
+
+// whether the edge (and the cars on it) is inside the box
+func (sub *Subscription) HasEdge(edgeID int) bool {
+	return sub.edges == nil || sub.edges[edgeID]
+}
+
+// whether enough time passed since the last update for the rate
+func (sub *Subscription) Due(lastSent, now time.Time) bool {
+	if sub.rate <= 0 {
+		return true
+	}
+	return now.Sub(lastSent) >= time.Duration(float64(time.Second)/sub.rate)
+}
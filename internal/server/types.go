@@ -1,16 +1,41 @@
 package server
 
-import "waze/internal/types"
+import (
+	"context"
+	"waze/internal/graph"
+	"waze/internal/navigation"
+)
 
 type PathRequest struct {
+	// canceled when the client is gone or its deadline passed, the search stops then
+	Ctx context.Context
+
+	// the map of the request, a reload doesn't change the graph of a running search
+	Graph *graph.Graph
+
 	StartNodeId int
 	EndNodeId   int
+	Profile     navigation.Profile
+
+	// a row of a matrix: the routes from StartNodeId to each of these, EndNodeId and Alternatives are not used
+	Targets []int
+
+	// number of alternative routes to add
+	Alternatives int
+
+	// a search of the debug endpoints, run instead of the alternatives. its stats are in the result
+	Search *searchQuery
+
+	// whether to add the encoded polyline and turn-by-turn instructions to the response
+	Geometry     bool
+	Instructions bool
 
-	// channel to notify when the response is ready
+	// channel to notify when the response is ready. it is buffered, so a worker never waits for a client that gave up
 	ResponseChannel chan PathResult
 }
 
 type PathResult struct {
-	Response types.NavigationResponse
-	Err      error
+	Routes []*navigation.PathResult // the best route first, then the alternatives. of a matrix row, a route per target or nil
+	Stats  *SearchStats             // of a debug search
+	Err    error
 }
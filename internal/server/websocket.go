@@ -1,84 +1,341 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
+	"waze/internal/polyline"
+	"waze/internal/spatial"
 
 	"github.com/gorilla/websocket"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+// a JSON update on its way to the clients subscribed to its topic
+type outgoing struct {
+	target *Client // nil for all the clients
+	topic  string
+	text   []byte
 }
 
-// Client עם mutex לכתיבה בטוחה
-type Client struct {
-	conn *websocket.Conn
-	mu   sync.Mutex
-	send chan []byte
+// a new map: the clients get its graph and then a keyframe of the state on it
+type hubReset struct {
+	init      []byte // for the JSON clients
+	initFrame []byte // for the binary clients
+	index     *spatial.EdgeIndex
 }
 
-func (c *Client) WriteMessage(data []byte) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	return c.conn.WriteMessage(websocket.TextMessage, data)
+// a subscription change or a resync asked by a client
+type clientRequest struct {
+	client *Client
+	sub    *Subscription
+	resync bool
 }
 
+// how often the clients held back by their rate are checked again
+const FLUSH_INTERVAL = 100 * time.Millisecond
+
 // מנהל חיבורי WebSocket
 type Hub struct {
 	clients    map[*Client]bool
-	broadcast  chan []byte
+	broadcast  chan outgoing
 	register   chan *Client
 	unregister chan *Client
+	requests   chan clientRequest
+	resets     chan hubReset
 	mu         sync.RWMutex
-}
 
-var GlobalHub *Hub
+	// the latest cars and edge speeds. changed is signaled when they are updated,
+	// many updates before the hub gets to them become one
+	live    *liveState
+	changed chan struct{}
+
+	// the same updates for the SSE and long-poll clients. logView is what the log already holds
+	events  *EventLog
+	logView *clientView
+	logMu   sync.Mutex
+
+	// messages that were not delivered because a queue was full
+	dropped atomic.Uint64
+
+	// quit is closed by Close, done when Run returned. writers are the running client writers
+	quit      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	writers   sync.WaitGroup
+}
 
 func NewHub() *Hub {
 	return &Hub{
 		clients:    make(map[*Client]bool),
-		broadcast:  make(chan []byte, 256),
+		broadcast:  make(chan outgoing, 256),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		requests:   make(chan clientRequest, 16),
+		resets:     make(chan hubReset),
+		live:       newLiveState(),
+		changed:    make(chan struct{}, 1),
+		events:     NewEventLog(),
+		logView:    newClientView(),
+		quit:       make(chan struct{}),
+		done:       make(chan struct{}),
 	}
 }
 
 func (h *Hub) Run() {
+	ticker := time.NewTicker(FLUSH_INTERVAL)
+	defer ticker.Stop()
+	defer close(h.done)
+
 	for {
 		select {
+		case <-h.quit:
+			// every writer sends a close frame
+			h.mu.Lock()
+			for client := range h.clients {
+				h.removeClient(client)
+			}
+			h.mu.Unlock()
+			return
+
 		case client := <-h.register:
 			h.mu.Lock()
 			h.clients[client] = true
 			h.mu.Unlock()
 			log.Printf("Client connected. Total: %d", len(h.clients))
+			// the new client starts with the whole state
+			h.updateClient(client, true, time.Now())
 
 		case client := <-h.unregister:
 			h.mu.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				client.conn.Close()
-			}
+			h.removeClient(client)
 			h.mu.Unlock()
 			log.Printf("Client disconnected. Total: %d", len(h.clients))
 
+		case req := <-h.requests:
+			if !h.clients[req.client] {
+				continue
+			}
+			if req.sub != nil {
+				req.client.sub = req.sub
+				// the next frame removes what left the box and adds what entered it,
+				// and the JSON clients get the car list of the new box
+				req.client.view.carsVersion = 0
+				req.client.pending = true
+			}
+			if req.resync {
+				h.updateClient(req.client, true, time.Now())
+			}
+
+		case reset := <-h.resets:
+			for client := range h.clients {
+				client.sub = client.sub.reindex(reset.index)
+				client.view = newClientView()
+				connected := false
+				if client.binary {
+					connected = h.deliver(client, websocket.BinaryMessage, reset.initFrame)
+				} else {
+					connected = h.deliver(client, websocket.TextMessage, reset.init)
+				}
+				if connected {
+					h.updateClient(client, true, time.Now())
+				}
+			}
+
 		case message := <-h.broadcast:
-			h.mu.Lock()
+			// only this loop changes the clients, deliver may remove the client it is given
 			for client := range h.clients {
-				if err := client.WriteMessage(message); err != nil {
-					client.conn.Close()
-					delete(h.clients, client)
+				if message.target != nil && client != message.target {
+					continue
+				}
+				if message.target == nil && !client.sub.Wants(message.topic) {
+					continue
 				}
+				h.deliver(client, websocket.TextMessage, message.text)
 			}
-			h.mu.Unlock()
+
+		case <-h.changed:
+			for client := range h.clients {
+				client.pending = true
+			}
+			h.flush(time.Now())
+
+		case now := <-ticker.C:
+			h.flush(now)
+		}
+	}
+}
+
+// updates the clients that have changes waiting and that their rate allows
+func (h *Hub) flush(now time.Time) {
+	for client := range h.clients {
+		if client.pending && client.sub.Due(client.lastSent, now) {
+			h.updateClient(client, false, now)
 		}
 	}
 }
 
+// sends the cars and edge congestion the client is subscribed to.
+// binary clients get the delta from their view, JSON clients the full car list and the changed edges.
+// it stops when the client is disconnected for being too slow
+func (h *Hub) updateClient(client *Client, keyframe bool, now time.Time) {
+	client.pending = false
+	client.lastSent = now
+
+	messages := make([][]byte, 0, 2)
+	h.live.mu.RLock()
+	if client.binary {
+		if frame := client.view.nextFrame(h.live, client.sub, keyframe); frame != nil {
+			messages = append(messages, frame.Encode())
+		}
+	} else {
+		if client.sub.Wants(TOPIC_CARS) && client.view.carsVersion != h.live.carsVersion {
+			client.view.carsVersion = h.live.carsVersion
+			messages = appendJSON(messages, GUIUpdate{Type: TOPIC_CARS, Data: h.live.carsFor(client.sub)})
+		}
+		if edges := client.view.changedEdges(h.live, client.sub); len(edges) > 0 {
+			messages = appendJSON(messages, GUIUpdate{Type: TOPIC_EDGES, Data: edges})
+		}
+	}
+	h.live.mu.RUnlock()
+
+	messageType := websocket.TextMessage
+	if client.binary {
+		messageType = websocket.BinaryMessage
+	}
+	for _, data := range messages {
+		if !h.deliver(client, messageType, data) {
+			return
+		}
+	}
+}
+
+// queues a message to the client, and disconnects the client when it stopped reading for too long.
+// it returns false when the client was disconnected, nothing may be delivered to it after that.
+// called by the hub loop only, without h.mu
+func (h *Hub) deliver(client *Client, messageType int, data []byte) bool {
+	if !client.enqueue(messageType, data) {
+		return true
+	}
+	h.dropped.Add(1)
+	if lagging := client.lagging.Load(); lagging > MAX_LAGGING_DROPS {
+		log.Printf("Dropping slow client after %d lost messages", lagging)
+		h.mu.Lock()
+		h.removeClient(client)
+		h.mu.Unlock()
+		return false
+	}
+	return true
+}
+
+func appendJSON(messages [][]byte, update GUIUpdate) [][]byte {
+	data, err := json.Marshal(update)
+	if err != nil {
+		log.Printf("Error marshaling update: %v", err)
+		return messages
+	}
+	return append(messages, data)
+}
+
+// Close disconnects all the clients with a close frame, and waits until the frames are written or ctx is done
+func (h *Hub) Close(ctx context.Context) error {
+	h.closeOnce.Do(func() { close(h.quit) })
+	select {
+	case <-h.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	written := make(chan struct{})
+	go func() {
+		h.writers.Wait()
+		close(written)
+	}()
+	select {
+	case <-written:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// starts the writer of the client, the hub waits for it on Close
+func (h *Hub) startWriter(client *Client) {
+	h.writers.Add(1)
+	go func() {
+		defer h.writers.Done()
+		client.writePump()
+	}()
+}
+
+// adds the client to the hub. false when the hub is closed, then the client is closed too
+func (h *Hub) add(client *Client) bool {
+	select {
+	case h.register <- client:
+		return true
+	case <-h.quit:
+		close(client.send)
+		return false
+	}
+}
+
+func (h *Hub) remove(client *Client) {
+	select {
+	case h.unregister <- client:
+	case <-h.done:
+		// all the clients were removed when the hub stopped
+	}
+}
+
+func (h *Hub) request(req clientRequest) {
+	select {
+	case h.requests <- req:
+	case <-h.done:
+	}
+}
+
+// Reset moves the clients to a new map. the live state is cleared, the WebSocket clients get the new graph
+// and a keyframe, and the SSE clients an init event. it returns once the hub did it, or when it stopped
+func (h *Hub) Reset(data GraphData, index *spatial.EdgeIndex) {
+	h.live.reset()
+
+	h.logMu.Lock()
+	h.logView = newClientView()
+	h.logEvent(EVENT_INIT, data)
+	h.logMu.Unlock()
+
+	init, err := json.Marshal(GUIUpdate{Type: EVENT_INIT, Data: data})
+	if err != nil {
+		log.Printf("Error marshaling update: %v", err)
+		return
+	}
+	select {
+	case h.resets <- hubReset{init: init, initFrame: EncodeInit(data), index: index}:
+	case <-h.done:
+	}
+}
+
+func (h *Hub) ClientCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// must be called with h.mu held
+func (h *Hub) removeClient(client *Client) {
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+	delete(h.clients, client)
+	// the writer sends a close frame and closes the connection
+	close(client.send)
+}
+
 // הודעה שנשלחת ל-GUI
 type GUIUpdate struct {
 	Type string      `json:"type"`
@@ -90,11 +347,12 @@ type CarPosition struct {
 	EdgeID   int     `json:"edge_id"`
 	Progress float64 `json:"progress"` // 0-1 על הקשת
 	Speed    float64 `json:"speed"`
+	Heading  float64 `json:"heading"` // מעלות בכיוון השעון מהצפון
 	X        float64 `json:"x"`
 	Y        float64 `json:"y"`
 }
 
-// שליחת עדכון לכל הלקוחות
+// שליחת עדכון ללקוחות שנרשמו לנושא (הסוג של העדכון)
 func (h *Hub) BroadcastUpdate(updateType string, data interface{}) {
 	update := GUIUpdate{
 		Type: updateType,
@@ -105,44 +363,141 @@ func (h *Hub) BroadcastUpdate(updateType string, data interface{}) {
 		log.Printf("Error marshaling update: %v", err)
 		return
 	}
-	h.broadcast <- jsonData
+	h.logEvent(updateType, data)
+	h.queue(outgoing{topic: updateType, text: jsonData})
+}
+
+// passes a message to the hub loop without blocking, it is dropped when the loop is that far behind
+func (h *Hub) queue(message outgoing) {
+	select {
+	case h.broadcast <- message:
+	default:
+		h.dropped.Add(1)
+	}
+}
+
+// BroadcastCars updates the live cars, every client then gets what it is subscribed to
+func (h *Hub) BroadcastCars(cars []CarPosition) {
+	h.live.updateCars(cars, time.Now())
+	h.logEvent(TOPIC_CARS, cars)
+	h.notifyChanged()
+}
+
+// BroadcastEdges updates the live edge congestion, every client then gets the edges that changed in its box
+func (h *Hub) BroadcastEdges(edges []EdgeCongestion) {
+	h.live.updateEdges(edges)
+
+	h.logMu.Lock()
+	h.live.mu.RLock()
+	changed := h.logView.changedEdges(h.live, defaultSubscription())
+	h.live.mu.RUnlock()
+	if len(changed) > 0 {
+		h.logEvent(TOPIC_EDGES, changed)
+	}
+	h.logMu.Unlock()
+
+	h.notifyChanged()
+}
+
+func (h *Hub) logEvent(updateType string, data interface{}) {
+	if err := h.events.Append(updateType, data); err != nil {
+		log.Printf("Error marshaling update: %v", err)
+	}
+}
+
+// the whole live state as events, for clients that start without history or missed too much.
+// lastID is the id of the last event the snapshot already covers
+func (h *Hub) snapshot() (events []Event, lastID uint64) {
+	lastID = h.events.LastID()
+
+	h.live.mu.RLock()
+	defer h.live.mu.RUnlock()
+
+	sub := defaultSubscription()
+	for _, update := range []GUIUpdate{
+		{Type: TOPIC_CARS, Data: h.live.carsFor(sub)},
+		{Type: TOPIC_EDGES, Data: newClientView().changedEdges(h.live, sub)},
+	} {
+		data, err := json.Marshal(update)
+		if err != nil {
+			log.Printf("Error marshaling update: %v", err)
+			continue
+		}
+		events = append(events, Event{ID: lastID, Type: update.Type, Update: data})
+	}
+	return events, lastID
+}
+
+func (h *Hub) notifyChanged() {
+	select {
+	case h.changed <- struct{}{}:
+	default:
+		// the hub was already told
+	}
+}
+
+// sends a JSON message to a single client
+func (h *Hub) sendTo(client *Client, updateType string, data interface{}) {
+	jsonData, err := json.Marshal(GUIUpdate{Type: updateType, Data: data})
+	if err != nil {
+		log.Printf("Error marshaling update: %v", err)
+		return
+	}
+	h.queue(outgoing{target: client, text: jsonData})
 }
 
 // WebSocket endpoint handler
 func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade error: %v", err)
 		return
 	}
 
-	client := &Client{
-		conn: conn,
-		send: make(chan []byte, 256),
-	}
+	client := newClient(conn, r.URL.Query().Get("v") == strconv.Itoa(PROTOCOL_VERSION))
+	s.Hub.startWriter(client)
 
-	GlobalHub.register <- client
+	// שליחת הגרף הראשוני ללקוח, לפני כל עדכון אחר
+	graphData := s.Map().GraphData()
+	if client.binary {
+		client.enqueue(websocket.BinaryMessage, EncodeInit(graphData))
+	} else {
+		initMsg := GUIUpdate{
+			Type: "init",
+			Data: graphData,
+		}
+		jsonData, _ := json.Marshal(initMsg)
+		client.enqueue(websocket.TextMessage, jsonData)
+	}
 
-	// שליחת הגרף הראשוני ללקוח (דרך ה-mutex)
-	graphData := s.GetGraphData()
-	initMsg := GUIUpdate{
-		Type: "init",
-		Data: graphData,
+	if !s.Hub.add(client) {
+		return
 	}
-	jsonData, _ := json.Marshal(initMsg)
-	client.WriteMessage(jsonData)
 
-	// האזנה להודעות מהלקוח (לסגירה)
+	// האזנה להודעות מהלקוח
 	go func() {
-		defer func() {
-			GlobalHub.unregister <- client
-		}()
-		for {
-			_, _, err := conn.ReadMessage()
-			if err != nil {
-				break
+		defer s.Hub.remove(client)
+		client.readPump(func(data []byte) {
+			var msg ClientMessage
+			if err := json.Unmarshal(data, &msg); err != nil {
+				s.Hub.sendTo(client, "error", "invalid message")
+				return
 			}
-		}
+			switch msg.Type {
+			case "subscribe":
+				sub, err := newSubscription(msg, s.Map().Matcher.Index)
+				if err != nil {
+					s.Hub.sendTo(client, "error", err.Error())
+					return
+				}
+				s.Hub.request(clientRequest{client: client, sub: sub})
+			case "resync":
+				// the client missed a frame
+				s.Hub.request(clientRequest{client: client, resync: true})
+			default:
+				s.Hub.sendTo(client, "error", fmt.Sprintf("unknown message type '%s'", msg.Type))
+			}
+		})
 	}()
 }
 
@@ -168,13 +523,21 @@ type EdgeData struct {
 	ToY        float64 `json:"to_y"`
 	Length     float64 `json:"length"`
 	SpeedLimit float64 `json:"speed_limit"`
+	RoadClass  string  `json:"road_class"`
+	Lanes      int     `json:"lanes"`
+	Name       string  `json:"name,omitempty"`
+	OneWay     bool    `json:"oneway"`
+	Toll       bool    `json:"toll,omitempty"`
+	Geometry   string  `json:"geometry,omitempty"` // encoded polyline, only for curved edges
 }
 
-func (s *Server) GetGraphData() GraphData {
-	nodes := make([]NodeData, 0, len(s.Graph.Nodes))
-	edges := make([]EdgeData, 0, len(s.Graph.Edges))
+// GraphData returns the map as the GUI draws it
+func (m *Map) GraphData() GraphData {
+	g := m.Graph
+	nodes := make([]NodeData, 0, len(g.Nodes))
+	edges := make([]EdgeData, 0, len(g.Edges))
 
-	for _, node := range s.Graph.Nodes {
+	for _, node := range g.Nodes {
 		nodes = append(nodes, NodeData{
 			ID: node.Id,
 			X:  node.X,
@@ -182,9 +545,15 @@ func (s *Server) GetGraphData() GraphData {
 		})
 	}
 
-	for _, edge := range s.Graph.Edges {
-		fromNode := s.Graph.Nodes[edge.From]
-		toNode := s.Graph.Nodes[edge.To]
+	for _, edge := range g.Edges {
+		fromNode := g.Nodes[edge.From]
+		toNode := g.Nodes[edge.To]
+
+		geometry := ""
+		if len(edge.Geometry) > 0 {
+			geometry = polyline.Encode(g.EdgePoints(edge))
+		}
+
 		edges = append(edges, EdgeData{
 			ID:         edge.Id,
 			From:       edge.From,
@@ -195,8 +564,14 @@ func (s *Server) GetGraphData() GraphData {
 			ToY:        toNode.Y,
 			Length:     edge.Length,
 			SpeedLimit: edge.SpeedLimit,
+			RoadClass:  string(edge.Class()),
+			Lanes:      edge.LaneCount(),
+			Name:       edge.Name,
+			OneWay:     edge.OneWay,
+			Toll:       edge.Toll,
+			Geometry:   geometry,
 		})
 	}
 
 	return GraphData{Nodes: nodes, Edges: edges}
-}
\ No newline at end of file
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func runHub(t *testing.T) *Hub {
+	t.Helper()
+	h := NewHub()
+	go h.Run()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := h.Close(ctx); err != nil {
+			t.Errorf("the hub did not stop: %v", err)
+		}
+	})
+	return h
+}
+
+// a client that never reads: no writer and a full queue. drops more lost messages disconnect it
+func stuckClient(binary bool, drops int64) *Client {
+	client := newClient(nil, binary)
+	for i := 0; i < SEND_QUEUE_SIZE; i++ {
+		client.send <- wsMessage{messageType: websocket.TextMessage, data: []byte("{}")}
+	}
+	client.lagging.Store(MAX_LAGGING_DROPS + 1 - drops)
+	return client
+}
+
+// waits until the hub disconnected the client, and checks its queue was closed
+func waitDisconnected(t *testing.T, h *Hub, client *Client) {
+	t.Helper()
+	connected := func() bool {
+		h.mu.RLock()
+		defer h.mu.RUnlock()
+		return h.clients[client]
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	// lagging is over the limit only after the hub had the client
+	for client.lagging.Load() <= MAX_LAGGING_DROPS || connected() {
+		if time.Now().After(deadline) {
+			t.Fatal("the client that never reads was not disconnected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for range client.send {
+	}
+}
+
+func TestHubDisconnectsClientThatNeverReads(t *testing.T) {
+	h := runHub(t)
+	client := newClient(nil, false)
+	if !h.add(client) {
+		t.Fatal("the hub refused the client")
+	}
+
+	// blocks until the hub loop takes every message, it must not get stuck on its own lock
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < SEND_QUEUE_SIZE+MAX_LAGGING_DROPS+10; i++ {
+			select {
+			case h.broadcast <- outgoing{topic: TOPIC_CARS, text: []byte("{}")}:
+			case <-time.After(2 * time.Second):
+				return
+			}
+		}
+	}()
+	waitDisconnected(t, h, client)
+	<-done
+
+	// the hub still works
+	if !h.add(newClient(nil, false)) {
+		t.Fatal("the hub stopped after disconnecting the client")
+	}
+}
+
+func TestHubStopsUpdatingDisconnectedClient(t *testing.T) {
+	h := runHub(t)
+	// a car list and an edge update, the first one disconnects the client
+	h.BroadcastCars([]CarPosition{{CarID: 1, EdgeID: 1, Progress: 0.5, Speed: 30}})
+	h.BroadcastEdges([]EdgeCongestion{{EdgeID: 1, Speed: 30, Ratio: 0.5, Confidence: 1}})
+
+	client := stuckClient(false, 1)
+	if !h.add(client) {
+		t.Fatal("the hub refused the client")
+	}
+	waitDisconnected(t, h, client)
+}
+
+func TestHubResetStopsAtDisconnectedClient(t *testing.T) {
+	h := runHub(t)
+	// the keyframe of a new client is dropped, then the init frame of the reset disconnects it
+	// and the keyframe after it must not be sent
+	client := stuckClient(true, 2)
+	if !h.add(client) {
+		t.Fatal("the hub refused the client")
+	}
+	h.Reset(GraphData{}, nil)
+	waitDisconnected(t, h, client)
+}
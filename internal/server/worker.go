@@ -2,32 +2,61 @@ package server
 
 import (
 	"log"
-	"waze/internal/graph"
+	"time"
 	"waze/internal/navigation"
 )
 
-var JobQueue chan PathRequest
+// navigation requests waiting for a worker, more are answered with overloaded
+const JOB_QUEUE_SIZE = 100
 
-func WakeWorkers(numWorkers int, g *graph.Graph) {
-	JobQueue = make(chan PathRequest, 100)
+// starts the routing workers, they stop with the server
+func (s *Server) startWorkers(numWorkers int) {
 	for i := 0; i < numWorkers; i++ {
-		go worker(g)
+		s.loops.Add(1)
+		go func() {
+			defer s.loops.Done()
+			s.worker()
+		}()
 	}
-	log.Printf("JobQueue started with %d workers", numWorkers)
+	log.Printf("Started %d routing workers", numWorkers)
 }
 
-func worker(g *graph.Graph) {
-	for req := range JobQueue {
-		pathRes, err := navigation.FindPathAstar(g, req.StartNodeId, req.EndNodeId)
+func (s *Server) worker() {
+	for {
+		select {
+		case req := <-s.Jobs:
+			s.route(req)
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *Server) route(req PathRequest) {
+	// nobody waits for requests that were canceled while in the queue
+	if err := req.Ctx.Err(); err != nil {
+		req.ResponseChannel <- PathResult{Err: err}
+		return
+	}
 
-		result := PathResult{}
-		if err != nil {
-			result.Err = err
-		} else {
-			result.Response.RouteNodes = pathRes.Route
-			result.Response.ETA = pathRes.ETA
-			result.Response.Distance = pathRes.Distance
+	if req.Search != nil {
+		stats, route, err := searchStats(req.Ctx, req.Graph, req.Search)
+		req.ResponseChannel <- PathResult{Routes: []*navigation.PathResult{route}, Stats: stats, Err: err}
+		return
+	}
+	start := time.Now()
+	if req.Targets != nil {
+		routes, err := navigation.FindPathsToMany(req.Ctx, req.Graph, req.StartNodeId, req.Targets, req.Profile)
+		req.ResponseChannel <- PathResult{Routes: routes, Err: err}
+		return
+	}
+	routes, err := navigation.FindAlternatives(req.Ctx, req.Graph, req.StartNodeId, req.EndNodeId, req.Profile, req.Alternatives)
+	// a canceled search says nothing about the routing
+	if req.Ctx.Err() == nil {
+		s.m.routingDuration.Observe(time.Since(start).Seconds())
+		if err == nil {
+			s.m.nodesExpanded.Observe(float64(routes[0].Expanded))
 		}
-		req.ResponseChannel <- result
 	}
-}
\ No newline at end of file
+	req.ResponseChannel <- PathResult{Routes: routes, Err: err}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"waze/internal/config"
 	"waze/internal/graph"
+	"waze/internal/types"
 )
 
 type CarState int
@@ -33,6 +34,17 @@ type Car struct {
 
 	LastRouteReq float64    // time of the last route request
 	NewRouteChan chan []int // channel for a new route
+
+	IsProbe    bool             // reports noisy GPS fixes instead of edge reports
+	ProbeTrace []types.GPSPoint // fixes not sent yet
+}
+
+// Progress returns how much of the current edge was driven, 0-1
+func (route *TravelRoute) Progress() float64 {
+	if route.CurrentEdgeLen <= 0 {
+		return 0
+	}
+	return min(route.EdgeProgress/route.CurrentEdgeLen, 1)
 }
 
 func NewCar(id, userId int, currentTime float64) *Car {
@@ -43,6 +55,7 @@ func NewCar(id, userId int, currentTime float64) *Car {
 		CurrentSpeed: 0,
 		LastRouteReq: currentTime,
 		NewRouteChan: make(chan []int, 1),
+		IsProbe:      isProbe(),
 	}
 }
 
@@ -112,13 +125,9 @@ func (car *Car) calculatePhysics(g *graph.Graph, densityMap map[int]int) {
 	}
 
 	lengthKm := edge.Length
-	carLen := config.Global.Physics.CarLengthKm
 
-	// calculate capacity of cars on the edge/road
-	carCapacity := lengthKm / carLen
-	if carCapacity < 1 {
-		carCapacity = 1
-	}
+	// calculate capacity of cars on the edge/road (by its length and lanes)
+	carCapacity := edge.CarCapacity(config.Get().Physics.CarLengthKm)
 
 	// calculate the current edgeDensity on the edge
 	edgeDensity := float64(densityMap[currentEdgeId]) / carCapacity
@@ -131,8 +140,8 @@ func (car *Car) calculatePhysics(g *graph.Graph, densityMap map[int]int) {
 
 	// check the progress on the current edge - (more progress means lower speed because of bottleneck)
 	progressPrecent := car.ActiveRoute.EdgeProgress / lengthKm
-	if progressPrecent > config.Global.Physics.DensityThreshold && edgeDensity > config.Global.Physics.EdgeDensityThreshold {
-		speedFactor *= config.Global.Physics.SpeedFactor
+	if progressPrecent > config.Get().Physics.DensityThreshold && edgeDensity > config.Get().Physics.EdgeDensityThreshold {
+		speedFactor *= config.Get().Physics.SpeedFactor
 	}
 
 	// update the current speed to be the speed limit in the edge multipkied by speed factor
@@ -12,7 +12,13 @@ import (
 
 type Client struct {
 	BaseURL string
+	APIKey  string // sent as a bearer token when set
 	Http    *http.Client
+
+	// the reports and the routes go over gRPC when set, see UseGRPC
+	grpc *grpcClient
+	// the reports go on a WebSocket when set, see UseReportStream
+	stream *reportStream
 }
 
 func NewClient(url string) *Client {
@@ -24,10 +30,31 @@ func NewClient(url string) *Client {
 	}
 }
 
+// sends a request with the API key of the client
+func (c *Client) do(method, url string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+	return c.Http.Do(req)
+}
+
 // send all traffic report from al cars to server
 func (c *Client) SendTrafficBatch(reports []types.TrafficReport) error {
+	if c.grpc != nil {
+		return c.sendTrafficGRPC(reports)
+	}
+	if c.stream != nil {
+		return c.sendTrafficStream(reports)
+	}
 	jsonData, _ := json.Marshal(reports)
-	resp, err := c.Http.Post(c.BaseURL+"/api/traffic", "application/json", bytes.NewBuffer(jsonData))
+	resp, err := c.do(http.MethodPost, c.BaseURL+"/api/v1/traffic", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return err
 	}
@@ -42,15 +69,47 @@ func (c *Client) SendTrafficBatch(reports []types.TrafficReport) error {
 	return nil
 }
 
+// Close ends the report stream and the gRPC connection of the client
+func (c *Client) Close() error {
+	var err error
+	if c.stream != nil {
+		err = c.stream.close()
+	}
+	if c.grpc != nil {
+		if grpcErr := c.grpc.close(); err == nil {
+			err = grpcErr
+		}
+	}
+	return err
+}
+
+// send the raw GPS traces of the probe cars to server
+func (c *Client) SendGPSTraces(traces []types.GPSTrace) error {
+	jsonData, _ := json.Marshal(traces)
+	resp, err := c.do(http.MethodPost, c.BaseURL+"/api/v1/traffic/gps", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("Server returned status:  %d", resp.StatusCode)
+	}
+	return nil
+}
+
 // Request and return route from server
 func (c *Client) RequestRoute(startNode, endNode int) ([]int, error) {
+	if c.grpc != nil {
+		return c.requestRouteGRPC(startNode, endNode)
+	}
 
-	url := fmt.Sprintf("%s/api/navigate?from=%d&to=%d", c.BaseURL, startNode, endNode)
+	url := fmt.Sprintf("%s/api/v1/navigate?from=%d&to=%d", c.BaseURL, startNode, endNode)
 	// fmt.Println(url)
 	// time.Sleep(time.Second * 5)
 
 	// send route request
-	resp, err := c.Http.Get(url)
+	resp, err := c.do(http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
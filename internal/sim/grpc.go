@@ -0,0 +1,115 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"waze/internal/types"
+	"waze/internal/wazepb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+// the gRPC connection of a client. the reports of all the batches go on one stream, it is opened again after an error
+type grpcClient struct {
+	conn *grpc.ClientConn
+	api  wazepb.WazeClient
+
+	mu      sync.Mutex
+	reports wazepb.Waze_ReportTrafficClient
+	cancel  context.CancelFunc // ends the report stream
+}
+
+// UseGRPC makes the client send the traffic reports and ask for the routes over the gRPC API at addr (host:port).
+// the GPS traces still go over HTTP
+func (c *Client) UseGRPC(addr string) error {
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return err
+	}
+	c.grpc = &grpcClient{conn: conn, api: wazepb.NewWazeClient(conn)}
+	return nil
+}
+
+// the context of a call, with the API key of the client
+func (c *Client) grpcContext(ctx context.Context) context.Context {
+	if c.APIKey == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+c.APIKey)
+}
+
+func (c *Client) sendTrafficGRPC(reports []types.TrafficReport) error {
+	batch := &wazepb.ReportBatch{Reports: make([]*wazepb.TrafficReport, len(reports))}
+	for i, report := range reports {
+		batch.Reports[i] = &wazepb.TrafficReport{
+			CarId:     int32(report.CarID),
+			EdgeId:    int32(report.EdgeID),
+			Speed:     report.Speed,
+			Progress:  report.Progress,
+			Heading:   report.Heading,
+			Timestamp: report.Timestamp,
+		}
+	}
+
+	g := c.grpc
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.reports == nil {
+		ctx, cancel := context.WithCancel(c.grpcContext(context.Background()))
+		stream, err := g.api.ReportTraffic(ctx)
+		if err != nil {
+			cancel()
+			return err
+		}
+		g.reports, g.cancel = stream, cancel
+	}
+	if err := g.reports.Send(batch); err != nil {
+		// the server ended the stream, its status tells why
+		_, err = g.reports.CloseAndRecv()
+		g.cancel()
+		g.reports = nil
+		return fmt.Errorf("report stream closed: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) requestRouteGRPC(startNode, endNode int) ([]int, error) {
+	ctx, cancel := context.WithTimeout(c.grpcContext(context.Background()), 5*time.Second)
+	defer cancel()
+
+	geometry := false
+	response, err := c.grpc.api.Route(ctx, &wazepb.RouteRequest{
+		From:     &wazepb.Waypoint{Point: &wazepb.Waypoint_NodeId{NodeId: int32(startNode)}},
+		To:       &wazepb.Waypoint{Point: &wazepb.Waypoint_NodeId{NodeId: int32(endNode)}},
+		Geometry: &geometry,
+	})
+	if err != nil {
+		return nil, err
+	}
+	route := make([]int, len(response.Route))
+	for i, id := range response.Route {
+		route[i] = int(id)
+	}
+	return route, nil
+}
+
+func (g *grpcClient) close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var err error
+	if g.reports != nil {
+		_, err = g.reports.CloseAndRecv()
+		g.cancel()
+		g.reports = nil
+	}
+	if closeErr := g.conn.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,63 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+	"time"
+	"waze/internal/graph"
+	"waze/internal/types"
+)
+
+// the fake car of the jam, and how often it reports
+const (
+	JAM_CAR_ID   = 99999
+	JAM_SPEED    = 1.0 // KM/hour
+	JAM_INTERVAL = 2 * time.Second
+)
+
+// NearestEdge returns the edge closest to the point (x, y) and how far along it the closest point is, 0-1.
+// nil when the graph has no edges
+func (w *World) NearestEdge(x, y float64) (*graph.Edge, float64) {
+	var nearest *graph.Edge
+	bestDist, progress := math.Inf(1), 0.0
+	for _, edge := range w.Graph.Edges {
+		dist, along, total := w.Projection.ClosestOnPolyline(w.Graph.EdgePoints(edge), x, y)
+		// the lower id wins a tie, the two directions of a road are equally close
+		if nearest == nil || dist < bestDist || (dist == bestDist && edge.Id < nearest.Id) {
+			nearest, bestDist = edge, dist
+			progress = 0
+			if total > 0 {
+				progress = along / total
+			}
+		}
+	}
+	return nearest, progress
+}
+
+// Jam makes a fake car crawl on the edge nearest to (x, y), so the routes around it can be seen changing.
+// it reports until the process ends. the ids of the edges change when the map is generated again, the location doesn't
+func (w *World) Jam(x, y float64) {
+	edge, progress := w.NearestEdge(x, y)
+	if edge == nil {
+		return
+	}
+	_, _, heading := w.Graph.PositionOnEdge(edge, progress)
+	fmt.Printf("Jam on edge %d %q\n", edge.Id, edge.Name)
+
+	for {
+		jamReport := []types.TrafficReport{
+			{
+				CarID:     JAM_CAR_ID,
+				EdgeID:    edge.Id,
+				Speed:     JAM_SPEED,
+				Progress:  progress,
+				Heading:   heading,
+				Timestamp: time.Now().Unix(),
+			},
+		}
+		if err := w.Client.SendTrafficBatch(jamReport); err != nil {
+			fmt.Printf("Error in jam report %s\n", err)
+		}
+		time.Sleep(JAM_INTERVAL)
+	}
+}
@@ -0,0 +1,26 @@
+package sim
+
+import "waze/internal/metrics"
+
+// the metrics the simulation updates
+type simMetrics struct {
+	tickDuration  *metrics.Histogram
+	activeCars    *metrics.Gauge
+	spawnFailures *metrics.Counter
+}
+
+// registers the metrics of the simulation in r
+func newSimMetrics(r *metrics.Registry) *simMetrics {
+	return &simMetrics{
+		tickDuration: r.NewHistogram("waze_sim_tick_duration_seconds",
+			"Wall time of a simulation tick", metrics.DefaultBuckets),
+		activeCars: r.NewGauge("waze_sim_active_cars", "Cars driving in the simulation"),
+		spawnFailures: r.NewCounter("waze_sim_spawn_failures_total",
+			"Cars that were not spawned because no route was found"),
+	}
+}
+
+// SpawnFailed counts a car that was not spawned because no route was found
+func (world *World) SpawnFailed() {
+	world.m.spawnFailures.Inc()
+}
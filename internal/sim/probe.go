@@ -0,0 +1,72 @@
+package sim
+
+import (
+	"math/rand"
+	"waze/internal/config"
+	"waze/internal/types"
+)
+
+// decide if a new car is a GPS probe, by the penetration rate
+func isProbe() bool {
+	return rand.Float64() < config.Get().Simulation.Probes.PenetrationRate
+}
+
+// take a noisy position fix of every driving probe car, once every sample interval
+func (world *World) sampleProbes() {
+	probes := config.Get().Simulation.Probes
+	if world.SimTime-world.lastProbeSample < probes.SampleInterval {
+		return
+	}
+	world.lastProbeSample = world.SimTime
+
+	for _, car := range world.Cars {
+		if !car.IsProbe || car.State != Driving || car.ActiveRoute == nil {
+			continue
+		}
+		// the fix got lost
+		if rand.Float64() < probes.DropoutRate {
+			continue
+		}
+		car.ProbeTrace = append(car.ProbeTrace, world.probeFix(car))
+	}
+}
+
+// the position of the car along its current edge, with gaussian noise on the position and the speed
+func (world *World) probeFix(car *Car) types.GPSPoint {
+	probes := config.Get().Simulation.Probes
+	route := car.ActiveRoute
+	edge := world.Graph.Edges[route.RouteEdges[route.CurrentEdgeIndex]]
+
+	x, y, _ := world.Graph.PositionOnEdge(edge, route.Progress())
+
+	dx, dy := world.Projection.MetersToDegrees(rand.NormFloat64()*probes.PositionNoise, rand.NormFloat64()*probes.PositionNoise)
+
+	speed := car.CurrentSpeed + rand.NormFloat64()*probes.SpeedNoise
+	if speed < 0 {
+		speed = 0
+	}
+
+	return types.GPSPoint{
+		X:         x + dx,
+		Y:         y + dy,
+		Timestamp: world.GetCurrentTime(),
+		Speed:     speed,
+	}
+}
+
+// GenerateProbeTraces returns the fixes collected since the last call, a trace per car.
+// the last fix of every car stays as the start of its next trace, so no part of the drive is lost
+func (world *World) GenerateProbeTraces() []types.GPSTrace {
+	traces := make([]types.GPSTrace, 0)
+	for _, car := range world.Cars {
+		if len(car.ProbeTrace) < 2 {
+			continue
+		}
+		points := make([]types.GPSPoint, len(car.ProbeTrace))
+		copy(points, car.ProbeTrace)
+		traces = append(traces, types.GPSTrace{CarID: car.Id, Points: points})
+
+		car.ProbeTrace = car.ProbeTrace[len(car.ProbeTrace)-1:]
+	}
+	return traces
+}
@@ -0,0 +1,132 @@
+package sim
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+	"waze/internal/types"
+
+	"github.com/gorilla/websocket"
+)
+
+// how long a batch waits for its ack before it counts as lost
+const ACK_TIMEOUT = 5 * time.Second
+
+// the report stream of a client. it is opened on the first batch, and again on the next batch after it broke
+type reportStream struct {
+	mu      sync.Mutex
+	conn    *websocket.Conn
+	seq     uint64
+	pending map[uint64]chan error // the batches waiting for their ack, by sequence
+}
+
+// UseReportStream makes the client send the traffic reports on one WebSocket (/api/v1/traffic/stream)
+// instead of a POST per batch. a batch returns once the server acknowledged it
+func (c *Client) UseReportStream() {
+	c.stream = &reportStream{pending: make(map[uint64]chan error)}
+}
+
+func (c *Client) sendTrafficStream(reports []types.TrafficReport) error {
+	st := c.stream
+	st.mu.Lock()
+	if st.conn == nil {
+		if err := st.open(c); err != nil {
+			st.mu.Unlock()
+			return err
+		}
+	}
+	st.seq++
+	seq := st.seq
+	acked := make(chan error, 1)
+	st.pending[seq] = acked
+
+	st.conn.SetWriteDeadline(time.Now().Add(ACK_TIMEOUT))
+	err := st.conn.WriteJSON(types.ReportMessage{Seq: seq, Reports: reports})
+	if err != nil {
+		// the reader fails the pending batches
+		st.conn.Close()
+	}
+	st.mu.Unlock()
+
+	select {
+	case err := <-acked:
+		return err
+	case <-time.After(ACK_TIMEOUT):
+		st.mu.Lock()
+		delete(st.pending, seq)
+		st.mu.Unlock()
+		return fmt.Errorf("no ack for batch %d", seq)
+	}
+}
+
+// dials the stream, st.mu must be held
+func (st *reportStream) open(c *Client) error {
+	u, err := url.Parse(c.BaseURL + "/api/v1/traffic/stream")
+	if err != nil {
+		return err
+	}
+	u.Scheme = map[string]string{"http": "ws", "https": "wss"}[u.Scheme]
+	header := http.Header{}
+	if c.APIKey != "" {
+		header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+	conn, resp, err := websocket.DefaultDialer.Dial(u.String(), header)
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("report stream refused, status: %d", resp.StatusCode)
+		}
+		return err
+	}
+	st.conn = conn
+	go st.readAcks(conn)
+	return nil
+}
+
+// passes the acks to the batches that wait for them, until the connection breaks
+func (st *reportStream) readAcks(conn *websocket.Conn) {
+	for {
+		var ack types.ReportAck
+		if err := conn.ReadJSON(&ack); err != nil {
+			st.mu.Lock()
+			if st.conn == conn {
+				st.conn = nil
+			}
+			for seq, acked := range st.pending {
+				acked <- fmt.Errorf("report stream closed: %w", err)
+				delete(st.pending, seq)
+			}
+			st.mu.Unlock()
+			conn.Close()
+			return
+		}
+
+		st.mu.Lock()
+		acked, exists := st.pending[ack.Seq]
+		delete(st.pending, ack.Seq)
+		st.mu.Unlock()
+		if !exists {
+			// the batch stopped waiting
+			continue
+		}
+		if ack.Type == "ack" {
+			acked <- nil
+		} else {
+			acked <- fmt.Errorf("batch %d not applied: %s: %s", ack.Seq, ack.Code, ack.Message)
+		}
+	}
+}
+
+func (st *reportStream) close() error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.conn == nil {
+		return nil
+	}
+	st.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	err := st.conn.Close()
+	st.conn = nil
+	return err
+}
@@ -7,11 +7,16 @@ import (
 	"time"
 	"waze/internal/config"
 	"waze/internal/graph"
+	"waze/internal/metrics"
+	"waze/internal/spatial"
 	"waze/internal/types"
 )
 
 const TIME_TO_REPORT = 2
 
+// the server matches at most this many traces of a request
+const PROBE_TRACES_PER_REQUEST = 1000
+
 type World struct {
 	Graph         *graph.Graph
 	Cars          []*Car
@@ -22,6 +27,13 @@ type World struct {
 	VirtualStartTime time.Time
 
 	EdgeDensity map[int]int
+
+	Projection      spatial.Projection // for the GPS noise in meters
+	lastProbeSample float64
+
+	// the metrics of this simulation, served on the metrics port
+	Metrics *metrics.Registry
+	m       *simMetrics
 }
 
 func NewWorld(mapFile, serverUrl string) (*World, error) {
@@ -29,12 +41,16 @@ func NewWorld(mapFile, serverUrl string) (*World, error) {
 	if err != nil {
 		log.Fatal(err)
 	}
+	registry := metrics.NewRegistry()
 	return &World{
 		Graph:            g,
 		Cars:             make([]*Car, 0),
 		SimTime:          0,
 		VirtualStartTime: time.Now(),
 		Client:           NewClient(serverUrl),
+		Projection:       spatial.ProjectionFor(g),
+		Metrics:          registry,
+		m:                newSimMetrics(registry),
 	}, nil
 }
 
@@ -97,14 +113,7 @@ func (world *World) GenarateTrafficReportsParallel() []types.TrafficReport {
 				car := world.Cars[j]
 
 				if car.State == Driving && car.ActiveRoute != nil {
-					currentEdge := car.ActiveRoute.RouteEdges[car.ActiveRoute.CurrentEdgeIndex]
-
-					world.ReportsBuffer[j] = types.TrafficReport{
-						CarID:     car.Id,
-						EdgeID:    currentEdge,
-						Speed:     car.CurrentSpeed,
-						Timestamp: world.GetCurrentTime(),
-					}
+					world.ReportsBuffer[j] = world.carReport(car)
 				} else {
 					world.ReportsBuffer[j].CarID = -1
 				}
@@ -130,14 +139,7 @@ func (world *World) GenarateTrafficReports() []types.TrafficReport {
 	for i := range carsCount {
 		car := world.Cars[i]
 		if car.State == Driving && car.ActiveRoute != nil {
-			currentEdge := car.ActiveRoute.RouteEdges[car.ActiveRoute.CurrentEdgeIndex]
-
-			world.ReportsBuffer[i] = types.TrafficReport{
-				CarID:     car.Id,
-				EdgeID:    currentEdge,
-				Speed:     car.CurrentSpeed,
-				Timestamp: world.GetCurrentTime(),
-			}
+			world.ReportsBuffer[i] = world.carReport(car)
 		} else {
 			world.ReportsBuffer[i].CarID = -1
 		}
@@ -145,13 +147,46 @@ func (world *World) GenarateTrafficReports() []types.TrafficReport {
 	return world.ReportsBuffer
 }
 
+// the report of a driving car: its edge, speed, and where it is on the edge
+func (world *World) carReport(car *Car) types.TrafficReport {
+	currentEdge := car.ActiveRoute.RouteEdges[car.ActiveRoute.CurrentEdgeIndex]
+	progress := car.ActiveRoute.Progress()
+
+	heading := 0.0
+	if edge, exists := world.Graph.Edges[currentEdge]; exists {
+		_, _, heading = world.Graph.PositionOnEdge(edge, progress)
+	}
+
+	return types.TrafficReport{
+		CarID:     car.Id,
+		EdgeID:    currentEdge,
+		Speed:     car.CurrentSpeed,
+		Progress:  progress,
+		Heading:   heading,
+		Timestamp: world.GetCurrentTime(),
+	}
+}
+
 func (world *World) Tick(dt float64) {
+	start := time.Now()
+	defer func() { world.m.tickDuration.Observe(time.Since(start).Seconds()) }()
+
 	world.SimTime += dt
 
 	world.EdgeDensity = world.calculateDensityParallel()
 	MoveCarsParallel(world.Cars, dt, world.Graph, world.EdgeDensity)
+	world.m.activeCars.Set(float64(len(world.Cars)))
 
-	if int(world.SimTime)%int(config.Global.Simulation.ReportInterval) == 0 {
+	probesEnabled := config.Get().Simulation.Probes.Enabled
+	if probesEnabled {
+		world.sampleProbes()
+	}
+
+	if int(world.SimTime)%int(config.Get().Simulation.ReportInterval) == 0 {
+		if probesEnabled {
+			world.sendProbeTraces()
+			return
+		}
 		reports := world.GenarateTrafficReports()
 		reportsCopy := make([]types.TrafficReport, len(reports))
 		copy(reportsCopy, reports)
@@ -164,6 +199,18 @@ func (world *World) Tick(dt float64) {
 	}
 }
 
+func (world *World) sendProbeTraces() {
+	traces := world.GenerateProbeTraces()
+	for start := 0; start < len(traces); start += PROBE_TRACES_PER_REQUEST {
+		go func(batch []types.GPSTrace) {
+			err := world.Client.SendGPSTraces(batch)
+			if err != nil {
+				fmt.Println("Failed to send GPS traces: ", err)
+			}
+		}(traces[start:min(start+PROBE_TRACES_PER_REQUEST, len(traces))])
+	}
+}
+
 func different(newRoute, currentRoute []int, currentIndex int) bool {
 	if len(newRoute) != len(currentRoute)-currentIndex {
 		return true
@@ -0,0 +1,47 @@
+package spatial
+
+import (
+	"math"
+	"waze/internal/graph"
+)
+
+// the average size of a grid cell in edges, so a cell holds a few edges
+const EDGES_PER_CELL = 2.0
+
+// EdgeIndex finds the edges of a graph by location
+type EdgeIndex struct {
+	Graph *graph.Graph
+	grid  *Grid
+}
+
+func NewEdgeIndex(g *graph.Graph) *EdgeIndex {
+	boxes := make(map[int]Box, len(g.Edges))
+	totalSize := 0.0
+	for id, edge := range g.Edges {
+		box := BoxOf(g.EdgePoints(edge))
+		boxes[id] = box
+		totalSize += math.Max(box.MaxX-box.MinX, box.MaxY-box.MinY)
+	}
+
+	// the cell size follows the average edge size of the map
+	cellSize := 1.0
+	if len(boxes) > 0 && totalSize > 0 {
+		cellSize = EDGES_PER_CELL * totalSize / float64(len(boxes))
+	}
+
+	grid := NewGrid(cellSize)
+	for id, box := range boxes {
+		grid.Insert(id, box)
+	}
+	return &EdgeIndex{Graph: g, grid: grid}
+}
+
+// InBox returns the edges whose bounding boxes intersect the box
+func (idx *EdgeIndex) InBox(box Box) []*graph.Edge {
+	ids := idx.grid.Query(box)
+	edges := make([]*graph.Edge, 0, len(ids))
+	for _, id := range ids {
+		edges = append(edges, idx.Graph.Edges[id])
+	}
+	return edges
+}
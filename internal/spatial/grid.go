@@ -0,0 +1,91 @@
+// Package spatial has a simple uniform grid index for looking up map objects by location
+package spatial
+
+import "math"
+
+// Box is an axis aligned rectangle in map coordinates
+type Box struct {
+	MinX float64 `json:"min_x"`
+	MinY float64 `json:"min_y"`
+	MaxX float64 `json:"max_x"`
+	MaxY float64 `json:"max_y"`
+}
+
+// BoxAround returns the square box with the given center and half size
+func BoxAround(x, y, radius float64) Box {
+	return Box{MinX: x - radius, MinY: y - radius, MaxX: x + radius, MaxY: y + radius}
+}
+
+// BoxOf returns the smallest box that holds all the points
+func BoxOf(points [][2]float64) Box {
+	box := Box{MinX: math.Inf(1), MinY: math.Inf(1), MaxX: math.Inf(-1), MaxY: math.Inf(-1)}
+	for _, p := range points {
+		box.MinX = math.Min(box.MinX, p[0])
+		box.MinY = math.Min(box.MinY, p[1])
+		box.MaxX = math.Max(box.MaxX, p[0])
+		box.MaxY = math.Max(box.MaxY, p[1])
+	}
+	return box
+}
+
+func (b Box) Contains(x, y float64) bool {
+	return x >= b.MinX && x <= b.MaxX && y >= b.MinY && y <= b.MaxY
+}
+
+func (b Box) Intersects(other Box) bool {
+	return b.MinX <= other.MaxX && other.MinX <= b.MaxX && b.MinY <= other.MaxY && other.MinY <= b.MaxY
+}
+
+// Grid indexes ids by their bounding boxes. every id is kept in all the cells its box touches
+type Grid struct {
+	cellSize float64
+	cells    map[[2]int][]int
+	boxes    map[int]Box
+}
+
+func NewGrid(cellSize float64) *Grid {
+	return &Grid{
+		cellSize: cellSize,
+		cells:    make(map[[2]int][]int),
+		boxes:    make(map[int]Box),
+	}
+}
+
+func (g *Grid) cellOf(x, y float64) [2]int {
+	return [2]int{int(math.Floor(x / g.cellSize)), int(math.Floor(y / g.cellSize))}
+}
+
+func (g *Grid) Insert(id int, box Box) {
+	g.boxes[id] = box
+	from := g.cellOf(box.MinX, box.MinY)
+	to := g.cellOf(box.MaxX, box.MaxY)
+	for cx := from[0]; cx <= to[0]; cx++ {
+		for cy := from[1]; cy <= to[1]; cy++ {
+			cell := [2]int{cx, cy}
+			g.cells[cell] = append(g.cells[cell], id)
+		}
+	}
+}
+
+// Query returns the ids whose boxes intersect the given box, each id once
+func (g *Grid) Query(box Box) []int {
+	result := make([]int, 0)
+	seen := make(map[int]bool)
+
+	from := g.cellOf(box.MinX, box.MinY)
+	to := g.cellOf(box.MaxX, box.MaxY)
+	for cx := from[0]; cx <= to[0]; cx++ {
+		for cy := from[1]; cy <= to[1]; cy++ {
+			for _, id := range g.cells[[2]int{cx, cy}] {
+				if seen[id] {
+					continue
+				}
+				seen[id] = true
+				if g.boxes[id].Intersects(box) {
+					result = append(result, id)
+				}
+			}
+		}
+	}
+	return result
+}
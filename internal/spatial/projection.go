@@ -0,0 +1,84 @@
+package spatial
+
+import (
+	"math"
+	"waze/internal/graph"
+)
+
+// KM per degree of latitude, and of longitude at the equator
+const (
+	KM_PER_DEG_LAT = 110.574
+	KM_PER_DEG_LON = 111.320
+)
+
+// Projection turns lon/lat degrees into flat KM coordinates around a reference latitude.
+// good enough for city sized maps
+type Projection struct {
+	KmPerX float64
+	KmPerY float64
+}
+
+func NewProjection(refLat float64) Projection {
+	return Projection{
+		KmPerX: KM_PER_DEG_LON * math.Cos(refLat*math.Pi/180),
+		KmPerY: KM_PER_DEG_LAT,
+	}
+}
+
+// ProjectionFor returns the projection around the middle latitude of the map
+func ProjectionFor(g *graph.Graph) Projection {
+	sumY := 0.0
+	for _, node := range g.Nodes {
+		sumY += node.Y
+	}
+	refLat := 0.0
+	if len(g.Nodes) > 0 {
+		refLat = sumY / float64(len(g.Nodes))
+	}
+	return NewProjection(refLat)
+}
+
+// MetersToDegrees returns the offsets in degrees of a move of dx, dy meters
+func (p Projection) MetersToDegrees(dx, dy float64) (float64, float64) {
+	return dx / 1000 / p.KmPerX, dy / 1000 / p.KmPerY
+}
+
+func (p Projection) ToKm(x, y float64) (float64, float64) {
+	return x * p.KmPerX, y * p.KmPerY
+}
+
+// DegreesFor returns a distance in degrees that covers at least km in every direction
+func (p Projection) DegreesFor(km float64) float64 {
+	return km / math.Min(p.KmPerX, p.KmPerY)
+}
+
+func (p Projection) Distance(x1, y1, x2, y2 float64) float64 {
+	return math.Hypot((x2-x1)*p.KmPerX, (y2-y1)*p.KmPerY)
+}
+
+// ClosestOnPolyline finds the point of the polyline closest to (x, y).
+// returns the distance to it and how far along the polyline it is, both in KM
+func (p Projection) ClosestOnPolyline(points [][2]float64, x, y float64) (dist, along, total float64) {
+	px, py := p.ToKm(x, y)
+	dist = math.Inf(1)
+
+	for i := 0; i < len(points)-1; i++ {
+		ax, ay := p.ToKm(points[i][0], points[i][1])
+		bx, by := p.ToKm(points[i+1][0], points[i+1][1])
+		dx, dy := bx-ax, by-ay
+		segLen := math.Hypot(dx, dy)
+
+		// the projection of the point on the segment, clamped to its ends
+		t := 0.0
+		if segLen > 0 {
+			t = math.Max(0, math.Min(1, ((px-ax)*dx+(py-ay)*dy)/(segLen*segLen)))
+		}
+		d := math.Hypot(ax+dx*t-px, ay+dy*t-py)
+		if d < dist {
+			dist = d
+			along = total + segLen*t
+		}
+		total += segLen
+	}
+	return dist, along, total
+}
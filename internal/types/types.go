@@ -1,18 +1,59 @@
 package types
 
+import "time"
+
 // format of sending a traffic report
 type TrafficReport struct {
-	CarID  int     `json:"car_id"`
-	EdgeID int     `json:"edge_id"`
-	Speed  float64 `json:"speed"`
-	// Position  float64
-	Timestamp int64 `json:"timestamp"`
+	CarID     int     `json:"car_id"`
+	EdgeID    int     `json:"edge_id"`
+	Speed     float64 `json:"speed"`
+	Progress  float64 `json:"progress"` // 0-1 along the edge
+	Heading   float64 `json:"heading"`  // degrees clockwise from north
+	Timestamp int64   `json:"timestamp"`
+
+	// the name of the API key that sent the report, set by the server
+	Reporter string `json:"reporter,omitempty"`
+}
+
+// a raw position fix of a probe, before it is matched to the map
+type GPSPoint struct {
+	X         float64 `json:"x"` // longitude
+	Y         float64 `json:"y"` // latitude
+	Timestamp int64   `json:"timestamp"`
+	Speed     float64 `json:"speed,omitempty"` // measured by the probe, KM/hour
+}
+
+// format of sending the raw positions of a single car
+type GPSTrace struct {
+	CarID  int        `json:"car_id"`
+	Points []GPSPoint `json:"points"`
 }
 
-// format of asking a navigation request
+// a point on the map
+type Location struct {
+	X float64 `json:"x"` // longitude
+	Y float64 `json:"y"` // latitude
+}
+
+// format of asking a navigation request. the ends are node ids, or locations that are snapped to the nearest node
 type NavigationRequest struct {
-	FromNodeId int `json:"from_node"`
-	ToNodeId   int `json:"toNode"`
+	FromNodeId *int      `json:"from_node,omitempty"` // nil when not given, 0 is a node id
+	ToNodeId   *int      `json:"to_node,omitempty"`
+	From       *Location `json:"from,omitempty"` // instead of from_node
+	To         *Location `json:"to,omitempty"`   // instead of to_node
+	Profile    string    `json:"profile,omitempty"`
+
+	// now when missing. the route uses the live speeds, the departure only sets the arrival time
+	DepartureTime *time.Time `json:"departure_time,omitempty"`
+
+	AvoidEdges       []int    `json:"avoid_edges,omitempty"`        // closed, never driven
+	AvoidRoadClasses []string `json:"avoid_road_classes,omitempty"` // driven only when there is no reasonable way around
+	Alternatives     int      `json:"alternatives,omitempty"`       // number of alternative routes to add
+
+	Geometry     *bool `json:"geometry,omitempty"` // true when missing
+	Instructions bool  `json:"instructions,omitempty"`
+
+	Timeout float64 `json:"timeout,omitempty"` // seconds to wait for the route, the server has its own limit too
 }
 
 // format of recieving a navigation request answer
@@ -20,5 +61,45 @@ type NavigationResponse struct {
 	RouteNodes []int   `json:"route"`
 	ETA        float64 `json:"eta"`
 	Distance   float64 `json:"distance"`
-	Err        error   `json:"error"`
+	Profile    string  `json:"profile"`
+	Geometry   string  `json:"geometry,omitempty"` // encoded polyline of the route
+
+	FromNodeId    int       `json:"from_node"`
+	ToNodeId      int       `json:"to_node"`
+	DepartureTime time.Time `json:"departure_time"`
+	ArrivalTime   time.Time `json:"arrival_time"`
+
+	Instructions []Instruction `json:"instructions,omitempty"`
+
+	// other routes, best first. they differ enough from this route and from each other
+	Alternatives []NavigationResponse `json:"alternatives,omitempty"`
+}
+
+// a single turn-by-turn step of a route
+type Instruction struct {
+	Type     string  `json:"type"`               // depart, turn, continue, roundabout, uturn, arrive
+	Modifier string  `json:"modifier,omitempty"` // left, right, slight left, sharp right, straight
+	Street   string  `json:"street,omitempty"`   // the street after the maneuver
+	Exit     int     `json:"exit,omitempty"`     // roundabout exit number
+	NodeId   int     `json:"node_id"`            // where the maneuver happens
+	Distance float64 `json:"distance"`           // KM from the previous instruction
+	Duration float64 `json:"duration"`           // minutes from the previous instruction
+	Text     string  `json:"text"`
+}
+
+// a message of the report stream (/api/v1/traffic/stream), the server acknowledges it by its sequence number
+type ReportMessage struct {
+	Seq     uint64          `json:"seq"`
+	Reports []TrafficReport `json:"reports"`
+}
+
+// the answer to a ReportMessage. an "ack" once its reports were applied, or an "error" when they were not
+// and the message may be sent again. a message without an answer may not have been applied
+type ReportAck struct {
+	Type       string `json:"type"` // ack or error
+	Seq        uint64 `json:"seq"`
+	Reports    int    `json:"reports,omitempty"`     // the reports of the message, the invalid ones were skipped
+	Code       string `json:"code,omitempty"`        // of an error, like the codes of the API errors
+	Message    string `json:"message,omitempty"`     // of an error
+	RetryAfter int    `json:"retry_after,omitempty"` // seconds, of a rate limited message
 }
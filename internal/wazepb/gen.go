@@ -0,0 +1,4 @@
+// Package wazepb is the generated code of the gRPC API, from waze.proto
+package wazepb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative waze.proto
@@ -0,0 +1,1570 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        (unknown)
+// source: waze.proto
+
+// the gRPC API of the server, the same routing and traffic as the HTTP API.
+// regenerate with go generate ./internal/wazepb after changing it
+
+package wazepb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// a point on the map
+type Location struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	X             float64                `protobuf:"fixed64,1,opt,name=x,proto3" json:"x,omitempty"` // longitude
+	Y             float64                `protobuf:"fixed64,2,opt,name=y,proto3" json:"y,omitempty"` // latitude
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Location) Reset() {
+	*x = Location{}
+	mi := &file_waze_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Location) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Location) ProtoMessage() {}
+
+func (x *Location) ProtoReflect() protoreflect.Message {
+	mi := &file_waze_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Location.ProtoReflect.Descriptor instead.
+func (*Location) Descriptor() ([]byte, []int) {
+	return file_waze_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Location) GetX() float64 {
+	if x != nil {
+		return x.X
+	}
+	return 0
+}
+
+func (x *Location) GetY() float64 {
+	if x != nil {
+		return x.Y
+	}
+	return 0
+}
+
+// an end of a route, a node or a location that is snapped to the nearest node
+type Waypoint struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Point:
+	//
+	//	*Waypoint_NodeId
+	//	*Waypoint_Location
+	Point         isWaypoint_Point `protobuf_oneof:"point"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Waypoint) Reset() {
+	*x = Waypoint{}
+	mi := &file_waze_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Waypoint) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Waypoint) ProtoMessage() {}
+
+func (x *Waypoint) ProtoReflect() protoreflect.Message {
+	mi := &file_waze_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Waypoint.ProtoReflect.Descriptor instead.
+func (*Waypoint) Descriptor() ([]byte, []int) {
+	return file_waze_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Waypoint) GetPoint() isWaypoint_Point {
+	if x != nil {
+		return x.Point
+	}
+	return nil
+}
+
+func (x *Waypoint) GetNodeId() int32 {
+	if x != nil {
+		if x, ok := x.Point.(*Waypoint_NodeId); ok {
+			return x.NodeId
+		}
+	}
+	return 0
+}
+
+func (x *Waypoint) GetLocation() *Location {
+	if x != nil {
+		if x, ok := x.Point.(*Waypoint_Location); ok {
+			return x.Location
+		}
+	}
+	return nil
+}
+
+type isWaypoint_Point interface {
+	isWaypoint_Point()
+}
+
+type Waypoint_NodeId struct {
+	NodeId int32 `protobuf:"varint,1,opt,name=node_id,json=nodeId,proto3,oneof"`
+}
+
+type Waypoint_Location struct {
+	Location *Location `protobuf:"bytes,2,opt,name=location,proto3,oneof"`
+}
+
+func (*Waypoint_NodeId) isWaypoint_Point() {}
+
+func (*Waypoint_Location) isWaypoint_Point() {}
+
+type RouteRequest struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	From    *Waypoint              `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
+	To      *Waypoint              `protobuf:"bytes,2,opt,name=to,proto3" json:"to,omitempty"`
+	Profile string                 `protobuf:"bytes,3,opt,name=profile,proto3" json:"profile,omitempty"` // fastest when empty
+	// now when missing. the route uses the live speeds, the departure only sets the arrival time
+	DepartureTime    *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=departure_time,json=departureTime,proto3" json:"departure_time,omitempty"`
+	AvoidEdges       []int32                `protobuf:"varint,5,rep,packed,name=avoid_edges,json=avoidEdges,proto3" json:"avoid_edges,omitempty"`             // closed, never driven
+	AvoidRoadClasses []string               `protobuf:"bytes,6,rep,name=avoid_road_classes,json=avoidRoadClasses,proto3" json:"avoid_road_classes,omitempty"` // driven only when there is no reasonable way around
+	Alternatives     int32                  `protobuf:"varint,7,opt,name=alternatives,proto3" json:"alternatives,omitempty"`                                  // number of alternative routes to add
+	Geometry         *bool                  `protobuf:"varint,8,opt,name=geometry,proto3,oneof" json:"geometry,omitempty"`                                    // true when missing
+	Instructions     bool                   `protobuf:"varint,9,opt,name=instructions,proto3" json:"instructions,omitempty"`
+	TimeoutSeconds   float64                `protobuf:"fixed64,10,opt,name=timeout_seconds,json=timeoutSeconds,proto3" json:"timeout_seconds,omitempty"` // the server has its own limit too
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *RouteRequest) Reset() {
+	*x = RouteRequest{}
+	mi := &file_waze_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RouteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RouteRequest) ProtoMessage() {}
+
+func (x *RouteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_waze_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RouteRequest.ProtoReflect.Descriptor instead.
+func (*RouteRequest) Descriptor() ([]byte, []int) {
+	return file_waze_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *RouteRequest) GetFrom() *Waypoint {
+	if x != nil {
+		return x.From
+	}
+	return nil
+}
+
+func (x *RouteRequest) GetTo() *Waypoint {
+	if x != nil {
+		return x.To
+	}
+	return nil
+}
+
+func (x *RouteRequest) GetProfile() string {
+	if x != nil {
+		return x.Profile
+	}
+	return ""
+}
+
+func (x *RouteRequest) GetDepartureTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.DepartureTime
+	}
+	return nil
+}
+
+func (x *RouteRequest) GetAvoidEdges() []int32 {
+	if x != nil {
+		return x.AvoidEdges
+	}
+	return nil
+}
+
+func (x *RouteRequest) GetAvoidRoadClasses() []string {
+	if x != nil {
+		return x.AvoidRoadClasses
+	}
+	return nil
+}
+
+func (x *RouteRequest) GetAlternatives() int32 {
+	if x != nil {
+		return x.Alternatives
+	}
+	return 0
+}
+
+func (x *RouteRequest) GetGeometry() bool {
+	if x != nil && x.Geometry != nil {
+		return *x.Geometry
+	}
+	return false
+}
+
+func (x *RouteRequest) GetInstructions() bool {
+	if x != nil {
+		return x.Instructions
+	}
+	return false
+}
+
+func (x *RouteRequest) GetTimeoutSeconds() float64 {
+	if x != nil {
+		return x.TimeoutSeconds
+	}
+	return 0
+}
+
+// a single turn-by-turn step of a route
+type Instruction struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`                    // depart, turn, continue, roundabout, uturn, arrive
+	Modifier      string                 `protobuf:"bytes,2,opt,name=modifier,proto3" json:"modifier,omitempty"`            // left, right, slight left, sharp right, straight
+	Street        string                 `protobuf:"bytes,3,opt,name=street,proto3" json:"street,omitempty"`                // the street after the maneuver
+	Exit          int32                  `protobuf:"varint,4,opt,name=exit,proto3" json:"exit,omitempty"`                   // roundabout exit number
+	NodeId        int32                  `protobuf:"varint,5,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"` // where the maneuver happens
+	Distance      float64                `protobuf:"fixed64,6,opt,name=distance,proto3" json:"distance,omitempty"`          // KM from the previous instruction
+	Duration      float64                `protobuf:"fixed64,7,opt,name=duration,proto3" json:"duration,omitempty"`          // minutes from the previous instruction
+	Text          string                 `protobuf:"bytes,8,opt,name=text,proto3" json:"text,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Instruction) Reset() {
+	*x = Instruction{}
+	mi := &file_waze_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Instruction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Instruction) ProtoMessage() {}
+
+func (x *Instruction) ProtoReflect() protoreflect.Message {
+	mi := &file_waze_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Instruction.ProtoReflect.Descriptor instead.
+func (*Instruction) Descriptor() ([]byte, []int) {
+	return file_waze_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Instruction) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Instruction) GetModifier() string {
+	if x != nil {
+		return x.Modifier
+	}
+	return ""
+}
+
+func (x *Instruction) GetStreet() string {
+	if x != nil {
+		return x.Street
+	}
+	return ""
+}
+
+func (x *Instruction) GetExit() int32 {
+	if x != nil {
+		return x.Exit
+	}
+	return 0
+}
+
+func (x *Instruction) GetNodeId() int32 {
+	if x != nil {
+		return x.NodeId
+	}
+	return 0
+}
+
+func (x *Instruction) GetDistance() float64 {
+	if x != nil {
+		return x.Distance
+	}
+	return 0
+}
+
+func (x *Instruction) GetDuration() float64 {
+	if x != nil {
+		return x.Duration
+	}
+	return 0
+}
+
+func (x *Instruction) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+type RouteResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Route         []int32                `protobuf:"varint,1,rep,packed,name=route,proto3" json:"route,omitempty"` // edge ids
+	Eta           float64                `protobuf:"fixed64,2,opt,name=eta,proto3" json:"eta,omitempty"`           // minutes
+	Distance      float64                `protobuf:"fixed64,3,opt,name=distance,proto3" json:"distance,omitempty"` // KM
+	Profile       string                 `protobuf:"bytes,4,opt,name=profile,proto3" json:"profile,omitempty"`
+	Geometry      string                 `protobuf:"bytes,5,opt,name=geometry,proto3" json:"geometry,omitempty"` // encoded polyline of the route
+	FromNode      int32                  `protobuf:"varint,6,opt,name=from_node,json=fromNode,proto3" json:"from_node,omitempty"`
+	ToNode        int32                  `protobuf:"varint,7,opt,name=to_node,json=toNode,proto3" json:"to_node,omitempty"`
+	DepartureTime *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=departure_time,json=departureTime,proto3" json:"departure_time,omitempty"`
+	ArrivalTime   *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=arrival_time,json=arrivalTime,proto3" json:"arrival_time,omitempty"`
+	Instructions  []*Instruction         `protobuf:"bytes,10,rep,name=instructions,proto3" json:"instructions,omitempty"`
+	// other routes, best first
+	Alternatives  []*RouteResponse `protobuf:"bytes,11,rep,name=alternatives,proto3" json:"alternatives,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RouteResponse) Reset() {
+	*x = RouteResponse{}
+	mi := &file_waze_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RouteResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RouteResponse) ProtoMessage() {}
+
+func (x *RouteResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_waze_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RouteResponse.ProtoReflect.Descriptor instead.
+func (*RouteResponse) Descriptor() ([]byte, []int) {
+	return file_waze_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *RouteResponse) GetRoute() []int32 {
+	if x != nil {
+		return x.Route
+	}
+	return nil
+}
+
+func (x *RouteResponse) GetEta() float64 {
+	if x != nil {
+		return x.Eta
+	}
+	return 0
+}
+
+func (x *RouteResponse) GetDistance() float64 {
+	if x != nil {
+		return x.Distance
+	}
+	return 0
+}
+
+func (x *RouteResponse) GetProfile() string {
+	if x != nil {
+		return x.Profile
+	}
+	return ""
+}
+
+func (x *RouteResponse) GetGeometry() string {
+	if x != nil {
+		return x.Geometry
+	}
+	return ""
+}
+
+func (x *RouteResponse) GetFromNode() int32 {
+	if x != nil {
+		return x.FromNode
+	}
+	return 0
+}
+
+func (x *RouteResponse) GetToNode() int32 {
+	if x != nil {
+		return x.ToNode
+	}
+	return 0
+}
+
+func (x *RouteResponse) GetDepartureTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.DepartureTime
+	}
+	return nil
+}
+
+func (x *RouteResponse) GetArrivalTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ArrivalTime
+	}
+	return nil
+}
+
+func (x *RouteResponse) GetInstructions() []*Instruction {
+	if x != nil {
+		return x.Instructions
+	}
+	return nil
+}
+
+func (x *RouteResponse) GetAlternatives() []*RouteResponse {
+	if x != nil {
+		return x.Alternatives
+	}
+	return nil
+}
+
+type MatrixRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sources       []*Waypoint            `protobuf:"bytes,1,rep,name=sources,proto3" json:"sources,omitempty"`
+	Destinations  []*Waypoint            `protobuf:"bytes,2,rep,name=destinations,proto3" json:"destinations,omitempty"`
+	Profile       string                 `protobuf:"bytes,3,opt,name=profile,proto3" json:"profile,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MatrixRequest) Reset() {
+	*x = MatrixRequest{}
+	mi := &file_waze_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MatrixRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MatrixRequest) ProtoMessage() {}
+
+func (x *MatrixRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_waze_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MatrixRequest.ProtoReflect.Descriptor instead.
+func (*MatrixRequest) Descriptor() ([]byte, []int) {
+	return file_waze_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *MatrixRequest) GetSources() []*Waypoint {
+	if x != nil {
+		return x.Sources
+	}
+	return nil
+}
+
+func (x *MatrixRequest) GetDestinations() []*Waypoint {
+	if x != nil {
+		return x.Destinations
+	}
+	return nil
+}
+
+func (x *MatrixRequest) GetProfile() string {
+	if x != nil {
+		return x.Profile
+	}
+	return ""
+}
+
+type MatrixCell struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Found         bool                   `protobuf:"varint,1,opt,name=found,proto3" json:"found,omitempty"`        // false when there is no route, the other fields are 0 then
+	Eta           float64                `protobuf:"fixed64,2,opt,name=eta,proto3" json:"eta,omitempty"`           // minutes
+	Distance      float64                `protobuf:"fixed64,3,opt,name=distance,proto3" json:"distance,omitempty"` // KM
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MatrixCell) Reset() {
+	*x = MatrixCell{}
+	mi := &file_waze_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MatrixCell) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MatrixCell) ProtoMessage() {}
+
+func (x *MatrixCell) ProtoReflect() protoreflect.Message {
+	mi := &file_waze_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MatrixCell.ProtoReflect.Descriptor instead.
+func (*MatrixCell) Descriptor() ([]byte, []int) {
+	return file_waze_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *MatrixCell) GetFound() bool {
+	if x != nil {
+		return x.Found
+	}
+	return false
+}
+
+func (x *MatrixCell) GetEta() float64 {
+	if x != nil {
+		return x.Eta
+	}
+	return 0
+}
+
+func (x *MatrixCell) GetDistance() float64 {
+	if x != nil {
+		return x.Distance
+	}
+	return 0
+}
+
+type MatrixRow struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Cells         []*MatrixCell          `protobuf:"bytes,1,rep,name=cells,proto3" json:"cells,omitempty"` // by destination
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MatrixRow) Reset() {
+	*x = MatrixRow{}
+	mi := &file_waze_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MatrixRow) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MatrixRow) ProtoMessage() {}
+
+func (x *MatrixRow) ProtoReflect() protoreflect.Message {
+	mi := &file_waze_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MatrixRow.ProtoReflect.Descriptor instead.
+func (*MatrixRow) Descriptor() ([]byte, []int) {
+	return file_waze_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *MatrixRow) GetCells() []*MatrixCell {
+	if x != nil {
+		return x.Cells
+	}
+	return nil
+}
+
+type MatrixResponse struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Rows             []*MatrixRow           `protobuf:"bytes,1,rep,name=rows,proto3" json:"rows,omitempty"`                                          // by source
+	SourceNodes      []int32                `protobuf:"varint,2,rep,packed,name=source_nodes,json=sourceNodes,proto3" json:"source_nodes,omitempty"` // the nodes the sources were snapped to
+	DestinationNodes []int32                `protobuf:"varint,3,rep,packed,name=destination_nodes,json=destinationNodes,proto3" json:"destination_nodes,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *MatrixResponse) Reset() {
+	*x = MatrixResponse{}
+	mi := &file_waze_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MatrixResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MatrixResponse) ProtoMessage() {}
+
+func (x *MatrixResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_waze_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MatrixResponse.ProtoReflect.Descriptor instead.
+func (*MatrixResponse) Descriptor() ([]byte, []int) {
+	return file_waze_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *MatrixResponse) GetRows() []*MatrixRow {
+	if x != nil {
+		return x.Rows
+	}
+	return nil
+}
+
+func (x *MatrixResponse) GetSourceNodes() []int32 {
+	if x != nil {
+		return x.SourceNodes
+	}
+	return nil
+}
+
+func (x *MatrixResponse) GetDestinationNodes() []int32 {
+	if x != nil {
+		return x.DestinationNodes
+	}
+	return nil
+}
+
+type TrafficReport struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	CarId         int32                  `protobuf:"varint,1,opt,name=car_id,json=carId,proto3" json:"car_id,omitempty"`
+	EdgeId        int32                  `protobuf:"varint,2,opt,name=edge_id,json=edgeId,proto3" json:"edge_id,omitempty"`
+	Speed         float64                `protobuf:"fixed64,3,opt,name=speed,proto3" json:"speed,omitempty"`       // KM/hour
+	Progress      float64                `protobuf:"fixed64,4,opt,name=progress,proto3" json:"progress,omitempty"` // 0-1 along the edge
+	Heading       float64                `protobuf:"fixed64,5,opt,name=heading,proto3" json:"heading,omitempty"`   // degrees clockwise from north
+	Timestamp     int64                  `protobuf:"varint,6,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TrafficReport) Reset() {
+	*x = TrafficReport{}
+	mi := &file_waze_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TrafficReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TrafficReport) ProtoMessage() {}
+
+func (x *TrafficReport) ProtoReflect() protoreflect.Message {
+	mi := &file_waze_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TrafficReport.ProtoReflect.Descriptor instead.
+func (*TrafficReport) Descriptor() ([]byte, []int) {
+	return file_waze_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *TrafficReport) GetCarId() int32 {
+	if x != nil {
+		return x.CarId
+	}
+	return 0
+}
+
+func (x *TrafficReport) GetEdgeId() int32 {
+	if x != nil {
+		return x.EdgeId
+	}
+	return 0
+}
+
+func (x *TrafficReport) GetSpeed() float64 {
+	if x != nil {
+		return x.Speed
+	}
+	return 0
+}
+
+func (x *TrafficReport) GetProgress() float64 {
+	if x != nil {
+		return x.Progress
+	}
+	return 0
+}
+
+func (x *TrafficReport) GetHeading() float64 {
+	if x != nil {
+		return x.Heading
+	}
+	return 0
+}
+
+func (x *TrafficReport) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+type ReportBatch struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Reports       []*TrafficReport       `protobuf:"bytes,1,rep,name=reports,proto3" json:"reports,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReportBatch) Reset() {
+	*x = ReportBatch{}
+	mi := &file_waze_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReportBatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReportBatch) ProtoMessage() {}
+
+func (x *ReportBatch) ProtoReflect() protoreflect.Message {
+	mi := &file_waze_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReportBatch.ProtoReflect.Descriptor instead.
+func (*ReportBatch) Descriptor() ([]byte, []int) {
+	return file_waze_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ReportBatch) GetReports() []*TrafficReport {
+	if x != nil {
+		return x.Reports
+	}
+	return nil
+}
+
+type ReportSummary struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Batches       int64                  `protobuf:"varint,1,opt,name=batches,proto3" json:"batches,omitempty"`
+	Received      int64                  `protobuf:"varint,2,opt,name=received,proto3" json:"received,omitempty"`
+	Applied       int64                  `protobuf:"varint,3,opt,name=applied,proto3" json:"applied,omitempty"` // reports on a known edge with a valid speed
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReportSummary) Reset() {
+	*x = ReportSummary{}
+	mi := &file_waze_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReportSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReportSummary) ProtoMessage() {}
+
+func (x *ReportSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_waze_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReportSummary.ProtoReflect.Descriptor instead.
+func (*ReportSummary) Descriptor() ([]byte, []int) {
+	return file_waze_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ReportSummary) GetBatches() int64 {
+	if x != nil {
+		return x.Batches
+	}
+	return 0
+}
+
+func (x *ReportSummary) GetReceived() int64 {
+	if x != nil {
+		return x.Received
+	}
+	return 0
+}
+
+func (x *ReportSummary) GetApplied() int64 {
+	if x != nil {
+		return x.Applied
+	}
+	return 0
+}
+
+// a box on the map, in degrees
+type Box struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MinX          float64                `protobuf:"fixed64,1,opt,name=min_x,json=minX,proto3" json:"min_x,omitempty"`
+	MinY          float64                `protobuf:"fixed64,2,opt,name=min_y,json=minY,proto3" json:"min_y,omitempty"`
+	MaxX          float64                `protobuf:"fixed64,3,opt,name=max_x,json=maxX,proto3" json:"max_x,omitempty"`
+	MaxY          float64                `protobuf:"fixed64,4,opt,name=max_y,json=maxY,proto3" json:"max_y,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Box) Reset() {
+	*x = Box{}
+	mi := &file_waze_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Box) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Box) ProtoMessage() {}
+
+func (x *Box) ProtoReflect() protoreflect.Message {
+	mi := &file_waze_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Box.ProtoReflect.Descriptor instead.
+func (*Box) Descriptor() ([]byte, []int) {
+	return file_waze_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Box) GetMinX() float64 {
+	if x != nil {
+		return x.MinX
+	}
+	return 0
+}
+
+func (x *Box) GetMinY() float64 {
+	if x != nil {
+		return x.MinY
+	}
+	return 0
+}
+
+func (x *Box) GetMaxX() float64 {
+	if x != nil {
+		return x.MaxX
+	}
+	return 0
+}
+
+func (x *Box) GetMaxY() float64 {
+	if x != nil {
+		return x.MaxY
+	}
+	return 0
+}
+
+type WatchTrafficRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Bbox          *Box                   `protobuf:"bytes,1,opt,name=bbox,proto3" json:"bbox,omitempty"`     // the whole map when missing
+	Topics        []string               `protobuf:"bytes,2,rep,name=topics,proto3" json:"topics,omitempty"` // cars and edges, both when empty
+	Rate          float64                `protobuf:"fixed64,3,opt,name=rate,proto3" json:"rate,omitempty"`   // at most this many updates per second, every change when 0
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchTrafficRequest) Reset() {
+	*x = WatchTrafficRequest{}
+	mi := &file_waze_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchTrafficRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchTrafficRequest) ProtoMessage() {}
+
+func (x *WatchTrafficRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_waze_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchTrafficRequest.ProtoReflect.Descriptor instead.
+func (*WatchTrafficRequest) Descriptor() ([]byte, []int) {
+	return file_waze_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *WatchTrafficRequest) GetBbox() *Box {
+	if x != nil {
+		return x.Bbox
+	}
+	return nil
+}
+
+func (x *WatchTrafficRequest) GetTopics() []string {
+	if x != nil {
+		return x.Topics
+	}
+	return nil
+}
+
+func (x *WatchTrafficRequest) GetRate() float64 {
+	if x != nil {
+		return x.Rate
+	}
+	return 0
+}
+
+type CarPosition struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	CarId         int32                  `protobuf:"varint,1,opt,name=car_id,json=carId,proto3" json:"car_id,omitempty"`
+	EdgeId        int32                  `protobuf:"varint,2,opt,name=edge_id,json=edgeId,proto3" json:"edge_id,omitempty"`
+	Progress      float64                `protobuf:"fixed64,3,opt,name=progress,proto3" json:"progress,omitempty"` // 0-1 along the edge
+	Speed         float64                `protobuf:"fixed64,4,opt,name=speed,proto3" json:"speed,omitempty"`
+	Heading       float64                `protobuf:"fixed64,5,opt,name=heading,proto3" json:"heading,omitempty"`
+	X             float64                `protobuf:"fixed64,6,opt,name=x,proto3" json:"x,omitempty"`
+	Y             float64                `protobuf:"fixed64,7,opt,name=y,proto3" json:"y,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CarPosition) Reset() {
+	*x = CarPosition{}
+	mi := &file_waze_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CarPosition) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CarPosition) ProtoMessage() {}
+
+func (x *CarPosition) ProtoReflect() protoreflect.Message {
+	mi := &file_waze_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CarPosition.ProtoReflect.Descriptor instead.
+func (*CarPosition) Descriptor() ([]byte, []int) {
+	return file_waze_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *CarPosition) GetCarId() int32 {
+	if x != nil {
+		return x.CarId
+	}
+	return 0
+}
+
+func (x *CarPosition) GetEdgeId() int32 {
+	if x != nil {
+		return x.EdgeId
+	}
+	return 0
+}
+
+func (x *CarPosition) GetProgress() float64 {
+	if x != nil {
+		return x.Progress
+	}
+	return 0
+}
+
+func (x *CarPosition) GetSpeed() float64 {
+	if x != nil {
+		return x.Speed
+	}
+	return 0
+}
+
+func (x *CarPosition) GetHeading() float64 {
+	if x != nil {
+		return x.Heading
+	}
+	return 0
+}
+
+func (x *CarPosition) GetX() float64 {
+	if x != nil {
+		return x.X
+	}
+	return 0
+}
+
+func (x *CarPosition) GetY() float64 {
+	if x != nil {
+		return x.Y
+	}
+	return 0
+}
+
+type EdgeCongestion struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EdgeId        int32                  `protobuf:"varint,1,opt,name=edge_id,json=edgeId,proto3" json:"edge_id,omitempty"`
+	Speed         float64                `protobuf:"fixed64,2,opt,name=speed,proto3" json:"speed,omitempty"`           // KM/hour, the speed limit when nothing was reported
+	Ratio         float64                `protobuf:"fixed64,3,opt,name=ratio,proto3" json:"ratio,omitempty"`           // current speed / speed limit
+	Confidence    float64                `protobuf:"fixed64,4,opt,name=confidence,proto3" json:"confidence,omitempty"` // 0-1
+	Incident      bool                   `protobuf:"varint,5,opt,name=incident,proto3" json:"incident,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EdgeCongestion) Reset() {
+	*x = EdgeCongestion{}
+	mi := &file_waze_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EdgeCongestion) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EdgeCongestion) ProtoMessage() {}
+
+func (x *EdgeCongestion) ProtoReflect() protoreflect.Message {
+	mi := &file_waze_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EdgeCongestion.ProtoReflect.Descriptor instead.
+func (*EdgeCongestion) Descriptor() ([]byte, []int) {
+	return file_waze_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *EdgeCongestion) GetEdgeId() int32 {
+	if x != nil {
+		return x.EdgeId
+	}
+	return 0
+}
+
+func (x *EdgeCongestion) GetSpeed() float64 {
+	if x != nil {
+		return x.Speed
+	}
+	return 0
+}
+
+func (x *EdgeCongestion) GetRatio() float64 {
+	if x != nil {
+		return x.Ratio
+	}
+	return 0
+}
+
+func (x *EdgeCongestion) GetConfidence() float64 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+func (x *EdgeCongestion) GetIncident() bool {
+	if x != nil {
+		return x.Incident
+	}
+	return false
+}
+
+// a keyframe holds every car and edge in the box, a delta only what changed since the previous update
+type TrafficUpdate struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sequence      uint32                 `protobuf:"varint,1,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	Keyframe      bool                   `protobuf:"varint,2,opt,name=keyframe,proto3" json:"keyframe,omitempty"`
+	Cars          []*CarPosition         `protobuf:"bytes,3,rep,name=cars,proto3" json:"cars,omitempty"`
+	Removed       []int32                `protobuf:"varint,4,rep,packed,name=removed,proto3" json:"removed,omitempty"` // cars that left the map or the box
+	Edges         []*EdgeCongestion      `protobuf:"bytes,5,rep,name=edges,proto3" json:"edges,omitempty"`
+	MapReloaded   bool                   `protobuf:"varint,6,opt,name=map_reloaded,json=mapReloaded,proto3" json:"map_reloaded,omitempty"` // the map was replaced, the edges of earlier updates are gone
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TrafficUpdate) Reset() {
+	*x = TrafficUpdate{}
+	mi := &file_waze_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TrafficUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TrafficUpdate) ProtoMessage() {}
+
+func (x *TrafficUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_waze_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TrafficUpdate.ProtoReflect.Descriptor instead.
+func (*TrafficUpdate) Descriptor() ([]byte, []int) {
+	return file_waze_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *TrafficUpdate) GetSequence() uint32 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *TrafficUpdate) GetKeyframe() bool {
+	if x != nil {
+		return x.Keyframe
+	}
+	return false
+}
+
+func (x *TrafficUpdate) GetCars() []*CarPosition {
+	if x != nil {
+		return x.Cars
+	}
+	return nil
+}
+
+func (x *TrafficUpdate) GetRemoved() []int32 {
+	if x != nil {
+		return x.Removed
+	}
+	return nil
+}
+
+func (x *TrafficUpdate) GetEdges() []*EdgeCongestion {
+	if x != nil {
+		return x.Edges
+	}
+	return nil
+}
+
+func (x *TrafficUpdate) GetMapReloaded() bool {
+	if x != nil {
+		return x.MapReloaded
+	}
+	return false
+}
+
+type WatchRouteRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Route *RouteRequest          `protobuf:"bytes,1,opt,name=route,proto3" json:"route,omitempty"`
+	// the route is checked again this often, 5 seconds when 0
+	IntervalSeconds float64 `protobuf:"fixed64,2,opt,name=interval_seconds,json=intervalSeconds,proto3" json:"interval_seconds,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *WatchRouteRequest) Reset() {
+	*x = WatchRouteRequest{}
+	mi := &file_waze_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchRouteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRouteRequest) ProtoMessage() {}
+
+func (x *WatchRouteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_waze_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRouteRequest.ProtoReflect.Descriptor instead.
+func (*WatchRouteRequest) Descriptor() ([]byte, []int) {
+	return file_waze_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *WatchRouteRequest) GetRoute() *RouteRequest {
+	if x != nil {
+		return x.Route
+	}
+	return nil
+}
+
+func (x *WatchRouteRequest) GetIntervalSeconds() float64 {
+	if x != nil {
+		return x.IntervalSeconds
+	}
+	return 0
+}
+
+var File_waze_proto protoreflect.FileDescriptor
+
+const file_waze_proto_rawDesc = "" +
+	"\n" +
+	"\n" +
+	"waze.proto\x12\awaze.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"&\n" +
+	"\bLocation\x12\f\n" +
+	"\x01x\x18\x01 \x01(\x01R\x01x\x12\f\n" +
+	"\x01y\x18\x02 \x01(\x01R\x01y\"_\n" +
+	"\bWaypoint\x12\x19\n" +
+	"\anode_id\x18\x01 \x01(\x05H\x00R\x06nodeId\x12/\n" +
+	"\blocation\x18\x02 \x01(\v2\x11.waze.v1.LocationH\x00R\blocationB\a\n" +
+	"\x05point\"\xa3\x03\n" +
+	"\fRouteRequest\x12%\n" +
+	"\x04from\x18\x01 \x01(\v2\x11.waze.v1.WaypointR\x04from\x12!\n" +
+	"\x02to\x18\x02 \x01(\v2\x11.waze.v1.WaypointR\x02to\x12\x18\n" +
+	"\aprofile\x18\x03 \x01(\tR\aprofile\x12A\n" +
+	"\x0edeparture_time\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\rdepartureTime\x12\x1f\n" +
+	"\vavoid_edges\x18\x05 \x03(\x05R\n" +
+	"avoidEdges\x12,\n" +
+	"\x12avoid_road_classes\x18\x06 \x03(\tR\x10avoidRoadClasses\x12\"\n" +
+	"\falternatives\x18\a \x01(\x05R\falternatives\x12\x1f\n" +
+	"\bgeometry\x18\b \x01(\bH\x00R\bgeometry\x88\x01\x01\x12\"\n" +
+	"\finstructions\x18\t \x01(\bR\finstructions\x12'\n" +
+	"\x0ftimeout_seconds\x18\n" +
+	" \x01(\x01R\x0etimeoutSecondsB\v\n" +
+	"\t_geometry\"\xce\x01\n" +
+	"\vInstruction\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x1a\n" +
+	"\bmodifier\x18\x02 \x01(\tR\bmodifier\x12\x16\n" +
+	"\x06street\x18\x03 \x01(\tR\x06street\x12\x12\n" +
+	"\x04exit\x18\x04 \x01(\x05R\x04exit\x12\x17\n" +
+	"\anode_id\x18\x05 \x01(\x05R\x06nodeId\x12\x1a\n" +
+	"\bdistance\x18\x06 \x01(\x01R\bdistance\x12\x1a\n" +
+	"\bduration\x18\a \x01(\x01R\bduration\x12\x12\n" +
+	"\x04text\x18\b \x01(\tR\x04text\"\xb7\x03\n" +
+	"\rRouteResponse\x12\x14\n" +
+	"\x05route\x18\x01 \x03(\x05R\x05route\x12\x10\n" +
+	"\x03eta\x18\x02 \x01(\x01R\x03eta\x12\x1a\n" +
+	"\bdistance\x18\x03 \x01(\x01R\bdistance\x12\x18\n" +
+	"\aprofile\x18\x04 \x01(\tR\aprofile\x12\x1a\n" +
+	"\bgeometry\x18\x05 \x01(\tR\bgeometry\x12\x1b\n" +
+	"\tfrom_node\x18\x06 \x01(\x05R\bfromNode\x12\x17\n" +
+	"\ato_node\x18\a \x01(\x05R\x06toNode\x12A\n" +
+	"\x0edeparture_time\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\rdepartureTime\x12=\n" +
+	"\farrival_time\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\varrivalTime\x128\n" +
+	"\finstructions\x18\n" +
+	" \x03(\v2\x14.waze.v1.InstructionR\finstructions\x12:\n" +
+	"\falternatives\x18\v \x03(\v2\x16.waze.v1.RouteResponseR\falternatives\"\x8d\x01\n" +
+	"\rMatrixRequest\x12+\n" +
+	"\asources\x18\x01 \x03(\v2\x11.waze.v1.WaypointR\asources\x125\n" +
+	"\fdestinations\x18\x02 \x03(\v2\x11.waze.v1.WaypointR\fdestinations\x12\x18\n" +
+	"\aprofile\x18\x03 \x01(\tR\aprofile\"P\n" +
+	"\n" +
+	"MatrixCell\x12\x14\n" +
+	"\x05found\x18\x01 \x01(\bR\x05found\x12\x10\n" +
+	"\x03eta\x18\x02 \x01(\x01R\x03eta\x12\x1a\n" +
+	"\bdistance\x18\x03 \x01(\x01R\bdistance\"6\n" +
+	"\tMatrixRow\x12)\n" +
+	"\x05cells\x18\x01 \x03(\v2\x13.waze.v1.MatrixCellR\x05cells\"\x88\x01\n" +
+	"\x0eMatrixResponse\x12&\n" +
+	"\x04rows\x18\x01 \x03(\v2\x12.waze.v1.MatrixRowR\x04rows\x12!\n" +
+	"\fsource_nodes\x18\x02 \x03(\x05R\vsourceNodes\x12+\n" +
+	"\x11destination_nodes\x18\x03 \x03(\x05R\x10destinationNodes\"\xa9\x01\n" +
+	"\rTrafficReport\x12\x15\n" +
+	"\x06car_id\x18\x01 \x01(\x05R\x05carId\x12\x17\n" +
+	"\aedge_id\x18\x02 \x01(\x05R\x06edgeId\x12\x14\n" +
+	"\x05speed\x18\x03 \x01(\x01R\x05speed\x12\x1a\n" +
+	"\bprogress\x18\x04 \x01(\x01R\bprogress\x12\x18\n" +
+	"\aheading\x18\x05 \x01(\x01R\aheading\x12\x1c\n" +
+	"\ttimestamp\x18\x06 \x01(\x03R\ttimestamp\"?\n" +
+	"\vReportBatch\x120\n" +
+	"\areports\x18\x01 \x03(\v2\x16.waze.v1.TrafficReportR\areports\"_\n" +
+	"\rReportSummary\x12\x18\n" +
+	"\abatches\x18\x01 \x01(\x03R\abatches\x12\x1a\n" +
+	"\breceived\x18\x02 \x01(\x03R\breceived\x12\x18\n" +
+	"\aapplied\x18\x03 \x01(\x03R\aapplied\"Y\n" +
+	"\x03Box\x12\x13\n" +
+	"\x05min_x\x18\x01 \x01(\x01R\x04minX\x12\x13\n" +
+	"\x05min_y\x18\x02 \x01(\x01R\x04minY\x12\x13\n" +
+	"\x05max_x\x18\x03 \x01(\x01R\x04maxX\x12\x13\n" +
+	"\x05max_y\x18\x04 \x01(\x01R\x04maxY\"c\n" +
+	"\x13WatchTrafficRequest\x12 \n" +
+	"\x04bbox\x18\x01 \x01(\v2\f.waze.v1.BoxR\x04bbox\x12\x16\n" +
+	"\x06topics\x18\x02 \x03(\tR\x06topics\x12\x12\n" +
+	"\x04rate\x18\x03 \x01(\x01R\x04rate\"\xa5\x01\n" +
+	"\vCarPosition\x12\x15\n" +
+	"\x06car_id\x18\x01 \x01(\x05R\x05carId\x12\x17\n" +
+	"\aedge_id\x18\x02 \x01(\x05R\x06edgeId\x12\x1a\n" +
+	"\bprogress\x18\x03 \x01(\x01R\bprogress\x12\x14\n" +
+	"\x05speed\x18\x04 \x01(\x01R\x05speed\x12\x18\n" +
+	"\aheading\x18\x05 \x01(\x01R\aheading\x12\f\n" +
+	"\x01x\x18\x06 \x01(\x01R\x01x\x12\f\n" +
+	"\x01y\x18\a \x01(\x01R\x01y\"\x91\x01\n" +
+	"\x0eEdgeCongestion\x12\x17\n" +
+	"\aedge_id\x18\x01 \x01(\x05R\x06edgeId\x12\x14\n" +
+	"\x05speed\x18\x02 \x01(\x01R\x05speed\x12\x14\n" +
+	"\x05ratio\x18\x03 \x01(\x01R\x05ratio\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\x04 \x01(\x01R\n" +
+	"confidence\x12\x1a\n" +
+	"\bincident\x18\x05 \x01(\bR\bincident\"\xdd\x01\n" +
+	"\rTrafficUpdate\x12\x1a\n" +
+	"\bsequence\x18\x01 \x01(\rR\bsequence\x12\x1a\n" +
+	"\bkeyframe\x18\x02 \x01(\bR\bkeyframe\x12(\n" +
+	"\x04cars\x18\x03 \x03(\v2\x14.waze.v1.CarPositionR\x04cars\x12\x18\n" +
+	"\aremoved\x18\x04 \x03(\x05R\aremoved\x12-\n" +
+	"\x05edges\x18\x05 \x03(\v2\x17.waze.v1.EdgeCongestionR\x05edges\x12!\n" +
+	"\fmap_reloaded\x18\x06 \x01(\bR\vmapReloaded\"k\n" +
+	"\x11WatchRouteRequest\x12+\n" +
+	"\x05route\x18\x01 \x01(\v2\x15.waze.v1.RouteRequestR\x05route\x12)\n" +
+	"\x10interval_seconds\x18\x02 \x01(\x01R\x0fintervalSeconds2\xc6\x02\n" +
+	"\x04Waze\x126\n" +
+	"\x05Route\x12\x15.waze.v1.RouteRequest\x1a\x16.waze.v1.RouteResponse\x129\n" +
+	"\x06Matrix\x12\x16.waze.v1.MatrixRequest\x1a\x17.waze.v1.MatrixResponse\x12?\n" +
+	"\rReportTraffic\x12\x14.waze.v1.ReportBatch\x1a\x16.waze.v1.ReportSummary(\x01\x12F\n" +
+	"\fWatchTraffic\x12\x1c.waze.v1.WatchTrafficRequest\x1a\x16.waze.v1.TrafficUpdate0\x01\x12B\n" +
+	"\n" +
+	"WatchRoute\x12\x1a.waze.v1.WatchRouteRequest\x1a\x16.waze.v1.RouteResponse0\x01B\x16Z\x14waze/internal/wazepbb\x06proto3"
+
+var (
+	file_waze_proto_rawDescOnce sync.Once
+	file_waze_proto_rawDescData []byte
+)
+
+func file_waze_proto_rawDescGZIP() []byte {
+	file_waze_proto_rawDescOnce.Do(func() {
+		file_waze_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_waze_proto_rawDesc), len(file_waze_proto_rawDesc)))
+	})
+	return file_waze_proto_rawDescData
+}
+
+var file_waze_proto_msgTypes = make([]protoimpl.MessageInfo, 18)
+var file_waze_proto_goTypes = []any{
+	(*Location)(nil),              // 0: waze.v1.Location
+	(*Waypoint)(nil),              // 1: waze.v1.Waypoint
+	(*RouteRequest)(nil),          // 2: waze.v1.RouteRequest
+	(*Instruction)(nil),           // 3: waze.v1.Instruction
+	(*RouteResponse)(nil),         // 4: waze.v1.RouteResponse
+	(*MatrixRequest)(nil),         // 5: waze.v1.MatrixRequest
+	(*MatrixCell)(nil),            // 6: waze.v1.MatrixCell
+	(*MatrixRow)(nil),             // 7: waze.v1.MatrixRow
+	(*MatrixResponse)(nil),        // 8: waze.v1.MatrixResponse
+	(*TrafficReport)(nil),         // 9: waze.v1.TrafficReport
+	(*ReportBatch)(nil),           // 10: waze.v1.ReportBatch
+	(*ReportSummary)(nil),         // 11: waze.v1.ReportSummary
+	(*Box)(nil),                   // 12: waze.v1.Box
+	(*WatchTrafficRequest)(nil),   // 13: waze.v1.WatchTrafficRequest
+	(*CarPosition)(nil),           // 14: waze.v1.CarPosition
+	(*EdgeCongestion)(nil),        // 15: waze.v1.EdgeCongestion
+	(*TrafficUpdate)(nil),         // 16: waze.v1.TrafficUpdate
+	(*WatchRouteRequest)(nil),     // 17: waze.v1.WatchRouteRequest
+	(*timestamppb.Timestamp)(nil), // 18: google.protobuf.Timestamp
+}
+var file_waze_proto_depIdxs = []int32{
+	0,  // 0: waze.v1.Waypoint.location:type_name -> waze.v1.Location
+	1,  // 1: waze.v1.RouteRequest.from:type_name -> waze.v1.Waypoint
+	1,  // 2: waze.v1.RouteRequest.to:type_name -> waze.v1.Waypoint
+	18, // 3: waze.v1.RouteRequest.departure_time:type_name -> google.protobuf.Timestamp
+	18, // 4: waze.v1.RouteResponse.departure_time:type_name -> google.protobuf.Timestamp
+	18, // 5: waze.v1.RouteResponse.arrival_time:type_name -> google.protobuf.Timestamp
+	3,  // 6: waze.v1.RouteResponse.instructions:type_name -> waze.v1.Instruction
+	4,  // 7: waze.v1.RouteResponse.alternatives:type_name -> waze.v1.RouteResponse
+	1,  // 8: waze.v1.MatrixRequest.sources:type_name -> waze.v1.Waypoint
+	1,  // 9: waze.v1.MatrixRequest.destinations:type_name -> waze.v1.Waypoint
+	6,  // 10: waze.v1.MatrixRow.cells:type_name -> waze.v1.MatrixCell
+	7,  // 11: waze.v1.MatrixResponse.rows:type_name -> waze.v1.MatrixRow
+	9,  // 12: waze.v1.ReportBatch.reports:type_name -> waze.v1.TrafficReport
+	12, // 13: waze.v1.WatchTrafficRequest.bbox:type_name -> waze.v1.Box
+	14, // 14: waze.v1.TrafficUpdate.cars:type_name -> waze.v1.CarPosition
+	15, // 15: waze.v1.TrafficUpdate.edges:type_name -> waze.v1.EdgeCongestion
+	2,  // 16: waze.v1.WatchRouteRequest.route:type_name -> waze.v1.RouteRequest
+	2,  // 17: waze.v1.Waze.Route:input_type -> waze.v1.RouteRequest
+	5,  // 18: waze.v1.Waze.Matrix:input_type -> waze.v1.MatrixRequest
+	10, // 19: waze.v1.Waze.ReportTraffic:input_type -> waze.v1.ReportBatch
+	13, // 20: waze.v1.Waze.WatchTraffic:input_type -> waze.v1.WatchTrafficRequest
+	17, // 21: waze.v1.Waze.WatchRoute:input_type -> waze.v1.WatchRouteRequest
+	4,  // 22: waze.v1.Waze.Route:output_type -> waze.v1.RouteResponse
+	8,  // 23: waze.v1.Waze.Matrix:output_type -> waze.v1.MatrixResponse
+	11, // 24: waze.v1.Waze.ReportTraffic:output_type -> waze.v1.ReportSummary
+	16, // 25: waze.v1.Waze.WatchTraffic:output_type -> waze.v1.TrafficUpdate
+	4,  // 26: waze.v1.Waze.WatchRoute:output_type -> waze.v1.RouteResponse
+	22, // [22:27] is the sub-list for method output_type
+	17, // [17:22] is the sub-list for method input_type
+	17, // [17:17] is the sub-list for extension type_name
+	17, // [17:17] is the sub-list for extension extendee
+	0,  // [0:17] is the sub-list for field type_name
+}
+
+func init() { file_waze_proto_init() }
+func file_waze_proto_init() {
+	if File_waze_proto != nil {
+		return
+	}
+	file_waze_proto_msgTypes[1].OneofWrappers = []any{
+		(*Waypoint_NodeId)(nil),
+		(*Waypoint_Location)(nil),
+	}
+	file_waze_proto_msgTypes[2].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_waze_proto_rawDesc), len(file_waze_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   18,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_waze_proto_goTypes,
+		DependencyIndexes: file_waze_proto_depIdxs,
+		MessageInfos:      file_waze_proto_msgTypes,
+	}.Build()
+	File_waze_proto = out.File
+	file_waze_proto_goTypes = nil
+	file_waze_proto_depIdxs = nil
+}
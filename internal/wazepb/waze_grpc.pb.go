@@ -0,0 +1,286 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: waze.proto
+
+// the gRPC API of the server, the same routing and traffic as the HTTP API.
+// regenerate with go generate ./internal/wazepb after changing it
+
+package wazepb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Waze_Route_FullMethodName         = "/waze.v1.Waze/Route"
+	Waze_Matrix_FullMethodName        = "/waze.v1.Waze/Matrix"
+	Waze_ReportTraffic_FullMethodName = "/waze.v1.Waze/ReportTraffic"
+	Waze_WatchTraffic_FullMethodName  = "/waze.v1.Waze/WatchTraffic"
+	Waze_WatchRoute_FullMethodName    = "/waze.v1.Waze/WatchRoute"
+)
+
+// WazeClient is the client API for Waze service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type WazeClient interface {
+	// the route between two nodes or locations, like POST /api/v1/navigate
+	Route(ctx context.Context, in *RouteRequest, opts ...grpc.CallOption) (*RouteResponse, error)
+	// the routes from every source to every destination
+	Matrix(ctx context.Context, in *MatrixRequest, opts ...grpc.CallOption) (*MatrixResponse, error)
+	// a stream of traffic report batches, like POST /api/v1/traffic. the summary is sent when the client closes it
+	ReportTraffic(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ReportBatch, ReportSummary], error)
+	// the cars and the edge congestion, a keyframe and then the changes
+	WatchTraffic(ctx context.Context, in *WatchTrafficRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TrafficUpdate], error)
+	// the route again whenever it or its ETA changes with the traffic
+	WatchRoute(ctx context.Context, in *WatchRouteRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RouteResponse], error)
+}
+
+type wazeClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewWazeClient(cc grpc.ClientConnInterface) WazeClient {
+	return &wazeClient{cc}
+}
+
+func (c *wazeClient) Route(ctx context.Context, in *RouteRequest, opts ...grpc.CallOption) (*RouteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RouteResponse)
+	err := c.cc.Invoke(ctx, Waze_Route_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wazeClient) Matrix(ctx context.Context, in *MatrixRequest, opts ...grpc.CallOption) (*MatrixResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MatrixResponse)
+	err := c.cc.Invoke(ctx, Waze_Matrix_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wazeClient) ReportTraffic(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ReportBatch, ReportSummary], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Waze_ServiceDesc.Streams[0], Waze_ReportTraffic_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ReportBatch, ReportSummary]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Waze_ReportTrafficClient = grpc.ClientStreamingClient[ReportBatch, ReportSummary]
+
+func (c *wazeClient) WatchTraffic(ctx context.Context, in *WatchTrafficRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TrafficUpdate], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Waze_ServiceDesc.Streams[1], Waze_WatchTraffic_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchTrafficRequest, TrafficUpdate]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Waze_WatchTrafficClient = grpc.ServerStreamingClient[TrafficUpdate]
+
+func (c *wazeClient) WatchRoute(ctx context.Context, in *WatchRouteRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RouteResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Waze_ServiceDesc.Streams[2], Waze_WatchRoute_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchRouteRequest, RouteResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Waze_WatchRouteClient = grpc.ServerStreamingClient[RouteResponse]
+
+// WazeServer is the server API for Waze service.
+// All implementations must embed UnimplementedWazeServer
+// for forward compatibility.
+type WazeServer interface {
+	// the route between two nodes or locations, like POST /api/v1/navigate
+	Route(context.Context, *RouteRequest) (*RouteResponse, error)
+	// the routes from every source to every destination
+	Matrix(context.Context, *MatrixRequest) (*MatrixResponse, error)
+	// a stream of traffic report batches, like POST /api/v1/traffic. the summary is sent when the client closes it
+	ReportTraffic(grpc.ClientStreamingServer[ReportBatch, ReportSummary]) error
+	// the cars and the edge congestion, a keyframe and then the changes
+	WatchTraffic(*WatchTrafficRequest, grpc.ServerStreamingServer[TrafficUpdate]) error
+	// the route again whenever it or its ETA changes with the traffic
+	WatchRoute(*WatchRouteRequest, grpc.ServerStreamingServer[RouteResponse]) error
+	mustEmbedUnimplementedWazeServer()
+}
+
+// UnimplementedWazeServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedWazeServer struct{}
+
+func (UnimplementedWazeServer) Route(context.Context, *RouteRequest) (*RouteResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Route not implemented")
+}
+func (UnimplementedWazeServer) Matrix(context.Context, *MatrixRequest) (*MatrixResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Matrix not implemented")
+}
+func (UnimplementedWazeServer) ReportTraffic(grpc.ClientStreamingServer[ReportBatch, ReportSummary]) error {
+	return status.Error(codes.Unimplemented, "method ReportTraffic not implemented")
+}
+func (UnimplementedWazeServer) WatchTraffic(*WatchTrafficRequest, grpc.ServerStreamingServer[TrafficUpdate]) error {
+	return status.Error(codes.Unimplemented, "method WatchTraffic not implemented")
+}
+func (UnimplementedWazeServer) WatchRoute(*WatchRouteRequest, grpc.ServerStreamingServer[RouteResponse]) error {
+	return status.Error(codes.Unimplemented, "method WatchRoute not implemented")
+}
+func (UnimplementedWazeServer) mustEmbedUnimplementedWazeServer() {}
+func (UnimplementedWazeServer) testEmbeddedByValue()              {}
+
+// UnsafeWazeServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to WazeServer will
+// result in compilation errors.
+type UnsafeWazeServer interface {
+	mustEmbedUnimplementedWazeServer()
+}
+
+func RegisterWazeServer(s grpc.ServiceRegistrar, srv WazeServer) {
+	// If the following call panics, it indicates UnimplementedWazeServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Waze_ServiceDesc, srv)
+}
+
+func _Waze_Route_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RouteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WazeServer).Route(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Waze_Route_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WazeServer).Route(ctx, req.(*RouteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Waze_Matrix_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MatrixRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WazeServer).Matrix(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Waze_Matrix_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WazeServer).Matrix(ctx, req.(*MatrixRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Waze_ReportTraffic_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(WazeServer).ReportTraffic(&grpc.GenericServerStream[ReportBatch, ReportSummary]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Waze_ReportTrafficServer = grpc.ClientStreamingServer[ReportBatch, ReportSummary]
+
+func _Waze_WatchTraffic_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchTrafficRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WazeServer).WatchTraffic(m, &grpc.GenericServerStream[WatchTrafficRequest, TrafficUpdate]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Waze_WatchTrafficServer = grpc.ServerStreamingServer[TrafficUpdate]
+
+func _Waze_WatchRoute_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRouteRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WazeServer).WatchRoute(m, &grpc.GenericServerStream[WatchRouteRequest, RouteResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Waze_WatchRouteServer = grpc.ServerStreamingServer[RouteResponse]
+
+// Waze_ServiceDesc is the grpc.ServiceDesc for Waze service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Waze_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "waze.v1.Waze",
+	HandlerType: (*WazeServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Route",
+			Handler:    _Waze_Route_Handler,
+		},
+		{
+			MethodName: "Matrix",
+			Handler:    _Waze_Matrix_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ReportTraffic",
+			Handler:       _Waze_ReportTraffic_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "WatchTraffic",
+			Handler:       _Waze_WatchTraffic_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchRoute",
+			Handler:       _Waze_WatchRoute_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "waze.proto",
+}